@@ -0,0 +1,82 @@
+// Command hnsw-server serves the collections of a data directory over HTTP.
+//
+// Usage:
+//
+//	hnsw-server [-addr :8080] [-data ./data] [-flush-interval 30s]
+//
+// Changed collections are saved every flush interval and on SIGINT or
+// SIGTERM, after in-flight requests have finished.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"go-hnsw/hnsw/server"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	dataDir := flag.String("data", "data", "directory holding the collection files")
+	flushInterval := flag.Duration("flush-interval", 30*time.Second, "how often changed collections are saved, 0 to save only on shutdown")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "how long to wait for in-flight requests on shutdown")
+	flag.Parse()
+
+	s, err := server.New(*dataDir)
+	if err != nil {
+		log.Fatalf("hnsw-server: %s", err)
+	}
+
+	httpServer := &http.Server{Addr: *addr, Handler: s.Handler()}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *flushInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(*flushInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := s.Flush(); err != nil {
+						log.Printf("hnsw-server: flush: %s", err)
+					}
+				}
+			}
+		}()
+	}
+
+	// done is closed once Shutdown has returned, so the final flush sees the
+	// writes of every finished request.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("hnsw-server: shutdown: %s", err)
+		}
+	}()
+
+	log.Printf("hnsw-server: listening on %s, data in %s", *addr, *dataDir)
+	err = httpServer.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("hnsw-server: %s", err)
+	}
+	<-done
+
+	err = s.Close()
+	if err != nil {
+		log.Fatalf("hnsw-server: %s", err)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/datasets"
+	"go-hnsw/hnsw/eval"
+	"go-hnsw/hnsw/vectors"
+	"io"
+	"os"
+)
+
+func runBench(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := newFlagSet("bench")
+	index := flags.String("index", "", "index file")
+	queriesPath := flags.String("queries", "", "query vectors file")
+	truthPath := flags.String("truth", "", "ground truth .ivecs file; computed exactly when empty")
+	array := flags.String("array", "", "array name inside an .npz archive")
+	k := flags.Int("k", 10, "number of results per query")
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	err = required(flags, "index", "queries")
+	if err != nil {
+		return err
+	}
+	if *k <= 0 {
+		return errors.New("-k must be > 0")
+	}
+
+	collection, err := loadIndex(*index)
+	if err != nil {
+		return err
+	}
+
+	points, err := readPoints(*queriesPath, *array)
+	if err != nil {
+		return err
+	}
+	queries := make([]vectors.Vector, len(points))
+	for i, p := range points {
+		queries[i] = p.Vector
+	}
+	err = checkQueries(queries, collection.VectorDimension())
+	if err != nil {
+		return err
+	}
+
+	var truth [][]uint64
+	if *truthPath != "" {
+		truth, err = readTruth(*truthPath)
+	} else {
+		truth = exactTruth(collection, queries, *k)
+	}
+	if err != nil {
+		return err
+	}
+
+	stats := collection.Stats()
+	result := eval.Measure(collection, queries, truth, *k)
+	result.Dataset = *index
+	result.Layers = len(stats.Layers)
+	result.Connectivity = stats.Connectivity
+	result.PrefetchFactor = stats.PrefetchFactor
+	result.MemoryBytes = uint64(stats.MemoryBytes)
+
+	if *asJSON {
+		return eval.WriteJSON(stdout, []eval.Result{result})
+	}
+	return eval.WriteTable(stdout, []eval.Result{result})
+}
+
+func readTruth(path string) ([][]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return datasets.NewReader(f, datasets.Ivecs).ReadAllIds()
+}
+
+func exactTruth(collection *hnsw.HnswCollection, queries []vectors.Vector, k int) [][]uint64 {
+	flat := hnsw.NewFlatCollection(collection.VectorDimension(), collection.Distance())
+	collection.Range(func(point hnsw.Point) bool {
+		flat.Upsert(point.Id, point.Vector, point.Value)
+		return true
+	})
+
+	truth := make([][]uint64, len(queries))
+	for i, q := range queries {
+		for _, r := range flat.Search(q, k) {
+			truth[i] = append(truth[i], r.Id)
+		}
+	}
+	return truth
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/quantization"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"io"
+	"time"
+)
+
+func runBuild(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := newFlagSet("build")
+	input := flags.String("input", "", "vector file (.fvecs, .bvecs, .ivecs, .npy, .npz, .jsonl, .csv)")
+	output := flags.String("output", "", "index file to write")
+	array := flags.String("array", "", "array name inside an .npz archive")
+	metric := flags.String("metric", "euclidian", "registered distance name")
+	layers := flags.Int("layers", 3, "number of layers")
+	connectivity := flags.Int("connectivity", 16, "neighbors linked on insert")
+	prefetch := flags.Int("prefetch", 4, "candidates explored per requested result")
+	pqSubspaces := flags.Int("pq-subspaces", 0, "quantize with this many product quantization subspaces")
+	pqCentroids := flags.Int("pq-centroids", 256, "centroids per product quantization subspace")
+	binary := flags.Bool("binary", false, "use binary quantization")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	err = required(flags, "input", "output")
+	if err != nil {
+		return err
+	}
+
+	distance, ok := distances.ByName(*metric)
+	if !ok {
+		return fmt.Errorf("unknown metric %q", *metric)
+	}
+	if *layers <= 0 || *connectivity <= 0 || *prefetch <= 0 {
+		return errors.New("-layers, -connectivity and -prefetch must be > 0")
+	}
+	if *pqSubspaces < 0 {
+		return errors.New("-pq-subspaces must be >= 0")
+	}
+	if *pqSubspaces > 0 && (*pqCentroids <= 0 || *pqCentroids > 256) {
+		return errors.New("-pq-centroids must be in range [1, 256]")
+	}
+
+	points, err := readPoints(*input, *array)
+	if err != nil {
+		return err
+	}
+	if len(points) == 0 {
+		return errors.New("input has no vectors")
+	}
+	dimension := len(points[0].Vector)
+	for i, p := range points {
+		if len(p.Vector) != dimension {
+			return fmt.Errorf("vector %d has dimension %d, %d expected", i, len(p.Vector), dimension)
+		}
+	}
+	if *pqSubspaces > 0 && dimension%*pqSubspaces != 0 {
+		return fmt.Errorf("-pq-subspaces %d does not divide the dimension %d", *pqSubspaces, dimension)
+	}
+
+	start := time.Now()
+	collection := hnsw.NewHnswCollection(*layers, dimension, distance, *connectivity, *prefetch)
+	collection.UpsertBatch(points)
+
+	switch {
+	case *pqSubspaces > 0:
+		training := make([]vectors.Vector, 0, len(points))
+		for _, p := range points {
+			training = append(training, p.Vector)
+		}
+		collection.Quantize(quantization.TrainProductQuantizer(training, *pqSubspaces, min(*pqCentroids, len(training)), 25))
+	case *binary:
+		collection.QuantizeBinary()
+	}
+	buildTime := time.Since(start)
+
+	err = saveIndex(*output, collection)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "built %s: %d points of dimension %d in %s\n", *output, collection.Len(), dimension, buildTime.Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"go-hnsw/hnsw/datasets"
+	"go-hnsw/internal/fsutil"
+	"io"
+)
+
+func runDump(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := newFlagSet("dump")
+	index := flags.String("index", "", "index file")
+	output := flags.String("output", "-", "output .jsonl or .csv file, or - for stdout")
+	format := flags.String("format", "jsonl", "format when writing to stdout: jsonl or csv")
+	encoding := flags.String("encoding", "", "value encoding: utf8 or base64, automatic when empty")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	err = required(flags, "index")
+	if err != nil {
+		return err
+	}
+
+	opts := datasets.DumpOptions{Encoding: datasets.ValueEncoding(*encoding)}
+	switch opts.Encoding {
+	case datasets.Auto, datasets.UTF8, datasets.Base64:
+	default:
+		return fmt.Errorf("unknown encoding %q", *encoding)
+	}
+
+	if *output == "-" {
+		f, ok := dumpFormat("." + *format)
+		if !ok {
+			return fmt.Errorf("unknown dump format %q", *format)
+		}
+		opts.Format = f
+	} else {
+		f, ok := dumpFormat(*output)
+		if !ok {
+			return fmt.Errorf("output %s must be a .jsonl or .csv file", *output)
+		}
+		opts.Format = f
+	}
+
+	collection, err := loadIndex(*index)
+	if err != nil {
+		return err
+	}
+
+	if *output == "-" {
+		return datasets.Dump(collection, stdout, opts)
+	}
+	return fsutil.WriteFileAtomic(*output, func(writer io.Writer) error {
+		return datasets.Dump(collection, writer, opts)
+	})
+}
+
+func runConvert(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := newFlagSet("convert")
+	input := flags.String("input", "", "input vector file")
+	output := flags.String("output", "", "output vector file, format chosen by extension")
+	array := flags.String("array", "", "array name inside an input .npz archive")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	err = required(flags, "input", "output")
+	if err != nil {
+		return err
+	}
+
+	points, err := readPoints(*input, *array)
+	if err != nil {
+		return err
+	}
+	err = writePoints(*output, points)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "converted %d vectors to %s\n", len(points), *output)
+	return nil
+}
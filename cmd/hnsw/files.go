@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/datasets"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"go-hnsw/internal/fsutil"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func extension(path string) string {
+	return strings.ToLower(filepath.Ext(path))
+}
+
+func dumpFormat(path string) (datasets.DumpFormat, bool) {
+	switch extension(path) {
+	case ".jsonl", ".ndjson", ".json":
+		return datasets.JSONLines, true
+	case ".csv":
+		return datasets.CSV, true
+	}
+	return 0, false
+}
+
+// readPoints reads every vector of a file. Vector files get ids in file order,
+// dumps keep their own ids. array selects the array of an .npz archive.
+func readPoints(path string, array string) ([]hnsw.Point, error) {
+	if format, ok := dumpFormat(path); ok {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return readDump(f, format)
+	}
+
+	var data []vectors.Vector
+	var err error
+	switch extension(path) {
+	case ".npy":
+		data, err = readNpy(path)
+	case ".npz":
+		data, err = readNpz(path, array)
+	default:
+		data, err = datasets.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]hnsw.Point, len(data))
+	for i, v := range data {
+		points[i] = hnsw.Point{Id: uint64(i), Vector: v}
+	}
+	return points, nil
+}
+
+func readDump(reader io.Reader, format datasets.DumpFormat) ([]hnsw.Point, error) {
+	dumpReader, err := datasets.NewDumpReader(reader, format)
+	if err != nil {
+		return nil, err
+	}
+
+	points := []hnsw.Point{}
+	for {
+		record, err := dumpReader.Read()
+		if err == io.EOF {
+			return points, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		point, err := record.Point()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", dumpReader.Line(), err)
+		}
+		if len(points) > 0 && len(point.Vector) != len(points[0].Vector) {
+			return nil, fmt.Errorf("line %d: dimension %d differs from the first point's %d", dumpReader.Line(), len(point.Vector), len(points[0].Vector))
+		}
+		points = append(points, point)
+	}
+}
+
+func readNpy(path string) ([]vectors.Vector, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	array, err := datasets.ReadNpy(f)
+	if err != nil {
+		return nil, err
+	}
+	return array.Vectors()
+}
+
+func readNpz(path string, name string) ([]vectors.Vector, error) {
+	npz, err := datasets.OpenNpz(path)
+	if err != nil {
+		return nil, err
+	}
+	defer npz.Close()
+
+	if names := npz.Names(); name == "" && len(names) == 1 {
+		name = names[0]
+	}
+	array, err := npz.Array(name)
+	if err != nil {
+		return nil, err
+	}
+	return array.Vectors()
+}
+
+// writePoints writes points to a file whose format is chosen by extension.
+// Only dumps and .npz archives keep the ids.
+func writePoints(path string, points []hnsw.Point) error {
+	dimension := 0
+	if len(points) > 0 {
+		dimension = len(points[0].Vector)
+	}
+	flat := hnsw.NewFlatCollection(dimension, distances.Euclidian)
+	flat.UpsertBatch(points)
+
+	return fsutil.WriteFileAtomic(path, func(writer io.Writer) error {
+		if format, ok := dumpFormat(path); ok {
+			return datasets.Dump(flat, writer, datasets.DumpOptions{Format: format})
+		}
+
+		data := make([]vectors.Vector, len(points))
+		for i, p := range points {
+			data[i] = p.Vector
+		}
+
+		switch extension(path) {
+		case ".npy":
+			return datasets.WriteNpy(writer, data, datasets.Float32)
+		case ".npz":
+			return datasets.ExportNpz(flat, writer, datasets.Float32)
+		}
+
+		format, err := datasets.FormatOf(path)
+		if err != nil {
+			return err
+		}
+		vecsWriter := datasets.NewWriter(writer, format)
+		for _, v := range data {
+			err = vecsWriter.Write(v)
+			if err != nil {
+				return err
+			}
+		}
+		return vecsWriter.Flush()
+	})
+}
+
+func loadIndex(path string) (*hnsw.HnswCollection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	collection, err := hnsw.LoadHnswCollection(bufio.NewReader(f), nil)
+	if err != nil {
+		return nil, fmt.Errorf("loading %s: %w", path, err)
+	}
+	return collection, nil
+}
+
+func saveIndex(path string, collection *hnsw.HnswCollection) error {
+	return fsutil.WriteFileAtomic(path, collection.Save)
+}
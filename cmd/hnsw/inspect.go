@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+)
+
+func runStats(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := newFlagSet("stats")
+	index := flags.String("index", "", "index file")
+	asJSON := flags.Bool("json", false, "print JSON instead of tables")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	err = required(flags, "index")
+	if err != nil {
+		return err
+	}
+
+	collection, err := loadIndex(*index)
+	if err != nil {
+		return err
+	}
+	stats := collection.Stats()
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+
+	fmt.Fprintf(stdout, "points: %d\ndimension: %d\nconnectivity: %d\nprefetch: %d\nmemory: %.1f MiB\n\n",
+		stats.Points, stats.VectorDimension, stats.Connectivity, stats.PrefetchFactor, float64(stats.MemoryBytes)/(1<<20))
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "layer\tnodes\tedges\tmin degree\tavg degree\tmax degree\t")
+	for i, ls := range stats.Layers {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%.2f\t%d\t\n", i, ls.Nodes, ls.Edges, ls.MinDegree, ls.AvgDegree, ls.MaxDegree)
+	}
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	for i, ls := range stats.Layers {
+		fmt.Fprintf(stdout, "\nlayer %d degree histogram:\n", i)
+		degrees := make([]int, 0, len(ls.DegreeHistogram))
+		for d := range ls.DegreeHistogram {
+			degrees = append(degrees, d)
+		}
+		slices.Sort(degrees)
+		for _, d := range degrees {
+			fmt.Fprintf(stdout, "%6d %d\n", d, ls.DegreeHistogram[d])
+		}
+	}
+	return nil
+}
+
+func runVerify(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := newFlagSet("verify")
+	index := flags.String("index", "", "index file")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	err = required(flags, "index")
+	if err != nil {
+		return err
+	}
+
+	collection, err := loadIndex(*index)
+	if err != nil {
+		return err
+	}
+	err = collection.Verify()
+	if err != nil {
+		return fmt.Errorf("%s: %w", *index, err)
+	}
+
+	fmt.Fprintf(stdout, "%s: ok, %d points\n", *index, collection.Len())
+	return nil
+}
@@ -0,0 +1,91 @@
+// Command hnsw builds, queries and inspects HNSW index files.
+//
+// Usage:
+//
+//	hnsw <command> [flags]
+//
+// Commands:
+//
+//	build    build an index from .fvecs, .bvecs, .npy, .npz, .jsonl or .csv vectors
+//	query    search an index with vectors from a file or stdin, printing JSON lines
+//	stats    print layer sizes, degree histograms and memory usage
+//	verify   check the file checksum and the graph invariants
+//	bench    measure recall and QPS against ground truth
+//	dump     write the points of an index as JSON lines or CSV
+//	convert  convert vectors between the supported file formats
+//
+// Run "hnsw <command> -h" for the flags of a command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = []command{
+	{"build", "build an index from a vector file", runBuild},
+	{"query", "search an index with vectors from a file or stdin", runQuery},
+	{"stats", "print layer sizes, degree histograms and memory usage", runStats},
+	{"verify", "check the file checksum and the graph invariants", runVerify},
+	{"bench", "measure recall and QPS against ground truth", runBench},
+	{"dump", "write the points of an index as JSON lines or CSV", runDump},
+	{"convert", "convert vectors between file formats", runConvert},
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hnsw: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return flag.ErrHelp
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdin, stdout)
+		}
+	}
+
+	usage(os.Stderr)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func usage(writer io.Writer) {
+	fmt.Fprintln(writer, "Usage: hnsw <command> [flags]")
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(writer, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("hnsw "+name, flag.ContinueOnError)
+}
+
+func required(flags *flag.FlagSet, names ...string) error {
+	for _, name := range names {
+		if flags.Lookup(name).Value.String() == "" {
+			return fmt.Errorf("flag -%s is required", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-hnsw/hnsw/datasets"
+	"go-hnsw/hnsw/vectors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeVectors(t *testing.T, path string, n int, dimension int) []vectors.Vector {
+	data := make([]vectors.Vector, n)
+	for i := range data {
+		data[i] = make(vectors.Vector, dimension)
+		for j := range data[i] {
+			data[i][j] = vectors.VFloat(rand.NormFloat64())
+		}
+	}
+	err := datasets.WriteFile(path, data)
+	if err != nil {
+		t.Fatalf("Writing %s failed: %s", path, err)
+	}
+	return data
+}
+
+func runOK(t *testing.T, stdin string, args ...string) string {
+	stdout := new(bytes.Buffer)
+	err := run(args, strings.NewReader(stdin), stdout)
+	if err != nil {
+		t.Fatalf("'hnsw %s' returned error: %s", strings.Join(args, " "), err)
+	}
+	return stdout.String()
+}
+
+func TestBuildQueryInspect(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "base.fvecs")
+	index := filepath.Join(dir, "base.hnsw")
+	data := writeVectors(t, input, 300, 8)
+
+	out := runOK(t, "", "build", "-input", input, "-output", index, "-connectivity", "8")
+	if !strings.Contains(out, "300 points of dimension 8") {
+		t.Fatalf("Unexpected build output: %s", out)
+	}
+
+	out = runOK(t, "", "verify", "-index", index)
+	if !strings.Contains(out, "ok, 300 points") {
+		t.Fatalf("Unexpected verify output: %s", out)
+	}
+
+	out = runOK(t, "", "stats", "-index", index)
+	if !strings.Contains(out, "points: 300") || !strings.Contains(out, "degree histogram") {
+		t.Fatalf("Unexpected stats output: %s", out)
+	}
+
+	query, _ := json.Marshal(data[42])
+	out = runOK(t, string(query)+"\n", "query", "-index", index, "-k", "3")
+	var output queryOutput
+	err := json.Unmarshal([]byte(out), &output)
+	if err != nil || len(output.Results) != 3 || output.Results[0].Id != 42 {
+		t.Fatalf("Unexpected query output: %s", out)
+	}
+
+	out = runOK(t, "", "bench", "-index", index, "-queries", input, "-k", "5", "-json")
+	results := []map[string]any{}
+	err = json.Unmarshal([]byte(out), &results)
+	if err != nil || len(results) != 1 || results[0]["recall"].(float64) < 0.8 {
+		t.Fatalf("Unexpected bench output: %s", out)
+	}
+}
+
+func TestVerifyDetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "base.fvecs")
+	index := filepath.Join(dir, "base.hnsw")
+	writeVectors(t, input, 50, 4)
+	runOK(t, "", "build", "-input", input, "-output", index)
+
+	raw, _ := os.ReadFile(index)
+	raw[len(raw)/2] ^= 0xff
+	os.WriteFile(index, raw, 0o644)
+
+	err := run([]string{"verify", "-index", index}, nil, new(bytes.Buffer))
+	if err == nil {
+		t.Fatal("Verifying a corrupted index must fail")
+	}
+}
+
+func TestConvertAndDump(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "base.fvecs")
+	data := writeVectors(t, input, 20, 4)
+
+	npy := filepath.Join(dir, "base.npy")
+	jsonl := filepath.Join(dir, "base.jsonl")
+	runOK(t, "", "convert", "-input", input, "-output", npy)
+	runOK(t, "", "convert", "-input", npy, "-output", jsonl)
+
+	points, err := readPoints(jsonl, "")
+	if err != nil || len(points) != 20 {
+		t.Fatalf("Expected 20 converted points but found %d (%v)", len(points), err)
+	}
+	for i, p := range points {
+		if p.Id != uint64(i) || float32(p.Vector[1]) != float32(data[i][1]) {
+			t.Fatalf("Unexpected converted point %+v", p)
+		}
+	}
+
+	index := filepath.Join(dir, "base.hnsw")
+	runOK(t, "", "build", "-input", jsonl, "-output", index, "-binary")
+
+	out := runOK(t, "", "dump", "-index", index, "-format", "csv")
+	if !strings.HasPrefix(out, "id,key,encoding,value,v0,v1,v2,v3\n") || strings.Count(out, "\n") != 21 {
+		t.Fatalf("Unexpected dump output: %s", out)
+	}
+}
+
+func TestInvalidInputIsAnError(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "base.fvecs")
+	index := filepath.Join(dir, "base.hnsw")
+	writeVectors(t, input, 50, 4)
+	runOK(t, "", "build", "-input", input, "-output", index)
+
+	mixed := filepath.Join(dir, "mixed.jsonl")
+	os.WriteFile(mixed, []byte(`{"id":0,"vector":[1,2]}`+"\n"+`{"id":1,"vector":[1,2,3]}`+"\n"), 0o644)
+
+	for _, c := range []struct {
+		args  []string
+		stdin string
+	}{
+		{args: []string{"build", "-input", input, "-output", index, "-layers", "0"}},
+		{args: []string{"build", "-input", input, "-output", index, "-pq-subspaces", "3"}},
+		{args: []string{"build", "-input", input, "-output", index, "-pq-subspaces", "2", "-pq-centroids", "300"}},
+		{args: []string{"build", "-input", mixed, "-output", index}},
+		{args: []string{"query", "-index", index}, stdin: "[1, 2]\n"},
+		{args: []string{"query", "-index", index, "-k", "0"}, stdin: "[1, 2, 3, 4]\n"},
+		{args: []string{"bench", "-index", index, "-queries", mixed}},
+	} {
+		err := run(c.args, strings.NewReader(c.stdin), new(bytes.Buffer))
+		if err == nil {
+			t.Fatalf("'hnsw %s' expected to fail", strings.Join(c.args, " "))
+		}
+	}
+}
+
+func TestMixedDimensionsNameTheLine(t *testing.T) {
+	dir := t.TempDir()
+	jsonl := filepath.Join(dir, "mixed.jsonl")
+	os.WriteFile(jsonl, []byte(`{"id":0,"vector":[1,2]}`+"\n"+`{"id":1,"vector":[1,2,3]}`+"\n"), 0o644)
+	csv := filepath.Join(dir, "mixed.csv")
+	os.WriteFile(csv, []byte("id,key,encoding,value,v0,v1\n0,,,,1,2\n1,,,,1,2,3\n"), 0o644)
+
+	for input, line := range map[string]string{jsonl: "line 2", csv: "line 3"} {
+		args := []string{"convert", "-input", input, "-output", filepath.Join(dir, "out.npy")}
+		err := run(args, nil, new(bytes.Buffer))
+		if err == nil || !strings.Contains(err.Error(), line) {
+			t.Fatalf("'hnsw %s' expected to fail on %s but '%v' found", strings.Join(args, " "), line, err)
+		}
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	err := run([]string{"frobnicate"}, nil, new(bytes.Buffer))
+	if err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Fatalf("Expected unknown command error but '%v' found", err)
+	}
+}
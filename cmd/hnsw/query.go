@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/datasets"
+	"go-hnsw/hnsw/vectors"
+	"io"
+	"unicode/utf8"
+)
+
+type queryResult struct {
+	Id       uint64         `json:"id"`
+	Distance vectors.VFloat `json:"distance"`
+	Value    string         `json:"value,omitempty"`
+	Base64   []byte         `json:"value_base64,omitempty"`
+}
+
+type queryOutput struct {
+	Query   int           `json:"query"`
+	Results []queryResult `json:"results"`
+}
+
+func runQuery(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := newFlagSet("query")
+	index := flags.String("index", "", "index file")
+	input := flags.String("input", "-", "query vectors file, or - to read JSON arrays or dump records from stdin")
+	array := flags.String("array", "", "array name inside an .npz archive")
+	k := flags.Int("k", 10, "number of results per query")
+	oversampling := flags.Int("oversampling", 0, "quantized candidates re-scored per result")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	err = required(flags, "index")
+	if err != nil {
+		return err
+	}
+	if *k <= 0 {
+		return errors.New("-k must be > 0")
+	}
+	if *oversampling < 0 {
+		return errors.New("-oversampling must be >= 0")
+	}
+
+	collection, err := loadIndex(*index)
+	if err != nil {
+		return err
+	}
+
+	var queries []vectors.Vector
+	if *input == "-" {
+		queries, err = readQueries(stdin)
+	} else {
+		var points []hnsw.Point
+		points, err = readPoints(*input, *array)
+		for _, p := range points {
+			queries = append(queries, p.Vector)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	err = checkQueries(queries, collection.VectorDimension())
+	if err != nil {
+		return err
+	}
+
+	buffered := bufio.NewWriter(stdout)
+	encoder := json.NewEncoder(buffered)
+	for i, q := range queries {
+		res := collection.SearchWithOptions(q, *k, hnsw.SearchOptions{Oversampling: *oversampling})
+
+		output := queryOutput{Query: i, Results: make([]queryResult, len(res))}
+		for j, r := range res {
+			output.Results[j] = queryResult{Id: r.Id, Distance: r.Distance}
+			if utf8.Valid(r.Value) {
+				output.Results[j].Value = string(r.Value)
+			} else {
+				output.Results[j].Base64 = r.Value
+			}
+		}
+		err = encoder.Encode(output)
+		if err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
+
+// readQueries reads one query per line, either a JSON array of numbers or a
+// dump record with a "vector" field.
+func readQueries(reader io.Reader) ([]vectors.Vector, error) {
+	queries := []vectors.Vector{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line += 1 {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var vector vectors.Vector
+		var err error
+		if text[0] == '[' {
+			err = json.Unmarshal(text, &vector)
+		} else {
+			var record datasets.Record
+			err = json.Unmarshal(text, &record)
+			vector = record.Vector
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		queries = append(queries, vector)
+	}
+	return queries, scanner.Err()
+}
+
+func checkQueries(queries []vectors.Vector, dimension int) error {
+	for i, q := range queries {
+		if len(q) != dimension {
+			return fmt.Errorf("query %d has dimension %d, %d expected", i, len(q), dimension)
+		}
+	}
+	return nil
+}
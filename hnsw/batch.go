@@ -0,0 +1,88 @@
+package hnsw
+
+import (
+	"context"
+	"go-hnsw/hnsw/vectors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// searchScratch holds the buffers of a bottom layer search so that a worker
+// running many searches allocates them once.
+type searchScratch struct {
+	visited    map[uint64]bool
+	candidates candidateQueue
+	nearest    KClosestNodes
+	budget     *searchBudget
+}
+
+func newSearchScratch() *searchScratch {
+	return &searchScratch{visited: map[uint64]bool{}}
+}
+
+func (scratch *searchScratch) reset(k int, distance nodeDistance) *KClosestNodes {
+	clear(scratch.visited)
+	scratch.candidates = scratch.candidates[:0]
+	scratch.nearest = KClosestNodes{
+		nodes:     scratch.nearest.nodes[:0],
+		distances: scratch.nearest.distances[:0],
+		targetLen: k,
+		distance:  distance,
+	}
+	return &scratch.nearest
+}
+
+// SearchBatch runs the queries on a pool of one worker per CPU and returns
+// their results in query order. Every query gets the time and distance
+// budgets of opts, and partial reports the queries that ran out of them as
+// SearchContext does. It stops early with the context error when ctx is done,
+// also in the middle of a query.
+func (hnsw *HnswCollection) SearchBatch(ctx context.Context, queries []vectors.Vector, k int, opts SearchOptions) (results [][]SearchResult, partial []bool, err error) {
+	results = make([][]SearchResult, len(queries))
+	partial = make([]bool, len(queries))
+	workers := min(runtime.NumCPU(), len(queries))
+
+	next := make(chan int)
+	var cancelled atomic.Bool
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scratch := newSearchScratch()
+			for i := range next {
+				scratch.budget = newSearchBudget(ctx, opts)
+				query := queries[i]
+				results[i] = toSearchResults(hnsw.nNearestWithOptions(scratch, query, k, opts), query, hnsw.Distance())
+				partial[i] = scratch.budget.partial
+				if scratch.budget.failed() {
+					cancelled.Store(true)
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range queries {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if err == nil && cancelled.Load() {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return results, partial, nil
+}
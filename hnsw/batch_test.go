@@ -0,0 +1,74 @@
+package hnsw
+
+import (
+	"context"
+	"errors"
+	"go-hnsw/hnsw/vectors/distances"
+	"testing"
+)
+
+func TestSearchBatchMatchesSearch(t *testing.T) {
+	hnsw := NewHnswCollection(3, 8, distances.Euclidian, 16, 4)
+	for _, v := range randomVectors(1000, 8) {
+		hnsw.Add(v, nil)
+	}
+
+	queries := randomVectors(100, 8)
+	batch, partial, err := hnsw.SearchBatch(context.Background(), queries, 5, SearchOptions{Ef: 200})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(batch) != len(queries) {
+		t.Fatalf("Expected %d results but found %d", len(queries), len(batch))
+	}
+
+	for i, query := range queries {
+		single := hnsw.SearchWithOptions(query, 5, SearchOptions{Ef: 200})
+		if partial[i] {
+			t.Fatalf("Query %d: no budget was set but the results are partial", i)
+		}
+		if len(batch[i]) != len(single) {
+			t.Fatalf("Query %d: expected %d results but found %d", i, len(single), len(batch[i]))
+		}
+		for j := range single {
+			if batch[i][j].Distance != single[j].Distance {
+				t.Fatalf("Query %d: result %d differs from a single search", i, j)
+			}
+		}
+	}
+}
+
+func TestSearchBatchCancelled(t *testing.T) {
+	hnsw := NewHnswCollection(3, 8, distances.Euclidian, 16, 4)
+	for _, v := range randomVectors(100, 8) {
+		hnsw.Add(v, nil)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res, _, err := hnsw.SearchBatch(ctx, randomVectors(10, 8), 5, SearchOptions{})
+	if !errors.Is(err, context.Canceled) || res != nil {
+		t.Fatalf("Expected context.Canceled but found %v", err)
+	}
+}
+
+func TestSearchBatchBudgets(t *testing.T) {
+	hnsw := NewHnswCollection(3, 8, distances.Euclidian, 16, 4)
+	for _, v := range randomVectors(1000, 8) {
+		hnsw.Add(v, nil)
+	}
+
+	queries := randomVectors(10, 8)
+	for _, opts := range []SearchOptions{{DistanceBudget: 30}, {TimeBudget: 1}} {
+		_, partial, err := hnsw.SearchBatch(context.Background(), queries, 10, opts)
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		for i, p := range partial {
+			if !p {
+				t.Fatalf("Query %d: expected partial results with %+v", i, opts)
+			}
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package hnsw
+
+import (
+	"context"
+	"fmt"
+	"go-hnsw/hnsw/vectors"
+	"io"
+	"time"
+)
+
+// budgetCheckInterval is the number of expanded nodes between two checks of
+// the context and the clock.
+const budgetCheckInterval = 16
+
+// searchBudget stops a traversal when its context is done or it runs out of
+// time or distance computations.
+type searchBudget struct {
+	ctx          context.Context
+	deadline     time.Time
+	maxDistances int
+	computed     int
+	steps        int
+	// partial is set when the time or distance budget ran out and err when
+	// the context is done.
+	partial bool
+	err     error
+}
+
+func newSearchBudget(ctx context.Context, opts SearchOptions) *searchBudget {
+	budget := &searchBudget{ctx: ctx, maxDistances: opts.DistanceBudget}
+	if opts.TimeBudget > 0 {
+		budget.deadline = time.Now().Add(opts.TimeBudget)
+	}
+	return budget
+}
+
+func (budget *searchBudget) exhausted() bool {
+	if budget == nil {
+		return false
+	}
+	if budget.partial || budget.err != nil {
+		return true
+	}
+
+	if budget.maxDistances > 0 && budget.computed >= budget.maxDistances {
+		budget.partial = true
+		return true
+	}
+
+	budget.steps += 1
+	if budget.steps%budgetCheckInterval != 1 {
+		return false
+	}
+	if err := budget.ctx.Err(); err != nil {
+		budget.err = err
+		return true
+	}
+	if !budget.deadline.IsZero() && time.Now().After(budget.deadline) {
+		budget.partial = true
+		return true
+	}
+	return false
+}
+
+// spent reports whether the distance budget is used up. It is checked
+// before every distance computation, so the budget is never exceeded.
+func (budget *searchBudget) spent() bool {
+	if budget == nil || budget.maxDistances <= 0 || budget.computed < budget.maxDistances {
+		return false
+	}
+	budget.partial = true
+	return true
+}
+
+func (budget *searchBudget) failed() bool {
+	return budget != nil && budget.err != nil
+}
+
+func (scratch *searchScratch) stopped() bool {
+	return scratch != nil && scratch.budget.exhausted()
+}
+
+func (scratch *searchScratch) spent() bool {
+	return scratch != nil && scratch.budget.spent()
+}
+
+// count makes distance count its computations against the budget, if any.
+func (scratch *searchScratch) count(distance nodeDistance) nodeDistance {
+	if scratch == nil || scratch.budget == nil {
+		return distance
+	}
+	budget := scratch.budget
+	return func(node *Node) vectors.VFloat {
+		budget.computed += 1
+		return distance(node)
+	}
+}
+
+// SearchContext is SearchWithOptions that can be cancelled through ctx and
+// honors the time and distance budgets of opts. When a budget runs out it
+// returns the nearest points found so far with partial set.
+func (hnsw *HnswCollection) SearchContext(ctx context.Context, vector vectors.Vector, k int, opts SearchOptions) (results []SearchResult, partial bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	scratch := newSearchScratch()
+	scratch.budget = newSearchBudget(ctx, opts)
+	nodes := hnsw.nNearestWithOptions(scratch, vector, k, opts)
+	if scratch.budget.err != nil {
+		return nil, false, scratch.budget.err
+	}
+	return toSearchResults(nodes, vector, hnsw.Distance()), scratch.budget.partial, nil
+}
+
+// AddContext is Add that can be cancelled through ctx while it looks for the
+// neighbors of the point. A cancelled point is not added.
+func (hnsw *HnswCollection) AddContext(ctx context.Context, vector vectors.Vector, value []byte) (uint64, error) {
+	if len(vector) != hnsw.vectorDimension {
+		panic(fmt.Sprintf("Vector dimension must be %d", hnsw.vectorDimension))
+	}
+
+	hnsw.writeMu.Lock()
+	defer hnsw.writeMu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	id := hnsw.idCounter
+	err := hnsw.insertWith(&searchBudget{ctx: ctx}, id, vector, value)
+	if err != nil {
+		return 0, err
+	}
+	hnsw.idCounter += 1
+	return id, nil
+}
+
+// AddBatchContext is AddBatch that stops when ctx is done. It returns the
+// ids of the points added until then along with the context error.
+func (hnsw *HnswCollection) AddBatchContext(ctx context.Context, vectors []vectors.Vector, values [][]byte) ([]uint64, error) {
+	validateBatch(hnsw.vectorDimension, vectors, values)
+
+	ids := make([]uint64, 0, len(vectors))
+	for i, vector := range vectors {
+		id, err := hnsw.AddContext(ctx, vector, batchValue(values, i))
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// SaveContext is Save that stops writing when ctx is done.
+func (hnsw *HnswCollection) SaveContext(ctx context.Context, writer io.Writer) error {
+	return hnsw.Save(&contextWriter{ctx: ctx, writer: writer})
+}
+
+type contextWriter struct {
+	ctx    context.Context
+	writer io.Writer
+}
+
+func (w *contextWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.writer.Write(p)
+}
@@ -0,0 +1,127 @@
+package hnsw
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"testing"
+)
+
+func newBudgetCollection(n int) *HnswCollection {
+	hnsw := NewHnswCollection(3, 8, distances.Euclidian, 16, 4)
+	for _, v := range randomVectors(n, 8) {
+		hnsw.Add(v, nil)
+	}
+	return hnsw
+}
+
+func TestSearchContextBudgets(t *testing.T) {
+	hnsw := newBudgetCollection(1000)
+	query := randomVectors(1, 8)[0]
+
+	res, partial, err := hnsw.SearchContext(context.Background(), query, 10, SearchOptions{})
+	if err != nil || partial || len(res) != 10 {
+		t.Fatalf("Expected 10 complete results but found %d, partial %v, error %v", len(res), partial, err)
+	}
+
+	res, partial, err = hnsw.SearchContext(context.Background(), query, 10, SearchOptions{DistanceBudget: 30})
+	if err != nil || !partial {
+		t.Fatalf("Expected partial results but found partial %v, error %v", partial, err)
+	}
+	if len(res) == 0 || len(res) > 10 {
+		t.Fatalf("Expected the best results found so far but found %d", len(res))
+	}
+
+	_, partial, err = hnsw.SearchContext(context.Background(), query, 10, SearchOptions{TimeBudget: 1})
+	if err != nil || !partial {
+		t.Fatalf("Expected partial results but found partial %v, error %v", partial, err)
+	}
+
+	allowed := map[uint64]bool{}
+	for id := uint64(0); id < 100; id += 1 {
+		allowed[id] = true
+	}
+	_, partial, _ = hnsw.SearchContext(context.Background(), query, 10, SearchOptions{Allowed: allowed, Ef: 200, DistanceBudget: 10})
+	if !partial {
+		t.Fatalf("Exact search over allowed ids must honor the budget")
+	}
+}
+
+func TestDistanceBudgetIsABound(t *testing.T) {
+	computed := 0
+	counting := func(vector1, vector2 vectors.Vector) vectors.VFloat {
+		computed += 1
+		return distances.Euclidian(vector1, vector2)
+	}
+	hnsw := NewHnswCollection(3, 8, counting, 16, 4)
+	for _, v := range randomVectors(1000, 8) {
+		hnsw.Add(v, nil)
+	}
+	query := randomVectors(1, 8)[0]
+
+	for _, budget := range []int{1, 2, 5, 30} {
+		computed = 0
+		res, partial, err := hnsw.SearchContext(context.Background(), query, 10, SearchOptions{DistanceBudget: budget})
+		if err != nil || !partial || len(res) == 0 {
+			t.Fatalf("Expected partial results but found %d, partial %v, error %v", len(res), partial, err)
+		}
+		// The results are scored once more after the search.
+		if computed-len(res) > budget {
+			t.Fatalf("Search computed %d distances with a budget of %d", computed-len(res), budget)
+		}
+	}
+}
+
+func TestSearchContextCancelled(t *testing.T) {
+	hnsw := newBudgetCollection(100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res, _, err := hnsw.SearchContext(ctx, randomVectors(1, 8)[0], 10, SearchOptions{})
+	if !errors.Is(err, context.Canceled) || res != nil {
+		t.Fatalf("Expected context.Canceled but found %v", err)
+	}
+}
+
+func TestAddContext(t *testing.T) {
+	hnsw := newBudgetCollection(100)
+
+	id, err := hnsw.AddContext(context.Background(), randomVectors(1, 8)[0], nil)
+	if err != nil || id != 100 {
+		t.Fatalf("Expected id 100 but found %d, error %v", id, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	ids, err := hnsw.AddBatchContext(ctx, randomVectors(5, 8), nil)
+	if !errors.Is(err, context.Canceled) || len(ids) != 0 {
+		t.Fatalf("Expected context.Canceled but found %v, ids %v", err, ids)
+	}
+	if hnsw.Len() != 101 {
+		t.Fatalf("Cancelled points must not be added, found %d points", hnsw.Len())
+	}
+	if err := hnsw.Verify(); err != nil {
+		t.Fatalf("Graph is inconsistent after cancellation: %v", err)
+	}
+
+	ids, err = hnsw.AddBatchContext(context.Background(), randomVectors(5, 8), nil)
+	if err != nil || len(ids) != 5 || ids[0] != 101 {
+		t.Fatalf("Expected 5 ids from 101 but found %v, error %v", ids, err)
+	}
+}
+
+func TestSaveContextCancelled(t *testing.T) {
+	hnsw := newBudgetCollection(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	if err := hnsw.SaveContext(ctx, &buf); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled but found %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package hnsw
+
+import "context"
+
+// Compact rebuilds the graph from the live points. Removals leave the former
+// neighbors of a point with fewer links; a rebuild links them as if the
+// removed points had never been added. Ids, values, layers and quantization
+// of the points are kept.
+func (hnsw *HnswCollection) Compact() {
+	hnsw.CompactContext(context.Background())
+}
+
+// CompactContext is Compact that gives up when ctx is done, leaving the graph
+// as it was. Writers wait until it is done.
+func (hnsw *HnswCollection) CompactContext(ctx context.Context) error {
+	hnsw.writeMu.Lock()
+	defer hnsw.writeMu.Unlock()
+
+	compacted := NewHnswCollection(len(hnsw.layers), hnsw.vectorDimension, hnsw.bottomLayer().DistanceFnc, hnsw.connectivity, hnsw.prefetchFactor)
+	compacted.pq = hnsw.pq
+	compacted.pqCosine = hnsw.pqCosine
+	compacted.binary = hnsw.binary
+
+	budget := &searchBudget{ctx: ctx}
+	for _, node := range hnsw.bottomLayer().nodes {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		insertIdx := 0
+		for insertIdx < len(hnsw.layers)-1 {
+			if _, ok := hnsw.layers[insertIdx].Get(node.Id); ok {
+				break
+			}
+			insertIdx += 1
+		}
+		err = compacted.insertAt(budget, insertIdx, node.Id, node.Vector, node.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	hnsw.layers = compacted.layers
+	return nil
+}
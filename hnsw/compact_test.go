@@ -0,0 +1,64 @@
+package hnsw
+
+import (
+	"context"
+	"errors"
+	"go-hnsw/hnsw/vectors/distances"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	data := randomVectors(500, 8)
+	hnswCollection := NewHnswCollection(3, 8, distances.Euclidian, 8, 4)
+	for _, v := range data {
+		hnswCollection.Add(v, []byte{1})
+	}
+	for id := uint64(0); id < 500; id += 2 {
+		hnswCollection.Remove(id)
+	}
+
+	hnswCollection.Compact()
+
+	if err := hnswCollection.Verify(); err != nil {
+		t.Fatalf("Graph is inconsistent after compaction: %v", err)
+	}
+	if hnswCollection.Len() != 250 {
+		t.Fatalf("Expected 250 points after compaction but found %d", hnswCollection.Len())
+	}
+	found := 0
+	for id := uint64(1); id < 500; id += 2 {
+		point, ok := hnswCollection.Get(id)
+		if !ok || len(point.Value) != 1 {
+			t.Fatalf("Point %d was lost by the compaction", id)
+		}
+		res := hnswCollection.Search(data[id], 1)
+		if len(res) == 1 && res[0].Id == id {
+			found += 1
+		}
+	}
+	if found < 240 {
+		t.Fatalf("Only %d of 250 points find themselves after compaction", found)
+	}
+
+	id := hnswCollection.Add(randomVectors(1, 8)[0], nil)
+	if id != 500 {
+		t.Fatalf("Expected id 500 after compaction but found %d", id)
+	}
+}
+
+func TestCompactContextCancelled(t *testing.T) {
+	hnswCollection := newBudgetCollection(100)
+	layers := append([]*Layer(nil), hnswCollection.layers...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := hnswCollection.CompactContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled but found %v", err)
+	}
+	for i, layer := range hnswCollection.layers {
+		if layer != layers[i] {
+			t.Fatalf("Cancelled compaction replaced layer %d", i)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package datasets
+
+import (
+	"fmt"
+	"go-hnsw/hnsw/vectors"
+	"os"
+	"path/filepath"
+)
+
+// Dataset is a benchmark dataset: vectors to index, queries and optionally the
+// ids of the exact nearest neighbors of every query.
+type Dataset struct {
+	Train     []vectors.Vector
+	Queries   []vectors.Vector
+	Neighbors [][]uint64
+}
+
+// LoadDirectory reads a dataset stored as separate vector files. Both the
+// ann-benchmarks layout (train, test and neighbors files) and the TEXMEX
+// layout (*_base, *_query and *_groundtruth files) are recognized.
+func LoadDirectory(dir string) (Dataset, error) {
+	dataset := Dataset{}
+
+	train, err := findFile(dir, "train", "*_base")
+	if err != nil {
+		return dataset, err
+	}
+	queries, err := findFile(dir, "test", "*_query")
+	if err != nil {
+		return dataset, err
+	}
+	neighbors, _ := findFile(dir, "neighbors", "*_groundtruth")
+
+	dataset.Train, err = ReadFile(train)
+	if err != nil {
+		return dataset, err
+	}
+	dataset.Queries, err = ReadFile(queries)
+	if err != nil {
+		return dataset, err
+	}
+
+	if neighbors != "" {
+		f, err := os.Open(neighbors)
+		if err != nil {
+			return dataset, err
+		}
+		defer f.Close()
+
+		dataset.Neighbors, err = NewReader(f, Ivecs).ReadAllIds()
+		if err != nil {
+			return dataset, err
+		}
+	}
+	return dataset, nil
+}
+
+// SaveDirectory writes the dataset in the ann-benchmarks layout as
+// train.fvecs, test.fvecs and, when present, neighbors.ivecs.
+func SaveDirectory(dir string, dataset Dataset) error {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	err = WriteFile(filepath.Join(dir, "train.fvecs"), dataset.Train)
+	if err != nil {
+		return err
+	}
+	err = WriteFile(filepath.Join(dir, "test.fvecs"), dataset.Queries)
+	if err != nil {
+		return err
+	}
+	if dataset.Neighbors == nil {
+		return nil
+	}
+
+	f, err := os.Create(filepath.Join(dir, "neighbors.ivecs"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	writer := NewWriter(f, Ivecs)
+	for _, ids := range dataset.Neighbors {
+		err = writer.WriteIds(ids)
+		if err != nil {
+			return err
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func ReadFile(path string) ([]vectors.Vector, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return NewReader(f, format).ReadAll()
+}
+
+func WriteFile(path string, data []vectors.Vector) error {
+	format, err := FormatOf(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	writer := NewWriter(f, format)
+	for _, vector := range data {
+		err = writer.Write(vector)
+		if err != nil {
+			return err
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func findFile(dir string, names ...string) (string, error) {
+	for _, name := range names {
+		for _, ext := range []string{".fvecs", ".bvecs", ".ivecs"} {
+			matches, err := filepath.Glob(filepath.Join(dir, name+ext))
+			if err != nil {
+				return "", err
+			}
+			if len(matches) > 0 {
+				return matches[0], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no %v vector file found in %s", names, dir)
+}
@@ -0,0 +1,51 @@
+package datasets
+
+import (
+	"go-hnsw/hnsw/vectors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDirectoryRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	dataset := Dataset{
+		Train:     []vectors.Vector{{1, 2}, {3, 4}, {5, 6}},
+		Queries:   []vectors.Vector{{0, 0}},
+		Neighbors: [][]uint64{{0, 1}},
+	}
+
+	err := SaveDirectory(dir, dataset)
+	if err != nil {
+		t.Fatalf("'SaveDirectory' returned error: %s", err)
+	}
+
+	loaded, err := LoadDirectory(dir)
+	if err != nil {
+		t.Fatalf("'LoadDirectory' returned error: %s", err)
+	}
+	if !reflect.DeepEqual(loaded, dataset) {
+		t.Fatalf("Expected %+v but %+v found", dataset, loaded)
+	}
+}
+
+func TestLoadTexmexDirectory(t *testing.T) {
+	dir := t.TempDir()
+
+	WriteFile(filepath.Join(dir, "siftsmall_base.bvecs"), []vectors.Vector{{1, 2}, {3, 4}})
+	WriteFile(filepath.Join(dir, "siftsmall_query.fvecs"), []vectors.Vector{{0.5, 0.5}})
+
+	loaded, err := LoadDirectory(dir)
+	if err != nil {
+		t.Fatalf("'LoadDirectory' returned error: %s", err)
+	}
+	if len(loaded.Train) != 2 || len(loaded.Queries) != 1 || loaded.Neighbors != nil {
+		t.Fatalf("Unexpected dataset %+v", loaded)
+	}
+
+	os.Remove(filepath.Join(dir, "siftsmall_query.fvecs"))
+	if _, err = LoadDirectory(dir); err == nil {
+		t.Fatal("Loading a directory without queries must fail")
+	}
+}
@@ -0,0 +1,290 @@
+package datasets
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+type DumpFormat int
+
+const (
+	JSONLines DumpFormat = iota
+	CSV
+)
+
+type ValueEncoding string
+
+const (
+	// Auto writes values as UTF-8 when they are valid UTF-8 and as base64
+	// otherwise.
+	Auto   ValueEncoding = ""
+	UTF8   ValueEncoding = "utf8"
+	Base64 ValueEncoding = "base64"
+)
+
+const importBatchSize = 1024
+
+// Record is one point of a dump.
+type Record struct {
+	Id       uint64         `json:"id"`
+	Key      string         `json:"key,omitempty"`
+	Vector   vectors.Vector `json:"vector"`
+	Value    string         `json:"value,omitempty"`
+	Encoding ValueEncoding  `json:"encoding,omitempty"`
+}
+
+type DumpOptions struct {
+	Format   DumpFormat
+	Encoding ValueEncoding
+	// Key, when set, derives the optional key written with every point.
+	Key func(point hnsw.Point) string
+}
+
+type ImportResult struct {
+	Count int
+	// Keys maps the keys found in the dump to the ids of their points.
+	Keys map[string]uint64
+}
+
+func newRecord(point hnsw.Point, opts DumpOptions) Record {
+	record := Record{Id: point.Id, Vector: point.Vector}
+	if opts.Key != nil {
+		record.Key = opts.Key(point)
+	}
+	if len(point.Value) == 0 {
+		return record
+	}
+
+	record.Encoding = opts.Encoding
+	if record.Encoding == Auto {
+		record.Encoding = Base64
+		if utf8.Valid(point.Value) {
+			record.Encoding = UTF8
+		}
+	}
+	if record.Encoding == Base64 {
+		record.Value = base64.StdEncoding.EncodeToString(point.Value)
+	} else {
+		record.Value = string(point.Value)
+	}
+	return record
+}
+
+func (record Record) Point() (hnsw.Point, error) {
+	point := hnsw.Point{Id: record.Id, Vector: record.Vector}
+	switch record.Encoding {
+	case Base64:
+		value, err := base64.StdEncoding.DecodeString(record.Value)
+		if err != nil {
+			return point, fmt.Errorf("record %d: %w", record.Id, err)
+		}
+		point.Value = value
+	case UTF8, Auto:
+		if record.Value != "" {
+			point.Value = []byte(record.Value)
+		}
+	default:
+		return point, fmt.Errorf("record %d: unknown value encoding %q", record.Id, record.Encoding)
+	}
+	return point, nil
+}
+
+// Dump writes every point of the index as one JSON object per line or one CSV
+// row. CSV rows are id, key, encoding, value followed by one column per
+// vector component.
+func Dump(index hnsw.Index, writer io.Writer, opts DumpOptions) error {
+	buffered := bufio.NewWriter(writer)
+
+	var write func(record Record) error
+	flush := buffered.Flush
+	switch opts.Format {
+	case JSONLines:
+		encoder := json.NewEncoder(buffered)
+		write = func(record Record) error {
+			return encoder.Encode(record)
+		}
+	case CSV:
+		csvWriter := csv.NewWriter(buffered)
+		headerWritten := false
+		write = func(record Record) error {
+			if !headerWritten {
+				headerWritten = true
+				err := csvWriter.Write(csvHeader(len(record.Vector)))
+				if err != nil {
+					return err
+				}
+			}
+			return csvWriter.Write(csvRow(record))
+		}
+		flush = func() error {
+			csvWriter.Flush()
+			err := csvWriter.Error()
+			if err != nil {
+				return err
+			}
+			return buffered.Flush()
+		}
+	default:
+		return fmt.Errorf("unknown dump format %d", opts.Format)
+	}
+
+	var err error
+	index.Range(func(point hnsw.Point) bool {
+		err = write(newRecord(point, opts))
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// DumpReader streams the records of a dump.
+type DumpReader struct {
+	next func() (Record, error)
+	line int
+}
+
+func NewDumpReader(reader io.Reader, format DumpFormat) (*DumpReader, error) {
+	switch format {
+	case JSONLines:
+		decoder := json.NewDecoder(bufio.NewReader(reader))
+		r := &DumpReader{}
+		r.next = func() (Record, error) {
+			var record Record
+			r.line += 1
+			err := decoder.Decode(&record)
+			return record, err
+		}
+		return r, nil
+	case CSV:
+		csvReader := csv.NewReader(bufio.NewReader(reader))
+		csvReader.FieldsPerRecord = -1
+		headerRead := false
+		r := &DumpReader{}
+		r.next = func() (Record, error) {
+			if !headerRead {
+				headerRead = true
+				_, err := csvReader.Read()
+				if err != nil {
+					return Record{}, err
+				}
+			}
+			row, err := csvReader.Read()
+			if err != nil {
+				return Record{}, err
+			}
+			r.line, _ = csvReader.FieldPos(0)
+			return parseCSVRow(row)
+		}
+		return r, nil
+	}
+	return nil, fmt.Errorf("unknown dump format %d", format)
+}
+
+// Line returns the line of the last record read. JSON lines dumps are assumed
+// to hold one record per line.
+func (r *DumpReader) Line() int {
+	return r.line
+}
+
+// Read returns the next record and io.EOF after the last one.
+func (r *DumpReader) Read() (Record, error) {
+	record, err := r.next()
+	if errors.Is(err, io.EOF) {
+		return record, io.EOF
+	}
+	return record, err
+}
+
+// Import upserts every record of a dump into the index in batches, keeping
+// the ids of the dump.
+func Import(index hnsw.Index, reader io.Reader, format DumpFormat) (ImportResult, error) {
+	result := ImportResult{Keys: map[string]uint64{}}
+
+	dumpReader, err := NewDumpReader(reader, format)
+	if err != nil {
+		return result, err
+	}
+
+	batch := make([]hnsw.Point, 0, importBatchSize)
+	for {
+		record, err := dumpReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+
+		point, err := record.Point()
+		if err != nil {
+			return result, err
+		}
+		err = checkDimension(index, len(point.Vector))
+		if err != nil {
+			return result, fmt.Errorf("record %d: %w", result.Count+len(batch)+1, err)
+		}
+		if record.Key != "" {
+			result.Keys[record.Key] = record.Id
+		}
+
+		batch = append(batch, point)
+		if len(batch) == importBatchSize {
+			index.UpsertBatch(batch)
+			result.Count += len(batch)
+			batch = batch[:0]
+		}
+	}
+	index.UpsertBatch(batch)
+	result.Count += len(batch)
+
+	return result, nil
+}
+
+func csvHeader(dimension int) []string {
+	header := []string{"id", "key", "encoding", "value"}
+	for i := 0; i < dimension; i += 1 {
+		header = append(header, "v"+strconv.Itoa(i))
+	}
+	return header
+}
+
+func csvRow(record Record) []string {
+	row := []string{strconv.FormatUint(record.Id, 10), record.Key, string(record.Encoding), record.Value}
+	for _, v := range record.Vector {
+		row = append(row, strconv.FormatFloat(float64(v), 'g', -1, 64))
+	}
+	return row
+}
+
+func parseCSVRow(row []string) (Record, error) {
+	if len(row) < 4 {
+		return Record{}, fmt.Errorf("expected at least 4 columns but %d found", len(row))
+	}
+
+	id, err := strconv.ParseUint(row[0], 10, 64)
+	if err != nil {
+		return Record{}, err
+	}
+
+	record := Record{Id: id, Key: row[1], Encoding: ValueEncoding(row[2]), Value: row[3]}
+	record.Vector = make(vectors.Vector, len(row)-4)
+	for i, s := range row[4:] {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return Record{}, fmt.Errorf("record %d: %w", id, err)
+		}
+		record.Vector[i] = vectors.VFloat(v)
+	}
+	return record, nil
+}
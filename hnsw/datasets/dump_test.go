@@ -0,0 +1,101 @@
+package datasets
+
+import (
+	"bytes"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func dumpSource() *hnsw.FlatCollection {
+	source := hnsw.NewFlatCollection(3, distances.Euclidian)
+	source.Upsert(4, vectors.Vector{1, 2.5, -3}, []byte("text value"))
+	source.Upsert(9, vectors.Vector{0, 0, 1e-7}, []byte{0xff, 0x00, 0x10})
+	source.Upsert(12, vectors.Vector{7, 8, 9}, nil)
+	return source
+}
+
+func TestDumpImportRoundTrip(t *testing.T) {
+	for _, format := range []DumpFormat{JSONLines, CSV} {
+		source := dumpSource()
+
+		buff := new(bytes.Buffer)
+		err := Dump(source, buff, DumpOptions{
+			Format: format,
+			Key: func(point hnsw.Point) string {
+				return "key-" + string(rune('a'+point.Id))
+			},
+		})
+		if err != nil {
+			t.Fatalf("%d: 'Dump' returned error: %s", format, err)
+		}
+
+		target := hnsw.NewHnswCollection(2, 3, distances.Euclidian, 4, 2)
+		result, err := Import(target, buff, format)
+		if err != nil {
+			t.Fatalf("%d: 'Import' returned error: %s", format, err)
+		}
+
+		if result.Count != 3 || target.Len() != 3 {
+			t.Fatalf("%d: expected 3 imported points but %d found", format, target.Len())
+		}
+		if result.Keys["key-e"] != 4 {
+			t.Fatalf("%d: unexpected imported keys %v", format, result.Keys)
+		}
+
+		source.Range(func(expected hnsw.Point) bool {
+			point, ok := target.Get(expected.Id)
+			if !ok || !reflect.DeepEqual(point, expected) {
+				t.Fatalf("%d: expected %+v but %+v found", format, expected, point)
+			}
+			return true
+		})
+	}
+}
+
+func TestDumpEncodings(t *testing.T) {
+	buff := new(bytes.Buffer)
+	Dump(dumpSource(), buff, DumpOptions{Format: JSONLines})
+
+	lines := strings.Split(strings.TrimSpace(buff.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines but %d found", len(lines))
+	}
+	if !strings.Contains(lines[0], `"value":"text value","encoding":"utf8"`) {
+		t.Fatalf("Valid UTF-8 value must be written as text: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"value":"/wAQ","encoding":"base64"`) {
+		t.Fatalf("Binary value must be written as base64: %s", lines[1])
+	}
+	if strings.Contains(lines[2], `"value"`) {
+		t.Fatalf("Empty value must be omitted: %s", lines[2])
+	}
+
+	buff.Reset()
+	Dump(dumpSource(), buff, DumpOptions{Format: CSV, Encoding: Base64})
+	if !strings.HasPrefix(buff.String(), "id,key,encoding,value,v0,v1,v2\n4,,base64,dGV4dCB2YWx1ZQ==,1,2.5,-3\n") {
+		t.Fatalf("Unexpected CSV dump: %s", buff.String())
+	}
+}
+
+func TestImportRejectsMalformedRecords(t *testing.T) {
+	target := hnsw.NewFlatCollection(2, distances.Euclidian)
+
+	_, err := Import(target, strings.NewReader(`{"id":1,"vector":[1,2],"value":"!!","encoding":"base64"}`), JSONLines)
+	if err == nil {
+		t.Fatal("Invalid base64 value must fail the import")
+	}
+
+	_, err = Import(target, strings.NewReader("id,key,encoding,value,v0,v1\n1,,,,1,x\n"), CSV)
+	if err == nil {
+		t.Fatal("Invalid vector component must fail the import")
+	}
+
+	_, err = Import(target, strings.NewReader(`{"id":1,"vector":[1,2]}`+"\n"+`{"id":2,"vector":[1,2,3]}`), JSONLines)
+	if err == nil || !strings.Contains(err.Error(), "record 2") {
+		t.Fatalf("Wrong dimension must fail the import at record 2 but '%v' found", err)
+	}
+}
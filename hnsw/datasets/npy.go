@@ -0,0 +1,413 @@
+package datasets
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DType is the element type of a .npy array.
+type DType string
+
+const (
+	Float32 DType = "f4"
+	Float64 DType = "f8"
+	Int32   DType = "i4"
+	Int64   DType = "i8"
+	Uint32  DType = "u4"
+	Uint64  DType = "u8"
+)
+
+var (
+	npyMagic = []byte("\x93NUMPY")
+
+	descrPattern   = regexp.MustCompile(`'descr'\s*:\s*'([<>|=])([a-z]\d+)'`)
+	fortranPattern = regexp.MustCompile(`'fortran_order'\s*:\s*(True|False)`)
+	shapePattern   = regexp.MustCompile(`'shape'\s*:\s*\(([^)]*)\)`)
+)
+
+func (dtype DType) size() int {
+	switch dtype {
+	case Float32, Int32, Uint32:
+		return 4
+	case Float64, Int64, Uint64:
+		return 8
+	}
+	return 0
+}
+
+// NpyArray is a decoded .npy array. Values are kept in C order whatever the
+// order of the file.
+type NpyArray struct {
+	DType  DType
+	Shape  []int
+	values []float64
+	ids    []uint64
+}
+
+// Vectors returns the rows of a 2-D array, or a 1-D array as a single vector.
+func (array *NpyArray) Vectors() ([]vectors.Vector, error) {
+	if array.values == nil {
+		return nil, fmt.Errorf("array of type %s does not hold floats", array.DType)
+	}
+
+	rows, cols := 1, 0
+	switch len(array.Shape) {
+	case 1:
+		cols = array.Shape[0]
+	case 2:
+		rows, cols = array.Shape[0], array.Shape[1]
+	default:
+		return nil, fmt.Errorf("expected a 1-D or 2-D array but the shape is %v", array.Shape)
+	}
+
+	res := make([]vectors.Vector, rows)
+	for i := range res {
+		vector := make(vectors.Vector, cols)
+		for j := range vector {
+			vector[j] = vectors.VFloat(array.values[i*cols+j])
+		}
+		res[i] = vector
+	}
+	return res, nil
+}
+
+// Ids returns the elements of a 1-D integer array.
+func (array *NpyArray) Ids() ([]uint64, error) {
+	if array.ids == nil || len(array.Shape) != 1 {
+		return nil, fmt.Errorf("expected a 1-D integer array but found %s array of shape %v", array.DType, array.Shape)
+	}
+	return array.ids, nil
+}
+
+// npyChunk is the number of elements read at a time, so a header claiming
+// more data than there is fails when the data runs out instead of
+// allocating for all of it first.
+const npyChunk = 1 << 16
+
+// ReadNpy decodes a .npy array. When reader is a file, the array must fit
+// in its size.
+func ReadNpy(reader io.Reader) (*NpyArray, error) {
+	limit := int64(-1)
+	if f, ok := reader.(*os.File); ok {
+		info, err := f.Stat()
+		if err == nil && info.Mode().IsRegular() {
+			limit = info.Size()
+		}
+	}
+	return readNpy(reader, limit)
+}
+
+// readNpy is ReadNpy for a reader holding at most limit bytes, or an unknown
+// number of bytes when limit is negative.
+func readNpy(reader io.Reader, limit int64) (*NpyArray, error) {
+	reader = bufio.NewReader(reader)
+
+	preamble := make([]byte, len(npyMagic)+2)
+	_, err := io.ReadFull(reader, preamble)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(preamble[:len(npyMagic)], npyMagic) {
+		return nil, errors.New("not a .npy file")
+	}
+
+	var headerLen int
+	consumed := int64(len(preamble))
+	switch major := preamble[len(npyMagic)]; major {
+	case 1:
+		var l uint16
+		err = binary.Read(reader, binary.LittleEndian, &l)
+		headerLen = int(l)
+		consumed += 2
+	case 2, 3:
+		var l uint32
+		err = binary.Read(reader, binary.LittleEndian, &l)
+		headerLen = int(l)
+		consumed += 4
+	default:
+		return nil, fmt.Errorf("unsupported .npy version %d", major)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if limit >= 0 && int64(headerLen) > limit-consumed {
+		return nil, fmt.Errorf(".npy header of %d bytes is larger than the file", headerLen)
+	}
+
+	header := make([]byte, headerLen)
+	_, err = io.ReadFull(reader, header)
+	if err != nil {
+		return nil, err
+	}
+	consumed += int64(headerLen)
+
+	array, byteOrder, fortranOrder, err := parseNpyHeader(string(header))
+	if err != nil {
+		return nil, err
+	}
+
+	size := array.DType.size()
+	count := 1
+	for _, d := range array.Shape {
+		if d != 0 && count > math.MaxInt/size/d {
+			return nil, fmt.Errorf(".npy shape %v is too large", array.Shape)
+		}
+		count *= d
+	}
+	if limit >= 0 && int64(count*size) > limit-consumed {
+		return nil, fmt.Errorf(".npy shape %v needs %d bytes but only %d remain", array.Shape, count*size, limit-consumed)
+	}
+
+	isFloat := array.DType == Float32 || array.DType == Float64
+	var values []float64
+	var ids []uint64
+	if isFloat {
+		values = make([]float64, 0, min(count, npyChunk))
+	} else {
+		ids = make([]uint64, 0, min(count, npyChunk))
+	}
+
+	data := make([]byte, min(count, npyChunk)*size)
+	for read := 0; read < count; {
+		n := min(count-read, npyChunk)
+		chunk := data[:n*size]
+		_, err = io.ReadFull(reader, chunk)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		for i := 0; i < n; i += 1 {
+			raw := chunk[i*size : (i+1)*size]
+			switch array.DType {
+			case Float32:
+				values = append(values, float64(math.Float32frombits(byteOrder.Uint32(raw))))
+			case Float64:
+				values = append(values, math.Float64frombits(byteOrder.Uint64(raw)))
+			case Int32:
+				ids = append(ids, uint64(int32(byteOrder.Uint32(raw))))
+			case Uint32:
+				ids = append(ids, uint64(byteOrder.Uint32(raw)))
+			case Int64, Uint64:
+				ids = append(ids, byteOrder.Uint64(raw))
+			}
+		}
+		read += n
+	}
+
+	if fortranOrder && len(array.Shape) >= 2 {
+		order := cOrderIndex(array.Shape, fortranOrder)
+		if isFloat {
+			values = reorder(values, order)
+		} else {
+			ids = reorder(ids, order)
+		}
+	}
+	array.values, array.ids = values, ids
+	return array, nil
+}
+
+func reorder[T any](elements []T, order func(i int) int) []T {
+	res := make([]T, len(elements))
+	for i, e := range elements {
+		res[order(i)] = e
+	}
+	return res
+}
+
+func parseNpyHeader(header string) (*NpyArray, binary.ByteOrder, bool, error) {
+	descr := descrPattern.FindStringSubmatch(header)
+	fortran := fortranPattern.FindStringSubmatch(header)
+	shape := shapePattern.FindStringSubmatch(header)
+	if descr == nil || fortran == nil || shape == nil {
+		return nil, nil, false, fmt.Errorf("malformed .npy header %q", header)
+	}
+
+	array := &NpyArray{DType: DType(descr[2])}
+	if array.DType.size() == 0 {
+		return nil, nil, false, fmt.Errorf("unsupported .npy dtype %q", descr[1]+descr[2])
+	}
+
+	var byteOrder binary.ByteOrder = binary.LittleEndian
+	if descr[1] == ">" {
+		byteOrder = binary.BigEndian
+	}
+
+	for _, d := range strings.Split(shape[1], ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 0 {
+			return nil, nil, false, fmt.Errorf("malformed .npy shape %q", shape[1])
+		}
+		array.Shape = append(array.Shape, n)
+	}
+
+	return array, byteOrder, fortran[1] == "True", nil
+}
+
+// cOrderIndex maps the position of an element in the file to its position in
+// C order.
+func cOrderIndex(shape []int, fortranOrder bool) func(i int) int {
+	if !fortranOrder || len(shape) < 2 {
+		return func(i int) int { return i }
+	}
+
+	strides := make([]int, len(shape))
+	stride := 1
+	for d := len(shape) - 1; d >= 0; d -= 1 {
+		strides[d] = stride
+		stride *= shape[d]
+	}
+
+	return func(i int) int {
+		index := 0
+		for d := 0; d < len(shape); d += 1 {
+			index += (i % shape[d]) * strides[d]
+			i /= shape[d]
+		}
+		return index
+	}
+}
+
+func writeNpyHeader(writer io.Writer, dtype DType, shape []int) error {
+	dims := make([]string, len(shape))
+	for i, d := range shape {
+		dims[i] = strconv.Itoa(d)
+	}
+	shapeStr := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		shapeStr += ","
+	}
+
+	header := fmt.Sprintf("{'descr': '<%s', 'fortran_order': False, 'shape': (%s), }", dtype, shapeStr)
+	prefixLen := len(npyMagic) + 2 + 2
+	padding := 64 - (prefixLen+len(header)+1)%64
+	if padding == 64 {
+		padding = 0
+	}
+	header += strings.Repeat(" ", padding) + "\n"
+
+	_, err := writer.Write(append(npyMagic, 1, 0))
+	if err != nil {
+		return err
+	}
+	err = binary.Write(writer, binary.LittleEndian, uint16(len(header)))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(writer, header)
+	return err
+}
+
+// WriteNpy stores the vectors as a 2-D C-ordered Float32 or Float64 array.
+func WriteNpy(writer io.Writer, data []vectors.Vector, dtype DType) error {
+	if dtype != Float32 && dtype != Float64 {
+		return fmt.Errorf("vectors can only be written as %s or %s but %s requested", Float32, Float64, dtype)
+	}
+
+	dimension := 0
+	if len(data) > 0 {
+		dimension = len(data[0])
+	}
+
+	buffered := bufio.NewWriter(writer)
+	err := writeNpyHeader(buffered, dtype, []int{len(data), dimension})
+	if err != nil {
+		return err
+	}
+
+	buff := make([]byte, 8)
+	for _, vector := range data {
+		if len(vector) != dimension {
+			return fmt.Errorf("all vectors must have dimension %d", dimension)
+		}
+		for _, v := range vector {
+			if dtype == Float32 {
+				binary.LittleEndian.PutUint32(buff, math.Float32bits(float32(v)))
+				_, err = buffered.Write(buff[:4])
+			} else {
+				binary.LittleEndian.PutUint64(buff, math.Float64bits(float64(v)))
+				_, err = buffered.Write(buff)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return buffered.Flush()
+}
+
+// WriteNpyIds stores ids as a 1-D Uint64 array.
+func WriteNpyIds(writer io.Writer, ids []uint64) error {
+	buffered := bufio.NewWriter(writer)
+	err := writeNpyHeader(buffered, Uint64, []int{len(ids)})
+	if err != nil {
+		return err
+	}
+	err = binary.Write(buffered, binary.LittleEndian, ids)
+	if err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// LoadNpy bulk inserts the rows of a .npy array into the index.
+func LoadNpy(index hnsw.Index, reader io.Reader) ([]uint64, error) {
+	array, err := ReadNpy(reader)
+	if err != nil {
+		return nil, err
+	}
+	return loadArray(index, array)
+}
+
+func loadArray(index hnsw.Index, array *NpyArray) ([]uint64, error) {
+	if len(array.Shape) > 0 {
+		err := checkDimension(index, array.Shape[len(array.Shape)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	data, err := array.Vectors()
+	if err != nil {
+		return nil, err
+	}
+	return index.AddBatch(data, nil), nil
+}
+
+// ExportNpy writes the vectors of the index to vectorsWriter and their ids,
+// in the same order, to idsWriter.
+func ExportNpy(index hnsw.Index, vectorsWriter io.Writer, idsWriter io.Writer, dtype DType) error {
+	data, ids := collect(index)
+
+	err := WriteNpy(vectorsWriter, data, dtype)
+	if err != nil {
+		return err
+	}
+	return WriteNpyIds(idsWriter, ids)
+}
+
+func collect(index hnsw.Index) ([]vectors.Vector, []uint64) {
+	data := make([]vectors.Vector, 0, index.Len())
+	ids := make([]uint64, 0, index.Len())
+	index.Range(func(point hnsw.Point) bool {
+		data = append(data, point.Vector)
+		ids = append(ids, point.Id)
+		return true
+	})
+	return data, ids
+}
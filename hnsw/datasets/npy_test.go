@@ -0,0 +1,201 @@
+package datasets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func rawNpy(header string, data any) []byte {
+	buff := new(bytes.Buffer)
+	buff.Write(npyMagic)
+	buff.Write([]byte{1, 0})
+	binary.Write(buff, binary.LittleEndian, uint16(len(header)))
+	buff.WriteString(header)
+	binary.Write(buff, binary.LittleEndian, data)
+	return buff.Bytes()
+}
+
+func TestReadNpyOrders(t *testing.T) {
+	expected := []vectors.Vector{{1, 2, 3}, {4, 5, 6}}
+
+	cOrder := rawNpy("{'descr': '<f4', 'fortran_order': False, 'shape': (2, 3), }\n", []float32{1, 2, 3, 4, 5, 6})
+	fOrder := rawNpy("{'descr': '<f8', 'fortran_order': True, 'shape': (2, 3), }\n", []float64{1, 4, 2, 5, 3, 6})
+
+	for _, raw := range [][]byte{cOrder, fOrder} {
+		array, err := ReadNpy(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("'ReadNpy' returned error: %s", err)
+		}
+		res, err := array.Vectors()
+		if err != nil {
+			t.Fatalf("'Vectors' returned error: %s", err)
+		}
+		if !reflect.DeepEqual(res, expected) {
+			t.Fatalf("Expected %v but %v found", expected, res)
+		}
+	}
+}
+
+func TestReadNpyBigEndian(t *testing.T) {
+	buff := new(bytes.Buffer)
+	header := "{'descr': '>f4', 'fortran_order': False, 'shape': (2,), }\n"
+	buff.Write(npyMagic)
+	buff.Write([]byte{1, 0})
+	binary.Write(buff, binary.LittleEndian, uint16(len(header)))
+	buff.WriteString(header)
+	binary.Write(buff, binary.BigEndian, []float32{1.5, -2})
+
+	array, err := ReadNpy(buff)
+	if err != nil {
+		t.Fatalf("'ReadNpy' returned error: %s", err)
+	}
+	res, _ := array.Vectors()
+	if !reflect.DeepEqual(res, []vectors.Vector{{1.5, -2}}) {
+		t.Fatalf("Unexpected vectors %v", res)
+	}
+}
+
+func TestWriteNpyRoundTrip(t *testing.T) {
+	data := []vectors.Vector{{1, 2}, {3, 4}, {5, 6}}
+
+	for _, dtype := range []DType{Float32, Float64} {
+		buff := new(bytes.Buffer)
+		err := WriteNpy(buff, data, dtype)
+		if err != nil {
+			t.Fatalf("'WriteNpy' returned error: %s", err)
+		}
+
+		headerEnd := bytes.IndexByte(buff.Bytes(), '\n') + 1
+		if headerEnd%64 != 0 {
+			t.Fatalf("Header must be padded to 64 bytes but ends at %d", headerEnd)
+		}
+
+		array, err := ReadNpy(buff)
+		if err != nil {
+			t.Fatalf("'ReadNpy' returned error: %s", err)
+		}
+		res, _ := array.Vectors()
+		if array.DType != dtype || !reflect.DeepEqual(res, data) {
+			t.Fatalf("Expected %v of %s but %v of %s found", data, dtype, res, array.DType)
+		}
+	}
+
+	if err := WriteNpy(new(bytes.Buffer), data, Int32); err == nil {
+		t.Fatal("Writing vectors as integers must fail")
+	}
+}
+
+func TestNpyCollectionRoundTrip(t *testing.T) {
+	source := hnsw.NewFlatCollection(2, distances.Euclidian)
+	source.AddBatch([]vectors.Vector{{1, 2}, {3, 4}, {5, 6}}, nil)
+	source.Remove(1)
+
+	vectorsBuff, idsBuff := new(bytes.Buffer), new(bytes.Buffer)
+	err := ExportNpy(source, vectorsBuff, idsBuff, Float64)
+	if err != nil {
+		t.Fatalf("'ExportNpy' returned error: %s", err)
+	}
+
+	idsArray, err := ReadNpy(idsBuff)
+	if err != nil {
+		t.Fatalf("'ReadNpy' returned error: %s", err)
+	}
+	ids, _ := idsArray.Ids()
+	if !reflect.DeepEqual(ids, []uint64{0, 2}) {
+		t.Fatalf("Unexpected exported ids %v", ids)
+	}
+
+	target := hnsw.NewHnswCollection(2, 2, distances.Euclidian, 4, 2)
+	loaded, err := LoadNpy(target, vectorsBuff)
+	if err != nil {
+		t.Fatalf("'LoadNpy' returned error: %s", err)
+	}
+	if len(loaded) != 2 || target.Len() != 2 {
+		t.Fatalf("Expected 2 loaded vectors but %d found", target.Len())
+	}
+}
+
+func TestLoadNpyWrongDimension(t *testing.T) {
+	buff := new(bytes.Buffer)
+	err := WriteNpy(buff, []vectors.Vector{{1, 2}, {3, 4}}, Float32)
+	if err != nil {
+		t.Fatalf("'WriteNpy' returned error: %s", err)
+	}
+
+	target := hnsw.NewHnswCollection(2, 3, distances.Euclidian, 4, 2)
+	if _, err := LoadNpy(target, buff); err == nil || target.Len() != 0 {
+		t.Fatalf("Loading 2 columns into a 3-D index must fail without adding vectors")
+	}
+}
+
+func TestReadNpyOversizedShape(t *testing.T) {
+	overflow := rawNpy("{'descr': '<f8', 'fortran_order': False, 'shape': (4294967296, 4294967296), }\n", []float64{1, 2})
+	if _, err := ReadNpy(bytes.NewReader(overflow)); err == nil {
+		t.Fatalf("A shape overflowing the element count must be rejected")
+	}
+
+	huge := rawNpy("{'descr': '<f8', 'fortran_order': False, 'shape': (1000000000, 128), }\n", []float64{1, 2})
+	if _, err := ReadNpy(bytes.NewReader(huge)); err == nil {
+		t.Fatalf("A shape larger than the data must fail")
+	}
+
+	path := filepath.Join(t.TempDir(), "huge.npy")
+	if err := os.WriteFile(path, huge, 0644); err != nil {
+		t.Fatalf("'WriteFile' returned error: %s", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("'Open' returned error: %s", err)
+	}
+	defer f.Close()
+	if _, err := ReadNpy(f); err == nil {
+		t.Fatalf("A shape larger than the file must be rejected")
+	}
+}
+
+func TestNpzRoundTrip(t *testing.T) {
+	source := hnsw.NewFlatCollection(3, distances.Euclidian)
+	source.AddBatch([]vectors.Vector{{1, 2, 3}, {4, 5, 6}}, nil)
+
+	path := filepath.Join(t.TempDir(), "collection.npz")
+	f, _ := os.Create(path)
+	err := ExportNpz(source, f, Float32)
+	f.Close()
+	if err != nil {
+		t.Fatalf("'ExportNpz' returned error: %s", err)
+	}
+
+	npz, err := OpenNpz(path)
+	if err != nil {
+		t.Fatalf("'OpenNpz' returned error: %s", err)
+	}
+	if !reflect.DeepEqual(npz.Names(), []string{"ids", "vectors"}) {
+		t.Fatalf("Unexpected arrays %v", npz.Names())
+	}
+	npz.Close()
+
+	target := hnsw.NewFlatCollection(3, distances.Euclidian)
+	_, err = LoadNpz(target, path, "vectors")
+	if err != nil {
+		t.Fatalf("'LoadNpz' returned error: %s", err)
+	}
+
+	point, _ := target.Get(1)
+	if !reflect.DeepEqual(point.Vector, vectors.Vector{4, 5, 6}) {
+		t.Fatalf("Unexpected loaded vector %v", point.Vector)
+	}
+
+	if _, err = LoadNpz(target, path, "missing"); err == nil {
+		t.Fatal("Loading a missing array must fail")
+	}
+	if _, err = LoadNpz(hnsw.NewFlatCollection(2, distances.Euclidian), path, "vectors"); err == nil {
+		t.Fatal("Loading into an index of another dimension must fail")
+	}
+}
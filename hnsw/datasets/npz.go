@@ -0,0 +1,101 @@
+package datasets
+
+import (
+	"archive/zip"
+	"fmt"
+	"go-hnsw/hnsw"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Npz is an opened .npz archive: a zip file with one .npy file per array.
+type Npz struct {
+	reader *zip.ReadCloser
+	files  map[string]*zip.File
+}
+
+func OpenNpz(path string) (*Npz, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	npz := &Npz{reader: reader, files: map[string]*zip.File{}}
+	for _, f := range reader.File {
+		npz.files[strings.TrimSuffix(f.Name, ".npy")] = f
+	}
+	return npz, nil
+}
+
+func (npz *Npz) Names() []string {
+	names := make([]string, 0, len(npz.files))
+	for name := range npz.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (npz *Npz) Array(name string) (*NpyArray, error) {
+	f, ok := npz.files[name]
+	if !ok {
+		return nil, fmt.Errorf("array %q not found in archive", name)
+	}
+
+	reader, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return readNpy(reader, int64(min(f.UncompressedSize64, math.MaxInt64)))
+}
+
+func (npz *Npz) Close() error {
+	return npz.reader.Close()
+}
+
+// LoadNpz bulk inserts the rows of the named array into the index.
+func LoadNpz(index hnsw.Index, path string, name string) ([]uint64, error) {
+	npz, err := OpenNpz(path)
+	if err != nil {
+		return nil, err
+	}
+	defer npz.Close()
+
+	array, err := npz.Array(name)
+	if err != nil {
+		return nil, err
+	}
+	return loadArray(index, array)
+}
+
+// ExportNpz writes the index as an archive with a "vectors" and an "ids"
+// array, readable with numpy.load.
+func ExportNpz(index hnsw.Index, writer io.Writer, dtype DType) error {
+	data, ids := collect(index)
+
+	archive := zip.NewWriter(writer)
+
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: "vectors.npy", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	err = WriteNpy(w, data, dtype)
+	if err != nil {
+		return err
+	}
+
+	w, err = archive.CreateHeader(&zip.FileHeader{Name: "ids.npy", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	err = WriteNpyIds(w, ids)
+	if err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
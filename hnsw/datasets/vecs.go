@@ -0,0 +1,278 @@
+package datasets
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"io"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Format is one of the TEXMEX vector file layouts. Every record is a little
+// endian int32 dimension followed by that many float32, int32 or uint8
+// components.
+type Format int
+
+const (
+	Fvecs Format = iota
+	Ivecs
+	Bvecs
+)
+
+func (format Format) componentSize() int {
+	if format == Bvecs {
+		return 1
+	}
+	return 4
+}
+
+func (format Format) String() string {
+	switch format {
+	case Fvecs:
+		return "fvecs"
+	case Ivecs:
+		return "ivecs"
+	case Bvecs:
+		return "bvecs"
+	}
+	return fmt.Sprintf("Format(%d)", int(format))
+}
+
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".fvecs":
+		return Fvecs, nil
+	case ".ivecs":
+		return Ivecs, nil
+	case ".bvecs":
+		return Bvecs, nil
+	}
+	return 0, fmt.Errorf("unknown vector file extension %q", filepath.Ext(path))
+}
+
+// MaxDimension bounds the dimension a record may declare.
+const MaxDimension = 1 << 20
+
+// vecsChunk is the number of bytes read at a time, so a record claiming more
+// components than the file holds fails when the data runs out instead of
+// allocating for all of it first.
+const vecsChunk = 1 << 16
+
+type Reader struct {
+	reader    *bufio.Reader
+	format    Format
+	buff      []byte
+	dimension int
+}
+
+func NewReader(reader io.Reader, format Format) *Reader {
+	return &Reader{reader: bufio.NewReader(reader), format: format, dimension: -1}
+}
+
+// readRecord reads the next record into r.buff. With uniform set, the record
+// must have the dimension of the first one.
+func (r *Reader) readRecord(uniform bool) (int, error) {
+	var dimension int32
+	err := binary.Read(r.reader, binary.LittleEndian, &dimension)
+	if err != nil {
+		return 0, err
+	}
+	if dimension < 0 || dimension > MaxDimension {
+		return 0, fmt.Errorf("invalid %s record dimension %d", r.format, dimension)
+	}
+	if uniform && r.dimension >= 0 && int(dimension) != r.dimension {
+		return 0, fmt.Errorf("%s record dimension %d differs from the first record's %d", r.format, dimension, r.dimension)
+	}
+
+	size := int(dimension) * r.format.componentSize()
+	r.buff = r.buff[:0]
+	for len(r.buff) < size {
+		n := min(size-len(r.buff), vecsChunk)
+		r.buff = slices.Grow(r.buff, n)
+		_, err = io.ReadFull(r.reader, r.buff[len(r.buff):len(r.buff)+n])
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		r.buff = r.buff[:len(r.buff)+n]
+	}
+	if uniform {
+		r.dimension = int(dimension)
+	}
+	return int(dimension), nil
+}
+
+// Read returns the next record as a vector and io.EOF after the last one.
+func (r *Reader) Read() (vectors.Vector, error) {
+	dimension, err := r.readRecord(true)
+	if err != nil {
+		return nil, err
+	}
+
+	vector := make(vectors.Vector, dimension)
+	for i := range vector {
+		switch r.format {
+		case Fvecs:
+			vector[i] = vectors.VFloat(math.Float32frombits(binary.LittleEndian.Uint32(r.buff[i*4:])))
+		case Ivecs:
+			vector[i] = vectors.VFloat(int32(binary.LittleEndian.Uint32(r.buff[i*4:])))
+		case Bvecs:
+			vector[i] = vectors.VFloat(r.buff[i])
+		}
+	}
+	return vector, nil
+}
+
+// ReadIds returns the next ivecs record as a list of ids, the layout used by
+// ground truth files.
+func (r *Reader) ReadIds() ([]uint64, error) {
+	if r.format != Ivecs {
+		return nil, fmt.Errorf("ids can only be read from ivecs but the format is %s", r.format)
+	}
+
+	// Result lists may be shorter than k, so their lengths can differ.
+	dimension, err := r.readRecord(false)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint64, dimension)
+	for i := range ids {
+		ids[i] = uint64(binary.LittleEndian.Uint32(r.buff[i*4:]))
+	}
+	return ids, nil
+}
+
+func (r *Reader) ReadAll() ([]vectors.Vector, error) {
+	res := []vectors.Vector{}
+	for {
+		vector, err := r.Read()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, err
+		}
+		res = append(res, vector)
+	}
+}
+
+func (r *Reader) ReadAllIds() ([][]uint64, error) {
+	res := [][]uint64{}
+	for {
+		ids, err := r.ReadIds()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return res, err
+		}
+		res = append(res, ids)
+	}
+}
+
+type Writer struct {
+	writer *bufio.Writer
+	format Format
+	buff   []byte
+}
+
+func NewWriter(writer io.Writer, format Format) *Writer {
+	return &Writer{writer: bufio.NewWriter(writer), format: format}
+}
+
+func (w *Writer) writeRecord(dimension int, put func(buff []byte)) error {
+	size := 4 + dimension*w.format.componentSize()
+	if cap(w.buff) < size {
+		w.buff = make([]byte, size)
+	}
+	w.buff = w.buff[:size]
+
+	binary.LittleEndian.PutUint32(w.buff, uint32(dimension))
+	put(w.buff[4:])
+
+	_, err := w.writer.Write(w.buff)
+	return err
+}
+
+// Write appends a vector. Components are converted to the writer's format,
+// so ivecs truncates and bvecs truncates and clamps them to [0, 255].
+func (w *Writer) Write(vector vectors.Vector) error {
+	return w.writeRecord(len(vector), func(buff []byte) {
+		for i, v := range vector {
+			switch w.format {
+			case Fvecs:
+				binary.LittleEndian.PutUint32(buff[i*4:], math.Float32bits(float32(v)))
+			case Ivecs:
+				binary.LittleEndian.PutUint32(buff[i*4:], uint32(int32(v)))
+			case Bvecs:
+				buff[i] = byte(min(max(v, 0), 255))
+			}
+		}
+	})
+}
+
+func (w *Writer) WriteIds(ids []uint64) error {
+	if w.format != Ivecs {
+		return fmt.Errorf("ids can only be written to ivecs but the format is %s", w.format)
+	}
+	return w.writeRecord(len(ids), func(buff []byte) {
+		for i, id := range ids {
+			binary.LittleEndian.PutUint32(buff[i*4:], uint32(id))
+		}
+	})
+}
+
+// WriteResults stores the ids of every result list as one ivecs record.
+func (w *Writer) WriteResults(results [][]hnsw.SearchResult) error {
+	for _, res := range results {
+		ids := make([]uint64, len(res))
+		for i, r := range res {
+			ids[i] = r.Id
+		}
+		err := w.WriteIds(ids)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) Flush() error {
+	return w.writer.Flush()
+}
+
+// Load adds every vector of the reader to the index and returns the ids the
+// index assigned, in file order.
+func Load(index hnsw.Index, reader *Reader) ([]uint64, error) {
+	ids := []uint64{}
+	for {
+		vector, err := reader.Read()
+		if err == io.EOF {
+			return ids, nil
+		}
+		if err != nil {
+			return ids, err
+		}
+		err = checkDimension(index, len(vector))
+		if err != nil {
+			return ids, fmt.Errorf("vector %d: %w", len(ids), err)
+		}
+		ids = append(ids, index.Add(vector, nil))
+	}
+}
+
+func checkDimension(index hnsw.Index, dimension int) error {
+	if dimension != index.VectorDimension() {
+		return fmt.Errorf("dimension %d does not match the index dimension %d", dimension, index.VectorDimension())
+	}
+	return nil
+}
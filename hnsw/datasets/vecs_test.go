@@ -0,0 +1,141 @@
+package datasets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestVecsRoundTrip(t *testing.T) {
+	data := []vectors.Vector{{1, 2, 3}, {4.5, -6, 7}, {255, 0, 8}}
+
+	for _, format := range []Format{Fvecs, Ivecs, Bvecs} {
+		buff := new(bytes.Buffer)
+		writer := NewWriter(buff, format)
+		for _, v := range data {
+			writer.Write(v)
+		}
+		writer.Flush()
+
+		expectedSize := len(data) * (4 + 3*format.componentSize())
+		if buff.Len() != expectedSize {
+			t.Fatalf("%s: file size expected to be %d but %d found", format, expectedSize, buff.Len())
+		}
+
+		res, err := NewReader(buff, format).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: 'ReadAll' returned error: %s", format, err)
+		}
+
+		expected := data
+		switch format {
+		case Ivecs:
+			expected = []vectors.Vector{{1, 2, 3}, {4, -6, 7}, {255, 0, 8}}
+		case Bvecs:
+			expected = []vectors.Vector{{1, 2, 3}, {4, 0, 7}, {255, 0, 8}}
+		}
+		if !reflect.DeepEqual(res, expected) {
+			t.Fatalf("%s: expected %v but %v found", format, expected, res)
+		}
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	buff := new(bytes.Buffer)
+	writer := NewWriter(buff, Fvecs)
+	writer.Write(vectors.Vector{1, 2, 3})
+	writer.Flush()
+
+	_, err := NewReader(bytes.NewReader(buff.Bytes()[:10]), Fvecs).Read()
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected unexpected EOF error but '%v' found", err)
+	}
+}
+
+func TestReadInvalidDimension(t *testing.T) {
+	buff := new(bytes.Buffer)
+	writer := NewWriter(buff, Fvecs)
+	writer.Write(vectors.Vector{1, 2, 3})
+	writer.Write(vectors.Vector{1, 2})
+	writer.Flush()
+
+	reader := NewReader(bytes.NewReader(buff.Bytes()), Fvecs)
+	if _, err := reader.Read(); err != nil {
+		t.Fatalf("'Read' returned error: %s", err)
+	}
+	if _, err := reader.Read(); err == nil {
+		t.Fatalf("A record with a different dimension must be rejected")
+	}
+
+	huge := binary.LittleEndian.AppendUint32(nil, MaxDimension+1)
+	if _, err := NewReader(bytes.NewReader(huge), Fvecs).Read(); err == nil {
+		t.Fatalf("A dimension above MaxDimension must be rejected")
+	}
+
+	lying := binary.LittleEndian.AppendUint32(nil, MaxDimension)
+	if _, err := NewReader(bytes.NewReader(lying), Fvecs).Read(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected unexpected EOF error but '%v' found", err)
+	}
+}
+
+func TestIdsRoundTrip(t *testing.T) {
+	buff := new(bytes.Buffer)
+	writer := NewWriter(buff, Ivecs)
+	writer.WriteResults([][]hnsw.SearchResult{{{Id: 3}, {Id: 1}}, {{Id: 7}}})
+	writer.Flush()
+
+	ids, err := NewReader(buff, Ivecs).ReadAllIds()
+	if err != nil {
+		t.Fatalf("'ReadAllIds' returned error: %s", err)
+	}
+	if !reflect.DeepEqual(ids, [][]uint64{{3, 1}, {7}}) {
+		t.Fatalf("Unexpected ids %v", ids)
+	}
+
+	if _, err = NewReader(buff, Fvecs).ReadIds(); err == nil {
+		t.Fatal("Reading ids from fvecs must fail")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	buff := new(bytes.Buffer)
+	writer := NewWriter(buff, Fvecs)
+	for i := 0; i < 10; i += 1 {
+		writer.Write(vectors.Vector{vectors.VFloat(i), 0})
+	}
+	writer.Flush()
+
+	collection := hnsw.NewHnswCollection(2, 2, distances.Euclidian, 4, 2)
+	ids, err := Load(collection, NewReader(buff, Fvecs))
+	if err != nil {
+		t.Fatalf("'Load' returned error: %s", err)
+	}
+
+	if len(ids) != 10 || collection.Len() != 10 {
+		t.Fatalf("Expected 10 loaded vectors but %d found", collection.Len())
+	}
+
+	res := collection.Search(vectors.Vector{4, 0}, 1)
+	if res[0].Id != ids[4] {
+		t.Fatalf("Nearest vector expected to be %d but %d found", ids[4], res[0].Id)
+	}
+}
+
+func TestLoadWrongDimension(t *testing.T) {
+	buff := new(bytes.Buffer)
+	writer := NewWriter(buff, Fvecs)
+	writer.Write(vectors.Vector{1, 2})
+	writer.Write(vectors.Vector{1, 2, 3})
+	writer.Flush()
+
+	collection := hnsw.NewHnswCollection(2, 2, distances.Euclidian, 4, 2)
+	ids, err := Load(collection, NewReader(buff, Fvecs))
+	if err == nil || len(ids) != 1 {
+		t.Fatalf("Expected an error after 1 loaded vector but %d loaded, error %v", len(ids), err)
+	}
+}
@@ -0,0 +1,465 @@
+// Package db hosts many named collections in one directory. Every collection
+// has its own write-ahead log and snapshot, and only a bounded number of them
+// is kept in memory at a time.
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors/distances"
+	"go-hnsw/hnsw/wal"
+	"go-hnsw/internal/fsutil"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+const (
+	metadataFile   = "metadata.json"
+	collectionsDir = "collections"
+	metadataFormat = 1
+)
+
+var (
+	ErrNotFound      = errors.New("collection not found")
+	ErrAlreadyExists = errors.New("collection already exists")
+	ErrInUse         = errors.New("collection in use")
+	ErrInvalidName   = errors.New("invalid collection name")
+	ErrClosed        = errors.New("db closed")
+
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// Config describes how a collection is built. Zero fields take the defaults
+// of DefaultConfig when the collection is created.
+type Config struct {
+	Dimension      int    `json:"dimension"`
+	Metric         string `json:"metric"`
+	Layers         int    `json:"layers"`
+	Connectivity   int    `json:"connectivity"`
+	PrefetchFactor int    `json:"prefetch_factor"`
+}
+
+var DefaultConfig = Config{Metric: "euclidian", Layers: 3, Connectivity: 16, PrefetchFactor: 4}
+
+func (config Config) withDefaults() Config {
+	if config.Metric == "" {
+		config.Metric = DefaultConfig.Metric
+	}
+	if config.Layers == 0 {
+		config.Layers = DefaultConfig.Layers
+	}
+	if config.Connectivity == 0 {
+		config.Connectivity = DefaultConfig.Connectivity
+	}
+	if config.PrefetchFactor == 0 {
+		config.PrefetchFactor = DefaultConfig.PrefetchFactor
+	}
+	return config
+}
+
+func (config Config) validate() error {
+	if config.Dimension <= 0 {
+		return errors.New("dimension must be > 0")
+	}
+	if config.Layers <= 0 || config.Connectivity <= 0 || config.PrefetchFactor <= 0 {
+		return errors.New("layers, connectivity and prefetch factor must be > 0")
+	}
+	if _, ok := distances.ByName(config.Metric); !ok {
+		return fmt.Errorf("unknown metric %q", config.Metric)
+	}
+	return nil
+}
+
+func (config Config) newCollection() *hnsw.HnswCollection {
+	distance, _ := distances.ByName(config.Metric)
+	return hnsw.NewHnswCollection(config.Layers, config.Dimension, distance, config.Connectivity, config.PrefetchFactor)
+}
+
+type Options struct {
+	// Log is used for the write-ahead log of every collection.
+	Log wal.Options
+	// MaxOpenCollections bounds how many collections are held in memory. The
+	// least recently used one is snapshotted and closed to make room. Zero
+	// means no limit.
+	MaxOpenCollections int
+}
+
+type metadata struct {
+	Format      int               `json:"format"`
+	Collections map[string]Config `json:"collections"`
+}
+
+type entry struct {
+	config     Config
+	collection *wal.Collection
+	pins       int
+	lastUsed   uint64
+	// evicting is closed when the snapshot and close of an evicted
+	// collection are done. It is nil when no eviction is running.
+	evicting chan struct{}
+	// loading is closed when the collection is loaded or failed to load. It
+	// is nil when no load is running.
+	loading chan struct{}
+}
+
+// busy returns the channel to wait on before using the entry, or nil.
+func (e *entry) busy() chan struct{} {
+	if e.evicting != nil {
+		return e.evicting
+	}
+	return e.loading
+}
+
+// DB is safe for concurrent use.
+type DB struct {
+	dir  string
+	opts Options
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	clock   uint64
+	closed  bool
+}
+
+// Open opens the database in dir, creating it when it does not exist.
+// Collections are only loaded when first used.
+func Open(dir string, opts Options) (*DB, error) {
+	err := os.MkdirAll(filepath.Join(dir, collectionsDir), 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := metadata{Format: metadataFormat, Collections: map[string]Config{}}
+	data, err := os.ReadFile(filepath.Join(dir, metadataFile))
+	if err == nil {
+		err = json.Unmarshal(data, &meta)
+		if err == nil && meta.Format != metadataFormat {
+			err = fmt.Errorf("unsupported metadata format %d", meta.Format)
+		}
+	} else if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading metadata: %w", err)
+	}
+
+	db := &DB{dir: dir, opts: opts, entries: map[string]*entry{}}
+	for name, config := range meta.Collections {
+		db.entries[name] = &entry{config: config}
+	}
+
+	err = db.removeOrphans()
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// removeOrphans deletes collection directories left behind by a crash in
+// the middle of Create or Drop.
+func (db *DB) removeOrphans() error {
+	dirs, err := os.ReadDir(filepath.Join(db.dir, collectionsDir))
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if _, ok := db.entries[dir.Name()]; ok {
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(db.dir, collectionsDir, dir.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) collectionDir(name string) string {
+	return filepath.Join(db.dir, collectionsDir, name)
+}
+
+// saveMetadata replaces the metadata file with the current collection list.
+// It must be called with db.mu held.
+func (db *DB) saveMetadata() error {
+	meta := metadata{Format: metadataFormat, Collections: map[string]Config{}}
+	for name, e := range db.entries {
+		meta.Collections[name] = e.config
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(filepath.Join(db.dir, metadataFile), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+func (db *DB) Create(name string, config Config) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%w %q", ErrInvalidName, name)
+	}
+	config = config.withDefaults()
+	err := config.validate()
+	if err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+	if _, ok := db.entries[name]; ok {
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, name)
+	}
+
+	err = os.MkdirAll(db.collectionDir(name), 0o755)
+	if err != nil {
+		return err
+	}
+	db.entries[name] = &entry{config: config}
+	err = db.saveMetadata()
+	if err != nil {
+		delete(db.entries, name)
+		os.RemoveAll(db.collectionDir(name))
+		return err
+	}
+	return nil
+}
+
+// Drop deletes a collection and its files. It fails with ErrInUse while a
+// Use call holds the collection.
+func (db *DB) Drop(name string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	e, err := db.settled(name)
+	if err != nil {
+		return err
+	}
+	if e.pins > 0 {
+		return fmt.Errorf("%w: %s", ErrInUse, name)
+	}
+
+	delete(db.entries, name)
+	err = db.saveMetadata()
+	if err != nil {
+		db.entries[name] = e
+		return err
+	}
+
+	if e.collection != nil {
+		e.collection.Close()
+	}
+	return os.RemoveAll(db.collectionDir(name))
+}
+
+func (db *DB) List() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	names := make([]string, 0, len(db.entries))
+	for name := range db.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (db *DB) Config(name string) (Config, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	e, ok := db.entries[name]
+	if !ok {
+		return Config{}, false
+	}
+	return e.config, true
+}
+
+// Use calls fnc with the named collection, loading it first if needed. The
+// collection stays in memory and cannot be dropped until fnc returns; it
+// must not be kept after that.
+func (db *DB) Use(name string, fnc func(collection *wal.Collection) error) error {
+	e, err := db.acquire(name)
+	if err != nil {
+		return err
+	}
+	defer db.release(e)
+	return fnc(e.collection)
+}
+
+// acquire pins the named collection, loading it first if needed. The load
+// and the replay of the log run without db.mu; the entry is marked as
+// loading meanwhile so other users of it wait for the result.
+func (db *DB) acquire(name string) (*entry, error) {
+	db.mu.Lock()
+	e, err := db.settled(name)
+	if err != nil {
+		db.mu.Unlock()
+		return nil, err
+	}
+
+	e.pins += 1
+	if e.collection == nil {
+		loading := make(chan struct{})
+		e.loading = loading
+		db.mu.Unlock()
+
+		collection, err := wal.OpenCollection(db.collectionDir(name), db.opts.Log, e.config.newCollection)
+
+		db.mu.Lock()
+		e.loading = nil
+		close(loading)
+		if err == nil && db.closed {
+			err = errors.Join(ErrClosed, collection.Close())
+		}
+		if err != nil {
+			e.pins -= 1
+			db.mu.Unlock()
+			return nil, fmt.Errorf("opening collection %s: %w", name, err)
+		}
+		e.collection = collection
+	}
+	db.clock += 1
+	e.lastUsed = db.clock
+
+	victims := db.victims()
+	db.mu.Unlock()
+
+	err = db.evict(victims)
+	if err != nil {
+		db.release(e)
+		return nil, err
+	}
+	return e, nil
+}
+
+// settled returns the named entry once no eviction or load of it is running.
+// It must be called with db.mu held, which it releases while waiting.
+func (db *DB) settled(name string) (*entry, error) {
+	for {
+		if db.closed {
+			return nil, ErrClosed
+		}
+		e, ok := db.entries[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		busy := e.busy()
+		if busy == nil {
+			return e, nil
+		}
+
+		db.mu.Unlock()
+		<-busy
+		db.mu.Lock()
+	}
+}
+
+func (db *DB) release(e *entry) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	e.pins -= 1
+}
+
+// victims picks the least recently used collections that are not in use
+// until at most MaxOpenCollections stay open, and marks them as evicting.
+// It must be called with db.mu held.
+func (db *DB) victims() []*entry {
+	if db.opts.MaxOpenCollections <= 0 {
+		return nil
+	}
+
+	open := []*entry{}
+	for _, e := range db.entries {
+		if e.collection != nil && e.evicting == nil {
+			open = append(open, e)
+		}
+	}
+	sort.Slice(open, func(i, j int) bool {
+		return open[i].lastUsed < open[j].lastUsed
+	})
+
+	var victims []*entry
+	for _, e := range open {
+		if len(open)-len(victims) <= db.opts.MaxOpenCollections {
+			break
+		}
+		if e.pins > 0 {
+			continue
+		}
+		e.evicting = make(chan struct{})
+		victims = append(victims, e)
+	}
+	return victims
+}
+
+// evict snapshots and closes the victims without holding db.mu, so other
+// collections stay usable meanwhile.
+func (db *DB) evict(victims []*entry) error {
+	var errs []error
+	for _, e := range victims {
+		errs = append(errs, closeCollection(e.collection))
+
+		db.mu.Lock()
+		e.collection = nil
+		close(e.evicting)
+		e.evicting = nil
+		db.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+func closeCollection(collection *wal.Collection) error {
+	err := collection.Snapshot()
+	return errors.Join(err, collection.Close())
+}
+
+// Loaded reports how many collections are currently in memory.
+func (db *DB) Loaded() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	n := 0
+	for _, e := range db.entries {
+		if e.collection != nil {
+			n += 1
+		}
+	}
+	return n
+}
+
+// Close snapshots and closes every loaded collection. Collections still held
+// by Use fail their writes with wal.ErrClosed.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+
+	var errs []error
+	for _, e := range db.entries {
+		for busy := e.busy(); busy != nil; busy = e.busy() {
+			db.mu.Unlock()
+			<-busy
+			db.mu.Lock()
+		}
+		if e.collection != nil {
+			errs = append(errs, closeCollection(e.collection))
+			e.collection = nil
+		}
+	}
+	return errors.Join(errs...)
+}
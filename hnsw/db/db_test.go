@@ -0,0 +1,268 @@
+package db
+
+import (
+	"errors"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/wal"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func addPoints(t *testing.T, db *DB, name string, n int, dim int) {
+	t.Helper()
+	err := db.Use(name, func(c *wal.Collection) error {
+		for i := 0; i < n; i += 1 {
+			vector := make(vectors.Vector, dim)
+			vector[i%dim] = vectors.VFloat(i)
+			_, err := c.Add(vector, nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func collectionLen(t *testing.T, db *DB, name string) int {
+	t.Helper()
+	n := 0
+	err := db.Use(name, func(c *wal.Collection) error {
+		n = c.Len()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestCreateListDrop(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Create("images", Config{Dimension: 4, Metric: "cosine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Create("texts", Config{Dimension: 8, Connectivity: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Create("texts", Config{Dimension: 8}); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("expected ErrAlreadyExists, got %v", err)
+	}
+	if err := db.Create("../escape", Config{Dimension: 8}); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("expected ErrInvalidName, got %v", err)
+	}
+	if err := db.Create("bad", Config{Dimension: 8, Metric: "nope"}); err == nil {
+		t.Fatalf("expected an error for an unknown metric")
+	}
+	if names := db.List(); !reflect.DeepEqual(names, []string{"images", "texts"}) {
+		t.Fatalf("unexpected collections %v", names)
+	}
+
+	addPoints(t, db, "texts", 30, 8)
+	err = db.Drop("images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use("images", func(c *wal.Collection) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, collectionsDir, "images")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("dropped collection files remain: %v", err)
+	}
+	db.Close()
+
+	db, err = Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if names := db.List(); !reflect.DeepEqual(names, []string{"texts"}) {
+		t.Fatalf("unexpected collections after reopening %v", names)
+	}
+	config, _ := db.Config("texts")
+	expected := Config{Dimension: 8, Metric: "euclidian", Layers: 3, Connectivity: 4, PrefetchFactor: 4}
+	if config != expected {
+		t.Fatalf("config %+v, expected %+v", config, expected)
+	}
+	if n := collectionLen(t, db, "texts"); n != 30 {
+		t.Fatalf("expected 30 points after reopening, got %d", n)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	dir := t.TempDir()
+	db, _ := Open(dir, Options{MaxOpenCollections: 2, Log: wal.Options{Sync: wal.SyncNever}})
+	defer db.Close()
+
+	names := []string{"a", "b", "c", "d"}
+	for i, name := range names {
+		db.Create(name, Config{Dimension: 4})
+		addPoints(t, db, name, 10*(i+1), 4)
+		if db.Loaded() > 2 {
+			t.Fatalf("%d collections loaded, at most 2 expected", db.Loaded())
+		}
+	}
+
+	for i, name := range names {
+		if n := collectionLen(t, db, name); n != 10*(i+1) {
+			t.Fatalf("collection %s has %d points after eviction, %d expected", name, n, 10*(i+1))
+		}
+	}
+
+	// Collections in use are never evicted.
+	err := db.Use("a", func(c *wal.Collection) error {
+		addPoints(t, db, "b", 1, 4)
+		addPoints(t, db, "c", 1, 4)
+		if _, err := c.Add(vectors.Vector{1, 2, 3, 4}, nil); err != nil {
+			return err
+		}
+		if err := db.Drop("a"); !errors.Is(err, ErrInUse) {
+			t.Fatalf("expected ErrInUse, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := collectionLen(t, db, "a"); n != 11 {
+		t.Fatalf("expected 11 points, got %d", n)
+	}
+}
+
+func TestFailedEvictionUnpins(t *testing.T) {
+	dir := t.TempDir()
+	db, _ := Open(dir, Options{MaxOpenCollections: 1})
+	defer db.Close()
+
+	db.Create("a", Config{Dimension: 4})
+	db.Create("b", Config{Dimension: 4})
+	addPoints(t, db, "a", 1, 4)
+
+	// The snapshot of a cannot be written without its directory.
+	os.RemoveAll(db.collectionDir("a"))
+	err := db.Use("b", func(c *wal.Collection) error { return nil })
+	if err == nil {
+		t.Fatalf("expected the eviction of a to fail")
+	}
+	if err := db.Drop("b"); err != nil {
+		t.Fatalf("b must not stay in use after a failed Use: %v", err)
+	}
+}
+
+func TestConcurrentUseWithEviction(t *testing.T) {
+	dir := t.TempDir()
+	db, _ := Open(dir, Options{MaxOpenCollections: 2, Log: wal.Options{Sync: wal.SyncNever}})
+	defer db.Close()
+
+	names := []string{"a", "b", "c", "d"}
+	for _, name := range names {
+		db.Create(name, Config{Dimension: 4})
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w += 1 {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 20; i += 1 {
+				addPoints(t, db, names[(w+i)%len(names)], 1, 4)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, name := range names {
+		total += collectionLen(t, db, name)
+	}
+	if total != 160 {
+		t.Fatalf("expected 160 points, got %d", total)
+	}
+}
+
+func TestConcurrentLoad(t *testing.T) {
+	dir := t.TempDir()
+	db, _ := Open(dir, Options{})
+	db.Create("a", Config{Dimension: 4})
+	addPoints(t, db, "a", 10, 4)
+	db.Close()
+
+	db, _ = Open(dir, Options{})
+	defer db.Close()
+
+	loaded := make([]*wal.Collection, 8)
+	var wg sync.WaitGroup
+	for w := range loaded {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			db.Use("a", func(c *wal.Collection) error {
+				loaded[w] = c
+				return nil
+			})
+		}(w)
+	}
+	wg.Wait()
+
+	for _, c := range loaded {
+		if c == nil || c != loaded[0] {
+			t.Fatalf("every Use must get the one loaded collection")
+		}
+	}
+	if n := collectionLen(t, db, "a"); n != 10 {
+		t.Fatalf("expected 10 points, got %d", n)
+	}
+}
+
+func TestFailedLoadUnpins(t *testing.T) {
+	dir := t.TempDir()
+	db, _ := Open(dir, Options{})
+	defer db.Close()
+	db.Create("a", Config{Dimension: 4})
+
+	// A file where the collection directory should be cannot be loaded.
+	os.RemoveAll(db.collectionDir("a"))
+	os.WriteFile(db.collectionDir("a"), nil, 0o644)
+	err := db.Use("a", func(c *wal.Collection) error { return nil })
+	if err == nil {
+		t.Fatalf("expected the load of a to fail")
+	}
+	if err := db.Drop("a"); err != nil {
+		t.Fatalf("a must not stay in use after a failed load: %v", err)
+	}
+}
+
+func TestOrphansRemoved(t *testing.T) {
+	dir := t.TempDir()
+	db, _ := Open(dir, Options{})
+	db.Create("kept", Config{Dimension: 4})
+	db.Close()
+
+	orphan := filepath.Join(dir, collectionsDir, "orphan")
+	os.MkdirAll(orphan, 0o755)
+
+	db, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := os.Stat(orphan); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("orphan directory not removed: %v", err)
+	}
+	if _, ok := db.Config("kept"); !ok {
+		t.Fatalf("collection lost")
+	}
+}
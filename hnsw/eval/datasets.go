@@ -0,0 +1,80 @@
+package eval
+
+import (
+	"go-hnsw/hnsw/vectors"
+	"math/rand/v2"
+)
+
+type Dataset struct {
+	Name      string
+	Dimension int
+	Train     []vectors.Vector
+	Queries   []vectors.Vector
+}
+
+// GaussianClusters draws vectors around nClusters random centers with the
+// given standard deviation. Queries come from the same distribution.
+func GaussianClusters(nTrain int, nQueries int, dimension int, nClusters int, stddev float64, seed uint64) Dataset {
+	rnd := rand.New(rand.NewPCG(seed, seed))
+
+	centers := make([]vectors.Vector, nClusters)
+	for i := range centers {
+		centers[i] = make(vectors.Vector, dimension)
+		for j := range centers[i] {
+			centers[i][j] = vectors.VFloat((rnd.Float64() - 0.5) * 20)
+		}
+	}
+
+	sample := func() vectors.Vector {
+		center := centers[rnd.IntN(nClusters)]
+		vector := make(vectors.Vector, dimension)
+		for j, c := range center {
+			vector[j] = c + vectors.VFloat(rnd.NormFloat64()*stddev)
+		}
+		return vector
+	}
+
+	return Dataset{
+		Name:      "gaussian-clusters",
+		Dimension: dimension,
+		Train:     sampleN(nTrain, sample),
+		Queries:   sampleN(nQueries, sample),
+	}
+}
+
+// UniformHypersphere draws vectors uniformly from the surface of the unit
+// hypersphere.
+func UniformHypersphere(nTrain int, nQueries int, dimension int, seed uint64) Dataset {
+	rnd := rand.New(rand.NewPCG(seed, seed))
+
+	sample := func() vectors.Vector {
+		vector := make(vectors.Vector, dimension)
+		for {
+			for j := range vector {
+				vector[j] = vectors.VFloat(rnd.NormFloat64())
+			}
+			abs := vectors.VectorAbs(vector)
+			if abs > 0 {
+				for j := range vector {
+					vector[j] /= abs
+				}
+				return vector
+			}
+		}
+	}
+
+	return Dataset{
+		Name:      "uniform-hypersphere",
+		Dimension: dimension,
+		Train:     sampleN(nTrain, sample),
+		Queries:   sampleN(nQueries, sample),
+	}
+}
+
+func sampleN(n int, sample func() vectors.Vector) []vectors.Vector {
+	res := make([]vectors.Vector, n)
+	for i := range res {
+		res[i] = sample()
+	}
+	return res
+}
@@ -0,0 +1,188 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"io"
+	"runtime"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+type Params struct {
+	Layers         int `json:"layers"`
+	Connectivity   int `json:"connectivity"`
+	PrefetchFactor int `json:"prefetch_factor"`
+}
+
+// Grid lists the values tried for every parameter; Run evaluates each
+// combination of them.
+type Grid struct {
+	Layers         []int
+	Connectivity   []int
+	PrefetchFactor []int
+}
+
+func (grid Grid) Params() []Params {
+	params := []Params{}
+	for _, l := range grid.Layers {
+		for _, c := range grid.Connectivity {
+			for _, p := range grid.PrefetchFactor {
+				params = append(params, Params{Layers: l, Connectivity: c, PrefetchFactor: p})
+			}
+		}
+	}
+	return params
+}
+
+type Config struct {
+	K        int
+	Distance distances.Distance
+	Grid     Grid
+}
+
+type Result struct {
+	Params
+	Dataset     string        `json:"dataset"`
+	K           int           `json:"k"`
+	Recall      float64       `json:"recall"`
+	QPS         float64       `json:"qps"`
+	P50Latency  time.Duration `json:"p50_latency_ns"`
+	P99Latency  time.Duration `json:"p99_latency_ns"`
+	BuildTime   time.Duration `json:"build_time_ns"`
+	MemoryBytes uint64        `json:"memory_bytes"`
+}
+
+// GroundTruth returns the ids of the exact k nearest train vectors for every
+// query. Ids are positions in dataset.Train.
+func GroundTruth(dataset Dataset, distance distances.Distance, k int) [][]uint64 {
+	flat := hnsw.NewFlatCollection(dataset.Dimension, distance)
+	for _, v := range dataset.Train {
+		flat.Add(v, nil)
+	}
+
+	truth := make([][]uint64, len(dataset.Queries))
+	for i, q := range dataset.Queries {
+		for _, r := range flat.Search(q, k) {
+			truth[i] = append(truth[i], r.Id)
+		}
+	}
+	return truth
+}
+
+func Run(dataset Dataset, config Config) []Result {
+	truth := GroundTruth(dataset, config.Distance, config.K)
+
+	results := []Result{}
+	for _, params := range config.Grid.Params() {
+		results = append(results, runOne(dataset, config, params, truth))
+	}
+	return results
+}
+
+func runOne(dataset Dataset, config Config, params Params, truth [][]uint64) Result {
+	before := heapAlloc()
+
+	start := time.Now()
+	collection := hnsw.NewHnswCollection(params.Layers, dataset.Dimension, config.Distance, params.Connectivity, params.PrefetchFactor)
+	for _, v := range dataset.Train {
+		collection.Add(v, nil)
+	}
+	buildTime := time.Since(start)
+
+	after := heapAlloc()
+
+	result := Measure(collection, dataset.Queries, truth, config.K)
+	result.Params = params
+	result.Dataset = dataset.Name
+	result.BuildTime = buildTime
+	if after > before {
+		result.MemoryBytes = after - before
+	}
+	return result
+}
+
+// Measure runs every query against the index and compares the results with
+// the expected ids in truth. Recall is the share of the first k expected ids
+// of every query that were found, so a query with fewer than k expected ids
+// can still reach 1.
+func Measure(index hnsw.Index, queries []vectors.Vector, truth [][]uint64, k int) Result {
+	latencies := make([]time.Duration, len(queries))
+	found, wanted := 0, 0
+	start := time.Now()
+	for i, q := range queries {
+		queryStart := time.Now()
+		res := index.Search(q, k)
+		latencies[i] = time.Since(queryStart)
+
+		expected := truth[i]
+		if len(expected) > k {
+			expected = expected[:k]
+		}
+		found += Recall(res, expected)
+		wanted += len(expected)
+	}
+	total := time.Since(start)
+
+	result := Result{
+		K:          k,
+		P50Latency: percentile(latencies, 0.50),
+		P99Latency: percentile(latencies, 0.99),
+	}
+	if wanted > 0 {
+		result.Recall = float64(found) / float64(wanted)
+	}
+	if len(queries) > 0 {
+		result.QPS = float64(len(queries)) / total.Seconds()
+	}
+	return result
+}
+
+// Recall counts how many of the expected ids are among the results.
+func Recall(results []hnsw.SearchResult, expected []uint64) int {
+	found := 0
+	for _, r := range results {
+		if slices.Contains(expected, r.Id) {
+			found += 1
+		}
+	}
+	return found
+}
+
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+func heapAlloc() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+func WriteJSON(writer io.Writer, results []Result) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+func WriteTable(writer io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(writer, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "dataset\tlayers\tconnectivity\tprefetch\tk\trecall\tqps\tp50\tp99\tbuild\tmemory\t")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%.4f\t%.0f\t%s\t%s\t%s\t%.1fMiB\t\n",
+			r.Dataset, r.Layers, r.Connectivity, r.PrefetchFactor, r.K, r.Recall, r.QPS,
+			r.P50Latency, r.P99Latency, r.BuildTime.Round(time.Millisecond), float64(r.MemoryBytes)/(1<<20))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,103 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGroundTruth(t *testing.T) {
+	dataset := GaussianClusters(200, 5, 4, 3, 0.5, 1)
+
+	truth := GroundTruth(dataset, distances.Euclidian, 3)
+
+	if len(truth) != 5 {
+		t.Fatalf("Ground truth expected for 5 queries but %d found", len(truth))
+	}
+	for i, ids := range truth {
+		if len(ids) != 3 {
+			t.Fatalf("Query %d expected to have 3 neighbors but %d found", i, len(ids))
+		}
+		d0 := distances.Euclidian(dataset.Queries[i], dataset.Train[ids[0]])
+		d2 := distances.Euclidian(dataset.Queries[i], dataset.Train[ids[2]])
+		if d0 > d2 {
+			t.Fatalf("Ground truth of query %d is not sorted by distance", i)
+		}
+	}
+}
+
+func isUnit(vector vectors.Vector) bool {
+	return math.Abs(float64(vectors.VectorAbs(vector))-1) < 1e-9
+}
+
+func TestUniformHypersphere(t *testing.T) {
+	dataset := UniformHypersphere(50, 5, 8, 7)
+
+	for _, v := range append(dataset.Train, dataset.Queries...) {
+		if !isUnit(v) {
+			t.Fatalf("Vector %s is not on the unit hypersphere", v)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dataset := UniformHypersphere(500, 20, 8, 3)
+	config := Config{
+		K:        5,
+		Distance: distances.Euclidian,
+		Grid: Grid{
+			Layers:         []int{3},
+			Connectivity:   []int{4, 8},
+			PrefetchFactor: []int{4},
+		},
+	}
+
+	results := Run(dataset, config)
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results but %d found", len(results))
+	}
+	for _, r := range results {
+		if r.Recall < 0.8 {
+			t.Fatalf("Recall %f is too low for %+v", r.Recall, r.Params)
+		}
+		if r.QPS <= 0 || r.P99Latency < r.P50Latency {
+			t.Fatalf("Unexpected latency figures %+v", r)
+		}
+	}
+
+	buff := new(bytes.Buffer)
+	err := WriteJSON(buff, results)
+	if err != nil {
+		t.Fatalf("'WriteJSON' returned error: %s", err)
+	}
+	decoded := []Result{}
+	err = json.Unmarshal(buff.Bytes(), &decoded)
+	if err != nil || len(decoded) != 2 || decoded[1].Connectivity != 8 {
+		t.Fatalf("Unexpected JSON output: %s", buff.String())
+	}
+
+	buff.Reset()
+	WriteTable(buff, results)
+	if !strings.Contains(buff.String(), "recall") || strings.Count(buff.String(), "\n") != 3 {
+		t.Fatalf("Unexpected table output: %s", buff.String())
+	}
+}
+
+func TestMeasureWithShortTruth(t *testing.T) {
+	index := hnsw.NewFlatCollection(2, distances.Euclidian)
+	for i := 0; i < 3; i += 1 {
+		index.Add(vectors.Vector{vectors.VFloat(i), 0}, nil)
+	}
+
+	// Only 3 points exist, so the truth of k = 10 holds 3 ids.
+	result := Measure(index, []vectors.Vector{{0, 0}}, [][]uint64{{0, 1, 2}}, 10)
+	if result.Recall != 1 {
+		t.Fatalf("Recall expected to be 1 but %f found", result.Recall)
+	}
+}
@@ -0,0 +1,179 @@
+package hnsw
+
+import (
+	"container/heap"
+	"fmt"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"runtime"
+	"sync"
+)
+
+const minParallelScanSize = 4096
+
+// FlatCollection compares the query against every stored vector. It gives
+// exact results and suits small collections or ground truth computation.
+type FlatCollection struct {
+	nodes           []*Node
+	rindex          map[uint64]int
+	idCounter       uint64
+	vectorDimension int
+	distance        distances.Distance
+}
+
+func NewFlatCollection(vectorDimension int, distance distances.Distance) *FlatCollection {
+	flat := new(FlatCollection)
+	flat.rindex = map[uint64]int{}
+	flat.vectorDimension = vectorDimension
+	flat.distance = distance
+	return flat
+}
+
+func (flat *FlatCollection) Add(vector vectors.Vector, value []byte) uint64 {
+	if len(vector) != flat.vectorDimension {
+		panic(fmt.Sprintf("Vector dimension must be %d", flat.vectorDimension))
+	}
+
+	id := flat.idCounter
+	flat.idCounter += 1
+
+	flat.nodes = append(flat.nodes, &Node{Id: id, Vector: vector, Value: value})
+	flat.rindex[id] = len(flat.nodes) - 1
+
+	return id
+}
+
+func (flat *FlatCollection) Upsert(id uint64, vector vectors.Vector, value []byte) {
+	if len(vector) != flat.vectorDimension {
+		panic(fmt.Sprintf("Vector dimension must be %d", flat.vectorDimension))
+	}
+
+	if id >= flat.idCounter {
+		flat.idCounter = id + 1
+	}
+
+	node := &Node{Id: id, Vector: vector, Value: value}
+	index, ok := flat.rindex[id]
+	if ok {
+		flat.nodes[index] = node
+		return
+	}
+	flat.nodes = append(flat.nodes, node)
+	flat.rindex[id] = len(flat.nodes) - 1
+}
+
+func (flat *FlatCollection) UpsertBatch(points []Point) {
+	validatePoints(flat.vectorDimension, points)
+
+	for _, point := range points {
+		flat.Upsert(point.Id, point.Vector, point.Value)
+	}
+}
+
+func (flat *FlatCollection) AddBatch(vectors []vectors.Vector, values [][]byte) []uint64 {
+	validateBatch(flat.vectorDimension, vectors, values)
+
+	ids := make([]uint64, len(vectors))
+	for i, vector := range vectors {
+		ids[i] = flat.Add(vector, batchValue(values, i))
+	}
+	return ids
+}
+
+func (flat *FlatCollection) Remove(id uint64) bool {
+	index, ok := flat.rindex[id]
+	if !ok {
+		return false
+	}
+
+	last := len(flat.nodes) - 1
+	flat.nodes[index] = flat.nodes[last]
+	flat.rindex[flat.nodes[index].Id] = index
+	flat.nodes[last] = nil
+	flat.nodes = flat.nodes[:last]
+	delete(flat.rindex, id)
+
+	return true
+}
+
+func (flat *FlatCollection) Search(vector vectors.Vector, k int) []SearchResult {
+	return toSearchResults(flat.nNearest(vector, k, nil), vector, flat.distance)
+}
+
+func (flat *FlatCollection) SearchFiltered(vector vectors.Vector, k int, filter Filter) []SearchResult {
+	return toSearchResults(flat.nNearest(vector, k, filter), vector, flat.distance)
+}
+
+func (flat *FlatCollection) Get(id uint64) (Point, bool) {
+	index, ok := flat.rindex[id]
+	if !ok {
+		return Point{}, false
+	}
+	node := flat.nodes[index]
+	return node.point(), true
+}
+
+func (flat *FlatCollection) VectorDimension() int {
+	return flat.vectorDimension
+}
+
+func (flat *FlatCollection) Distance() distances.Distance {
+	return flat.distance
+}
+
+func (flat *FlatCollection) Len() int {
+	return len(flat.nodes)
+}
+
+func (flat *FlatCollection) Range(fnc func(point Point) bool) {
+	for _, node := range flat.nodes {
+		if !fnc(node.point()) {
+			return
+		}
+	}
+}
+
+func (flat *FlatCollection) nNearest(vector vectors.Vector, n int, filter Filter) []*Node {
+	if n <= 0 {
+		return []*Node{}
+	}
+
+	workers := runtime.NumCPU()
+	if len(flat.nodes) < minParallelScanSize || workers == 1 {
+		return flat.scan(flat.nodes, vector, n, filter).nodes
+	}
+
+	chunkSize := (len(flat.nodes) + workers - 1) / workers
+	partial := make([]*KClosestNodes, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w += 1 {
+		start := min(w*chunkSize, len(flat.nodes))
+		end := min(start+chunkSize, len(flat.nodes))
+		wg.Add(1)
+		go func(w int, nodes []*Node) {
+			defer wg.Done()
+			partial[w] = flat.scan(nodes, vector, n, filter)
+		}(w, flat.nodes[start:end])
+	}
+	wg.Wait()
+
+	knearest := NewKClosestNodes(n, vector, flat.distance)
+	for _, p := range partial {
+		for i, node := range p.nodes {
+			heap.Push(knearest, candidate{node: node, distance: p.distances[i]})
+		}
+	}
+	return knearest.nodes
+}
+
+func (flat *FlatCollection) scan(nodes []*Node, vector vectors.Vector, n int, filter Filter) *KClosestNodes {
+	knearest := NewKClosestNodes(n, vector, flat.distance)
+	for _, node := range nodes {
+		if filter != nil && !filter(node.Id, node.Value) {
+			continue
+		}
+		heap.Push(knearest, node)
+	}
+	return knearest
+}
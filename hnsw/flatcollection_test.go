@@ -0,0 +1,60 @@
+package hnsw
+
+import (
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"testing"
+)
+
+var _ Index = (*HnswCollection)(nil)
+var _ Index = (*FlatCollection)(nil)
+
+func TestFlatCollectionIsExact(t *testing.T) {
+	data := randomVectors(minParallelScanSize*2, 8)
+
+	flat := NewFlatCollection(8, distances.Euclidian)
+	for _, v := range data {
+		flat.Add(v, nil)
+	}
+
+	for _, query := range randomVectors(5, 8) {
+		truth := exactNearest(data, query, 10)
+		res := flat.nNearest(query, 10, nil)
+
+		if len(res) != 10 {
+			t.Fatalf("Number of found nodes expected to be 10 but found %d", len(res))
+		}
+		for _, node := range res {
+			if !truth[node.Id] {
+				t.Fatalf("Node %d is not among the exact nearest nodes", node.Id)
+			}
+		}
+	}
+}
+
+func TestFlatCollectionRemove(t *testing.T) {
+	flat := NewFlatCollection(3, distances.Euclidian)
+
+	flat.Add(vectors.Vector{1.0, 0, 1.0}, []byte("v1"))
+	flat.Add(vectors.Vector{1.0, 0, 2.0}, []byte("v2"))
+	lastId := flat.Add(vectors.Vector{-1.0, 1.0, 0}, []byte("v3"))
+
+	if !flat.Remove(lastId) {
+		t.Fatal("Remove method must return 'true'")
+	}
+
+	if flat.Remove(lastId) {
+		t.Fatal("Remove method must return 'false' for a removed node")
+	}
+
+	res := nnToMap(flat.nNearest(vectors.Vector{0, 0, 0}, 3, nil))
+
+	if len(res) != 2 {
+		t.Fatalf("Number of found nodes expected to be 2 but found %d", len(res))
+	}
+
+	_, ok := res["v3"]
+	if ok {
+		t.Fatalf("The node with 'v3' value must not be present in the response")
+	}
+}
@@ -0,0 +1,236 @@
+package hnsw
+
+import (
+	"container/heap"
+	"go-hnsw/hnsw/vectors"
+	"sort"
+)
+
+// GroupKey returns the group of a point, or false to leave it out of grouped
+// searches.
+type GroupKey func(id uint64, value []byte) (string, bool)
+
+type GroupOptions struct {
+	Key GroupKey
+	// Groups is the number of groups returned and GroupSize the maximum
+	// number of hits in each.
+	Groups    int
+	GroupSize int
+	// Filter, Allowed and Ef apply as in other searches; Ef defaults to
+	// Groups*GroupSize times the prefetch factor.
+	SearchOptions
+}
+
+type Group struct {
+	Key  string
+	Hits []SearchResult
+}
+
+// SearchGroups returns the groups with the nearest points, ordered by their
+// nearest hit. The traversal keeps going past ef until the Groups nearest
+// groups have GroupSize hits or the graph is exhausted, so a few large groups
+// near the query do not hide the others.
+func (hnsw *HnswCollection) SearchGroups(vector vectors.Vector, opts GroupOptions) []Group {
+	if opts.Key == nil {
+		panic("Group key is required")
+	}
+	if opts.Groups <= 0 || opts.GroupSize <= 0 {
+		panic("Groups and GroupSize must be > 0")
+	}
+
+	layer := hnsw.bottomLayer()
+	distance := layer.distanceTo(vector)
+	start := hnsw.entryPoint(nil, distance)
+	if start == nil {
+		return []Group{}
+	}
+
+	groups := map[string]*groupState{}
+	top := &topGroups{}
+	collect := func(c candidate) bool {
+		node := c.node
+		if opts.Allowed != nil && !opts.Allowed[node.Id] {
+			return false
+		}
+		if opts.Filter != nil && !opts.Filter(node.Id, node.Value) {
+			return false
+		}
+		key, ok := opts.Key(node.Id, node.Value)
+		if !ok {
+			return false
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &groupState{key: key, hits: newKClosestNodesBy(opts.GroupSize, distance), nearest: c.distance, index: -1}
+			groups[key] = group
+		}
+		heap.Push(group.hits, c)
+		group.nearest = min(group.nearest, c.distance)
+		top.update(group, opts.Groups, opts.GroupSize)
+		return true
+	}
+	// filled reports whether the groups with the nearest hits so far all
+	// have GroupSize hits.
+	filled := func() bool {
+		return len(top.groups) == opts.Groups && top.unfilled == 0
+	}
+
+	ef := hnsw.ef(opts.Groups*opts.GroupSize, opts.SearchOptions)
+	nearest := newKClosestNodesBy(ef, distance)
+
+	first := candidate{node: start, distance: distance(start)}
+	candidates := &candidateQueue{first}
+	heap.Push(nearest, first)
+	collect(first)
+
+	// Past the usual stopping point the traversal goes on, accepting every
+	// neighbor including the ones rejected so far, until the nearest groups
+	// are filled. Then it narrows back to ef.
+	var rejected []candidate
+	exhausting, done := false, false
+	exhaust := func() {
+		exhausting = true
+		for _, c := range rejected {
+			heap.Push(candidates, c)
+			heap.Push(nearest, c)
+			collect(c)
+		}
+		rejected = nil
+		done = filled()
+	}
+
+	visited := map[uint64]bool{start.Id: true}
+	for {
+		if candidates.Len() == 0 {
+			if exhausting || len(rejected) == 0 {
+				break
+			}
+			exhaust()
+			continue
+		}
+
+		current := heap.Pop(candidates).(candidate)
+		if nearest.Len() == ef && current.distance > nearest.distances[0] {
+			if !exhausting {
+				exhaust()
+			}
+			if done {
+				break
+			}
+		}
+
+		for _, nbh := range current.node.neighbors {
+			if visited[nbh.Id] {
+				continue
+			}
+			visited[nbh.Id] = true
+
+			next := candidate{node: nbh, distance: distance(nbh)}
+			if nearest.Len() == ef && next.distance >= nearest.distances[0] {
+				if !exhausting {
+					rejected = append(rejected, next)
+					continue
+				}
+				if done {
+					continue
+				}
+			}
+			heap.Push(candidates, next)
+			heap.Push(nearest, next)
+			if collect(next) && exhausting && !done {
+				done = filled()
+			}
+		}
+	}
+
+	result := make([]Group, 0, len(groups))
+	for key, group := range groups {
+		result = append(result, Group{Key: key, Hits: toSearchResults(group.hits.nodes, vector, layer.DistanceFnc)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Hits[0].Distance != result[j].Hits[0].Distance {
+			return result[i].Hits[0].Distance < result[j].Hits[0].Distance
+		}
+		return result[i].Key < result[j].Key
+	})
+	if len(result) > opts.Groups {
+		result = result[:opts.Groups]
+	}
+	return result
+}
+
+type groupState struct {
+	key     string
+	hits    *KClosestNodes
+	nearest vectors.VFloat
+	full    bool
+	// index is the position in topGroups, or -1 when not among them.
+	index int
+}
+
+// topGroups keeps the groups with the nearest hits as a heap with the
+// farthest of them on top, and counts those that still miss hits.
+type topGroups struct {
+	groups   []*groupState
+	unfilled int
+}
+
+func (top *topGroups) Len() int { return len(top.groups) }
+
+func (top *topGroups) Less(i, j int) bool {
+	return groupBefore(top.groups[j], top.groups[i])
+}
+
+func (top *topGroups) Swap(i, j int) {
+	top.groups[i], top.groups[j] = top.groups[j], top.groups[i]
+	top.groups[i].index = i
+	top.groups[j].index = j
+}
+
+func (top *topGroups) Push(x any) {
+	group := x.(*groupState)
+	group.index = len(top.groups)
+	top.groups = append(top.groups, group)
+}
+
+func (top *topGroups) Pop() any {
+	group := top.groups[len(top.groups)-1]
+	top.groups = top.groups[:len(top.groups)-1]
+	group.index = -1
+	return group
+}
+
+// groupBefore orders groups as SearchGroups returns them.
+func groupBefore(a, b *groupState) bool {
+	if a.nearest != b.nearest {
+		return a.nearest < b.nearest
+	}
+	return a.key < b.key
+}
+
+// update is called after a hit was added to group. Hits only get nearer, so
+// a group only moves up and the groups left out stay behind the kept ones.
+func (top *topGroups) update(group *groupState, n int, groupSize int) {
+	wasFull := group.full
+	group.full = group.hits.Len() >= groupSize
+	if group.index >= 0 {
+		if group.full && !wasFull {
+			top.unfilled -= 1
+		}
+		heap.Fix(top, group.index)
+		return
+	}
+	if len(top.groups) == n {
+		if !groupBefore(group, top.groups[0]) {
+			return
+		}
+		evicted := heap.Pop(top).(*groupState)
+		if !evicted.full {
+			top.unfilled -= 1
+		}
+	}
+	heap.Push(top, group)
+	if !group.full {
+		top.unfilled += 1
+	}
+}
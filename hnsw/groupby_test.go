@@ -0,0 +1,139 @@
+package hnsw
+
+import (
+	"container/heap"
+	"fmt"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"math/rand/v2"
+	"sort"
+	"testing"
+)
+
+func TestSearchGroupsFillsGroups(t *testing.T) {
+	hnsw := NewHnswCollection(3, 2, distances.Euclidian, 8, 2)
+
+	// Document "near" has many chunks close to the query, so the plain
+	// nearest points all belong to it.
+	for i := 0; i < 50; i += 1 {
+		hnsw.Add(vectors.Vector{vectors.VFloat(i) * 0.001, 0}, []byte("near"))
+	}
+	for d := 0; d < 5; d += 1 {
+		for i := 0; i < 4; i += 1 {
+			hnsw.Add(vectors.Vector{vectors.VFloat(d + 1), vectors.VFloat(i) * 0.1}, []byte(fmt.Sprintf("doc%d", d)))
+		}
+	}
+
+	byValue := func(id uint64, value []byte) (string, bool) {
+		return string(value), true
+	}
+
+	groups := hnsw.SearchGroups(vectors.Vector{0, 0}, GroupOptions{Key: byValue, Groups: 3, GroupSize: 2})
+	if len(groups) != 3 {
+		t.Fatalf("Expected 3 groups but found %d", len(groups))
+	}
+	for i, key := range []string{"near", "doc0", "doc1"} {
+		if groups[i].Key != key || len(groups[i].Hits) != 2 {
+			t.Fatalf("Expected group %s with 2 hits but found %s with %d", key, groups[i].Key, len(groups[i].Hits))
+		}
+		if groups[i].Hits[0].Distance > groups[i].Hits[1].Distance {
+			t.Fatalf("Hits of group %s are not ordered", key)
+		}
+	}
+	if groups[1].Hits[0].Distance != 1 {
+		t.Fatalf("Expected the nearest hit of doc0 at distance 1 but found %v", groups[1].Hits[0].Distance)
+	}
+}
+
+func TestSearchGroupsMatchesExact(t *testing.T) {
+	data := randomVectors(1000, 8)
+	hnsw := NewHnswCollection(3, 8, distances.Euclidian, 16, 4)
+	for _, v := range data {
+		hnsw.Add(v, nil)
+	}
+
+	byId := func(id uint64, value []byte) (string, bool) {
+		if id%5 == 0 {
+			return "", false
+		}
+		return fmt.Sprint(id % 40), true
+	}
+
+	query := randomVectors(1, 8)[0]
+	groups := hnsw.SearchGroups(query, GroupOptions{Key: byId, Groups: 5, GroupSize: 3})
+
+	best := map[string]vectors.VFloat{}
+	for i, v := range data {
+		if key, ok := byId(uint64(i), nil); ok {
+			if d, seen := best[key]; !seen || distances.Euclidian(query, v) < d {
+				best[key] = distances.Euclidian(query, v)
+			}
+		}
+	}
+
+	if len(groups) != 5 {
+		t.Fatalf("Expected 5 groups but found %d", len(groups))
+	}
+	for i, group := range groups {
+		if len(group.Hits) != 3 {
+			t.Fatalf("Expected 3 hits in group %s but found %d", group.Key, len(group.Hits))
+		}
+		if group.Hits[0].Distance != best[group.Key] {
+			t.Fatalf("Nearest hit of group %s is %v but %v expected", group.Key, group.Hits[0].Distance, best[group.Key])
+		}
+		if i > 0 && groups[i-1].Hits[0].Distance > group.Hits[0].Distance {
+			t.Fatalf("Groups are not ordered by their nearest hit")
+		}
+	}
+	for key, d := range best {
+		if d < groups[len(groups)-1].Hits[0].Distance {
+			found := false
+			for _, group := range groups {
+				found = found || group.Key == key
+			}
+			if !found {
+				t.Fatalf("Group %s is nearer than the returned ones but missing", key)
+			}
+		}
+	}
+}
+
+func TestTopGroupsMatchesSorting(t *testing.T) {
+	const n, groupSize = 4, 3
+	groups := map[string]*groupState{}
+	top := &topGroups{}
+	distance := func(node *Node) vectors.VFloat { return 0 }
+
+	for i := 0; i < 2000; i += 1 {
+		key := fmt.Sprint(rand.IntN(30))
+		hit := vectors.VFloat(rand.Float64())
+		group, ok := groups[key]
+		if !ok {
+			group = &groupState{key: key, hits: newKClosestNodesBy(groupSize, distance), nearest: hit, index: -1}
+			groups[key] = group
+		}
+		heap.Push(group.hits, candidate{node: &Node{Id: uint64(i)}, distance: hit})
+		group.nearest = min(group.nearest, hit)
+		top.update(group, n, groupSize)
+
+		sorted := make([]*groupState, 0, len(groups))
+		for _, g := range groups {
+			sorted = append(sorted, g)
+		}
+		sort.Slice(sorted, func(i, j int) bool { return groupBefore(sorted[i], sorted[j]) })
+		sorted = sorted[:min(n, len(sorted))]
+
+		unfilled := 0
+		for _, g := range sorted {
+			if g.index < 0 {
+				t.Fatalf("Group %s is among the %d nearest but not kept", g.key, n)
+			}
+			if g.hits.Len() < groupSize {
+				unfilled += 1
+			}
+		}
+		if len(top.groups) != len(sorted) || top.unfilled != unfilled {
+			t.Fatalf("Expected %d kept groups with %d unfilled but found %d with %d", len(sorted), unfilled, len(top.groups), top.unfilled)
+		}
+	}
+}
@@ -64,11 +64,11 @@ func TestHnsw(t *testing.T) {
 		hnswCollection.Add(toVector(vectorToAdd), []byte("B"))
 	}
 
-	resA := hnswCollection.NNearest(toVector(baseVectorA), 20)
+	resA := hnswCollection.nNearest(toVector(baseVectorA), 20)
 
 	validateANN(t, resA, 20, "A")
 
-	resB := hnswCollection.NNearest(toVector(baseVectorB), 20)
+	resB := hnswCollection.nNearest(toVector(baseVectorB), 20)
 
 	validateANN(t, resB, 20, "B")
 
@@ -85,7 +85,7 @@ func HnswRemoveNodeTest(t *testing.T) {
 	hnswCollection.Add(v2, []byte("v2"))
 	lastId := hnswCollection.Add(v3, []byte("v3"))
 
-	originalResult := nnToMap(hnswCollection.NNearest(vectors.Vector{0, 0, 0}, 3))
+	originalResult := nnToMap(hnswCollection.nNearest(vectors.Vector{0, 0, 0}, 3))
 
 	if len(originalResult) != 3 {
 		t.Fatalf("Number of found nodes expected to be 3 but found %d", len(originalResult))
@@ -97,7 +97,7 @@ func HnswRemoveNodeTest(t *testing.T) {
 		t.Fatal("Remove method must return 'true'")
 	}
 
-	modifiedResult := nnToMap(hnswCollection.NNearest(vectors.Vector{0, 0, 0}, 3))
+	modifiedResult := nnToMap(hnswCollection.nNearest(vectors.Vector{0, 0, 0}, 3))
 
 	if len(modifiedResult) != 2 {
 		t.Fatalf("Number of found nodes expected to be 3 but found %d", len(modifiedResult))
@@ -1,10 +1,13 @@
 package hnsw
 
 import (
+	"container/heap"
 	"fmt"
+	"go-hnsw/hnsw/quantization"
 	"go-hnsw/hnsw/vectors"
 	"go-hnsw/hnsw/vectors/distances"
 	"math/rand/v2"
+	"sync"
 )
 
 type HnswCollection struct {
@@ -13,6 +16,13 @@ type HnswCollection struct {
 	connectivity    int
 	prefetchFactor  int
 	vectorDimension int
+	pq              *quantization.ProductQuantizer
+	pqCosine        bool
+	binary          bool
+	// writeMu serializes writers with each other and with the start and end
+	// of a Snapshot.
+	writeMu    sync.Mutex
+	snapshotMu sync.Mutex
 }
 
 func NewHnswCollection(nLayers int, vectorDimension int, distance distances.Distance, connectivity int, prefetchFactor int) *HnswCollection {
@@ -38,35 +48,282 @@ func (hnsw *HnswCollection) Add(vector vectors.Vector, value []byte) uint64 {
 		panic(fmt.Sprintf("Vector dimension must be %d", hnsw.vectorDimension))
 	}
 
+	hnsw.writeMu.Lock()
+	defer hnsw.writeMu.Unlock()
+
+	id := hnsw.generateNewId()
+	hnsw.insert(id, vector, value)
+	return id
+}
+
+// Upsert stores the point under the given id, replacing a point that already
+// has it. Ids generated by Add afterwards never collide with it.
+func (hnsw *HnswCollection) Upsert(id uint64, vector vectors.Vector, value []byte) {
+	if len(vector) != hnsw.vectorDimension {
+		panic(fmt.Sprintf("Vector dimension must be %d", hnsw.vectorDimension))
+	}
+
+	hnsw.writeMu.Lock()
+	defer hnsw.writeMu.Unlock()
+
+	hnsw.remove(id)
+	if id >= hnsw.idCounter {
+		hnsw.idCounter = id + 1
+	}
+	hnsw.insert(id, vector, value)
+}
+
+func (hnsw *HnswCollection) UpsertBatch(points []Point) {
+	validatePoints(hnsw.vectorDimension, points)
+
+	for _, point := range points {
+		hnsw.Upsert(point.Id, point.Vector, point.Value)
+	}
+}
+
+func (hnsw *HnswCollection) insert(id uint64, vector vectors.Vector, value []byte) {
+	hnsw.insertWith(nil, id, vector, value)
+}
+
+// insertWith adds the point from a random layer down to the bottom.
+func (hnsw *HnswCollection) insertWith(budget *searchBudget, id uint64, vector vectors.Vector, value []byte) error {
 	insertIdx := 0
 
 	if !hnsw.layers[0].IsEmpty() {
 		insertIdx = hnsw.findInsertIndex()
 	}
 
+	return hnsw.insertAt(budget, insertIdx, id, vector, value)
+}
+
+// insertAt adds the point to the layers from insertIdx down. It finds the
+// neighbors of the point in every layer before linking it anywhere, so
+// running out of budget leaves the graph unchanged.
+func (hnsw *HnswCollection) insertAt(budget *searchBudget, insertIdx int, id uint64, vector vectors.Vector, value []byte) error {
+	layers := hnsw.layers[insertIdx:]
+	neighbors := make([][]*Node, len(layers))
+	for i, layer := range layers {
+		scratch := newSearchScratch()
+		scratch.budget = budget
+		neighbors[i] = layer.neighborsFor(scratch, vector, hnsw.connectivity)
+		if budget.failed() {
+			return budget.err
+		}
+	}
+
+	// The codes are shared by every copy of the node and kept next to the
+	// raw vector, which re-scoring still needs.
+	var codes []byte
+	if hnsw.pq != nil {
+		codes = hnsw.pq.Encode(vector)
+	}
+	var bits quantization.BinaryCode
+	if hnsw.binary {
+		bits = quantization.EncodeBinary(vector)
+	}
+
 	node := &Node{}
-	id := hnsw.generateNewId()
-	for i := insertIdx; i < len(hnsw.layers); i += 1 {
-		layer := hnsw.layers[i]
-		newNode := layer.Add(id, vector, value, hnsw.connectivity)
+	for i, layer := range layers {
+		newNode := layer.link(id, vector, value, neighbors[i])
+		newNode.Codes = codes
+		newNode.Bits = bits
 		node.NextLevel = newNode
 		node = newNode
 	}
-	return id
+	return nil
+}
+
+// AddBatch adds the vectors in order and returns their ids. values may be nil,
+// otherwise it must have one entry per vector. Nothing is added if any vector
+// has a wrong dimension.
+func (hnsw *HnswCollection) AddBatch(vectors []vectors.Vector, values [][]byte) []uint64 {
+	validateBatch(hnsw.vectorDimension, vectors, values)
+
+	ids := make([]uint64, len(vectors))
+	for i, vector := range vectors {
+		ids[i] = hnsw.Add(vector, batchValue(values, i))
+	}
+	return ids
+}
+
+func (hnsw *HnswCollection) nNearest(vector vectors.Vector, n int) []*Node {
+	return hnsw.nNearestWithOptions(nil, vector, n, SearchOptions{})
 }
 
-func (hnsw *HnswCollection) NNearest(vector vectors.Vector, n int) []*Node {
-	node := hnsw.layers[0].Nearest(vector)
+// nNearestWithOptions is the entry point of every search. n <= 0 finds
+// nothing.
+func (hnsw *HnswCollection) nNearestWithOptions(scratch *searchScratch, vector vectors.Vector, n int, opts SearchOptions) []*Node {
+	if n <= 0 {
+		return []*Node{}
+	}
+
+	oversampling := opts.Oversampling
+	if oversampling <= 0 && (hnsw.pq != nil || hnsw.binary) {
+		oversampling = DefaultOversampling
+	}
+
+	if opts.Allowed != nil && countAllowed(opts.Allowed, hnsw.ef(n, opts)) <= hnsw.ef(n, opts) {
+		return hnsw.nNearestAllowed(scratch, vector, n, opts)
+	}
+
+	var accept func(node *Node) bool
+	if opts.Filter != nil || opts.Allowed != nil {
+		accept = func(node *Node) bool {
+			if opts.Allowed != nil && !opts.Allowed[node.Id] {
+				return false
+			}
+			return opts.Filter == nil || opts.Filter(node.Id, node.Value)
+		}
+	}
+
+	if (hnsw.pq == nil && !hnsw.binary) || oversampling <= 1 {
+		return hnsw.nNearestBy(scratch, hnsw.queryDistance(vector), n, hnsw.ef(n, opts), accept)
+	}
+
+	candidates := hnsw.nNearestBy(scratch, hnsw.queryDistance(vector), n*oversampling, hnsw.ef(n*oversampling, opts), accept)
+
+	knearest := newKClosestNodesBy(n, hnsw.bottomLayer().distanceTo(vector))
+	for _, node := range candidates {
+		heap.Push(knearest, node)
+	}
+	return knearest.nodes
+}
+
+// nNearestAllowed scores the allowed points exactly, which beats walking the
+// graph when few points are allowed.
+func (hnsw *HnswCollection) nNearestAllowed(scratch *searchScratch, vector vectors.Vector, n int, opts SearchOptions) []*Node {
+	layer := hnsw.bottomLayer()
+	knearest := newKClosestNodesBy(n, scratch.count(layer.distanceTo(vector)))
+	for id, allowed := range opts.Allowed {
+		if !allowed {
+			continue
+		}
+		if scratch.stopped() {
+			break
+		}
+		node, ok := layer.Get(id)
+		if !ok || (opts.Filter != nil && !opts.Filter(node.Id, node.Value)) {
+			continue
+		}
+		heap.Push(knearest, node)
+	}
+	return knearest.nodes
+}
+
+// countAllowed counts the ids mapped to true, stopping once it is past limit.
+func countAllowed(allowed map[uint64]bool, limit int) int {
+	if len(allowed) <= limit {
+		return len(allowed)
+	}
+	n := 0
+	for _, ok := range allowed {
+		if ok {
+			n += 1
+			if n > limit {
+				break
+			}
+		}
+	}
+	return n
+}
+
+func (hnsw *HnswCollection) Search(vector vectors.Vector, k int) []SearchResult {
+	return hnsw.SearchWithOptions(vector, k, SearchOptions{})
+}
+
+func (hnsw *HnswCollection) SearchFiltered(vector vectors.Vector, k int, filter Filter) []SearchResult {
+	return hnsw.SearchWithOptions(vector, k, SearchOptions{Filter: filter})
+}
+
+func (hnsw *HnswCollection) SearchWithOptions(vector vectors.Vector, k int, opts SearchOptions) []SearchResult {
+	return toSearchResults(hnsw.nNearestWithOptions(nil, vector, k, opts), vector, hnsw.bottomLayer().DistanceFnc)
+}
+
+func (hnsw *HnswCollection) Get(id uint64) (Point, bool) {
+	node, ok := hnsw.bottomLayer().Get(id)
+	if !ok {
+		return Point{}, false
+	}
+	return node.point(), true
+}
+
+func (hnsw *HnswCollection) Len() int {
+	return len(hnsw.bottomLayer().nodes)
+}
+
+func (hnsw *HnswCollection) VectorDimension() int {
+	return hnsw.vectorDimension
+}
+
+func (hnsw *HnswCollection) Distance() distances.Distance {
+	return hnsw.bottomLayer().DistanceFnc
+}
+
+// Range calls fnc for every point until it returns false.
+func (hnsw *HnswCollection) Range(fnc func(point Point) bool) {
+	for _, node := range hnsw.bottomLayer().nodes {
+		if !fnc(node.point()) {
+			return
+		}
+	}
+}
+
+func (hnsw *HnswCollection) queryDistance(vector vectors.Vector) nodeDistance {
+	if hnsw.pq != nil && hnsw.pqCosine {
+		table := hnsw.pq.CosineTable(vector)
+		return func(node *Node) vectors.VFloat {
+			return table.Distance(node.Codes)
+		}
+	}
+	if hnsw.pq != nil {
+		table := hnsw.pq.DistanceTable(vector)
+		return func(node *Node) vectors.VFloat {
+			return table.Distance(node.Codes)
+		}
+	}
+	if hnsw.binary {
+		code := quantization.EncodeBinary(vector)
+		return func(node *Node) vectors.VFloat {
+			return vectors.VFloat(quantization.Hamming(code, node.Bits))
+		}
+	}
+	return hnsw.bottomLayer().distanceTo(vector)
+}
+
+func (hnsw *HnswCollection) ef(n int, opts SearchOptions) int {
+	if opts.Ef > 0 {
+		return opts.Ef
+	}
+	return n * hnsw.prefetchFactor
+}
+
+func (hnsw *HnswCollection) nNearestBy(scratch *searchScratch, distance nodeDistance, n int, ef int, accept func(node *Node) bool) []*Node {
+	distance = scratch.count(distance)
+	node := hnsw.entryPoint(scratch, distance)
 
 	if node == nil {
 		return []*Node{}
 	}
 
-	for i := 1; i < len(hnsw.layers)-1; i += 1 {
-		node = hnsw.layers[i].NearestFrom(vector, node.NextLevel)
+	return hnsw.bottomLayer().nNearestWith(scratch, node, n, ef, distance, accept)
+}
+
+// entryPoint descends the upper layers greedily to the node the bottom layer
+// search starts from. It stops early when the budget of scratch runs out.
+func (hnsw *HnswCollection) entryPoint(scratch *searchScratch, distance nodeDistance) *Node {
+	var node *Node
+	for _, layer := range hnsw.layers {
+		if node == nil {
+			node = layer.nearestWith(scratch, distance)
+		} else {
+			node = layer.nearestFromWith(scratch, node.NextLevel, distance)
+		}
 	}
+	return node
+}
 
-	return hnsw.layers[len(hnsw.layers)-1].NNearest(node.NextLevel, n, hnsw.prefetchFactor)
+func (hnsw *HnswCollection) bottomLayer() *Layer {
+	return hnsw.layers[len(hnsw.layers)-1]
 }
 
 func (hnsw *HnswCollection) findInsertIndex() int {
@@ -80,9 +337,18 @@ func (hnsw *HnswCollection) generateNewId() uint64 {
 }
 
 func (hnsw *HnswCollection) Remove(id uint64) bool {
+	hnsw.writeMu.Lock()
+	defer hnsw.writeMu.Unlock()
+
+	return hnsw.remove(id)
+}
+
+func (hnsw *HnswCollection) remove(id uint64) bool {
 	res := false
 	for _, layer := range hnsw.layers {
-		res = res || layer.Remove(id)
+		if layer.Remove(id) {
+			res = true
+		}
 	}
 	return res
 }
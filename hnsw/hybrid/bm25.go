@@ -0,0 +1,178 @@
+// Package hybrid combines vector search with keyword search: a BM25 text
+// index over an optional text field per point, and fusion of its ranking
+// with the ranking of a vector index.
+package hybrid
+
+import (
+	"container/heap"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	DefaultK1 = 1.2
+	DefaultB  = 0.75
+)
+
+// Tokenize lower-cases text and splits it on everything but letters and
+// digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+type TextHit struct {
+	Id    uint64
+	Score float64
+}
+
+// TextIndex is an inverted index ranking points with BM25. It is safe for
+// concurrent use.
+type TextIndex struct {
+	k1 float64
+	b  float64
+
+	mu       sync.RWMutex
+	postings map[string]map[uint64]int
+	// lengths holds the number of terms of every point and terms its
+	// distinct terms, so that removing a point only touches its postings.
+	lengths     map[uint64]int
+	terms       map[uint64][]string
+	totalLength int
+}
+
+// NewTextIndex uses the BM25 parameters k1, the term frequency saturation,
+// and b, the document length normalization. DefaultK1 and DefaultB suit most
+// catalogs.
+func NewTextIndex(k1 float64, b float64) *TextIndex {
+	return &TextIndex{
+		k1:       k1,
+		b:        b,
+		postings: map[string]map[uint64]int{},
+		lengths:  map[uint64]int{},
+		terms:    map[uint64][]string{},
+	}
+}
+
+// Set replaces the text of a point.
+func (idx *TextIndex) Set(id uint64, text string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+
+	terms := Tokenize(text)
+	distinct := []string{}
+	for _, term := range terms {
+		docs := idx.postings[term]
+		if docs == nil {
+			docs = map[uint64]int{}
+			idx.postings[term] = docs
+		}
+		if docs[id] == 0 {
+			distinct = append(distinct, term)
+		}
+		docs[id] += 1
+	}
+	idx.lengths[id] = len(terms)
+	idx.terms[id] = distinct
+	idx.totalLength += len(terms)
+}
+
+func (idx *TextIndex) Remove(id uint64) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.remove(id)
+}
+
+func (idx *TextIndex) remove(id uint64) bool {
+	length, ok := idx.lengths[id]
+	if !ok {
+		return false
+	}
+	for _, term := range idx.terms[id] {
+		docs := idx.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.lengths, id)
+	delete(idx.terms, id)
+	idx.totalLength -= length
+	return true
+}
+
+func (idx *TextIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.lengths)
+}
+
+// Search returns the k points with the highest BM25 score for query, best
+// first. Points matching no query term are not returned.
+func (idx *TextIndex) Search(query string, k int) []TextHit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := float64(len(idx.lengths))
+	if n == 0 {
+		return []TextHit{}
+	}
+	avgLength := float64(idx.totalLength) / n
+
+	scores := map[uint64]float64{}
+	for _, term := range Tokenize(query) {
+		docs := idx.postings[term]
+		if len(docs) == 0 {
+			continue
+		}
+		df := float64(len(docs))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range docs {
+			norm := idx.k1 * (1 - idx.b + idx.b*float64(idx.lengths[id])/avgLength)
+			scores[id] += idf * float64(tf) * (idx.k1 + 1) / (float64(tf) + norm)
+		}
+	}
+
+	return topHits(scores, k)
+}
+
+// hitHeap is a min-heap on score keeping the best hits seen so far.
+type hitHeap []TextHit
+
+func (h hitHeap) Len() int { return len(h) }
+func (h hitHeap) Less(i, j int) bool {
+	if h[i].Score != h[j].Score {
+		return h[i].Score < h[j].Score
+	}
+	return h[i].Id > h[j].Id
+}
+func (h hitHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *hitHeap) Push(x any)   { *h = append(*h, x.(TextHit)) }
+func (h *hitHeap) Pop() any {
+	old := *h
+	hit := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return hit
+}
+
+// topHits returns the k best scores, best first and ties by id.
+func topHits(scores map[uint64]float64, k int) []TextHit {
+	best := &hitHeap{}
+	for id, score := range scores {
+		heap.Push(best, TextHit{Id: id, Score: score})
+		if best.Len() > k {
+			heap.Pop(best)
+		}
+	}
+
+	hits := make([]TextHit, best.Len())
+	for i := len(hits) - 1; i >= 0; i -= 1 {
+		hits[i] = heap.Pop(best).(TextHit)
+	}
+	return hits
+}
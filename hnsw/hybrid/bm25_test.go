@@ -0,0 +1,59 @@
+package hybrid
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize("Red running-shoes, size 42! Übergröße")
+	expected := []string{"red", "running", "shoes", "size", "42", "übergröße"}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Fatalf("got %v, expected %v", tokens, expected)
+	}
+}
+
+func TestBM25(t *testing.T) {
+	idx := NewTextIndex(DefaultK1, DefaultB)
+	idx.Set(0, "red running shoes")
+	idx.Set(1, "blue running shoes for trail running")
+	idx.Set(2, "red dress")
+	idx.Set(3, "a very long description of red socks that goes on and on about socks")
+
+	hits := idx.Search("red shoes", 10)
+	if len(hits) != 4 || hits[0].Id != 0 {
+		t.Fatalf("unexpected hits %+v", hits)
+	}
+	for i := 1; i < len(hits); i += 1 {
+		if hits[i].Score > hits[i-1].Score {
+			t.Fatalf("hits not sorted: %+v", hits)
+		}
+	}
+	// The short document mentioning red ranks above the long one.
+	if hits[len(hits)-1].Id != 3 {
+		t.Fatalf("long document not ranked last: %+v", hits)
+	}
+
+	// Hand-computed score of the single-term query "dress": one document of
+	// four with length 2 against an average length of 6.25.
+	idf := math.Log(1 + (4-1+0.5)/(1+0.5))
+	expected := idf * (DefaultK1 + 1) / (1 + DefaultK1*(1-DefaultB+DefaultB*2/6.25))
+	hits = idx.Search("dress", 10)
+	if len(hits) != 1 || math.Abs(hits[0].Score-expected) > 1e-9 {
+		t.Fatalf("got %+v, expected score %f", hits, expected)
+	}
+
+	idx.Set(2, "green hat")
+	idx.Remove(0)
+	if hits := idx.Search("dress", 10); len(hits) != 0 {
+		t.Fatalf("replaced text still matches: %+v", hits)
+	}
+	hits = idx.Search("red shoes", 10)
+	if len(hits) != 2 || hits[0].Id != 1 || idx.Len() != 3 {
+		t.Fatalf("unexpected hits after removal %+v", hits)
+	}
+	if hits := idx.Search("", 10); len(hits) != 0 {
+		t.Fatalf("empty query matched %+v", hits)
+	}
+}
@@ -0,0 +1,149 @@
+package hybrid
+
+import (
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"math"
+	"sort"
+)
+
+type Fusion int
+
+const (
+	// ReciprocalRankFusion scores a point with the sum of 1/(RRFK + rank)
+	// over the rankings it appears in. It ignores the raw scores, so it needs
+	// no tuning across scales.
+	ReciprocalRankFusion Fusion = iota
+	// WeightedFusion maps BM25 scores and vector distances of the candidates
+	// to [0, 1] and adds them with TextWeight and 1-TextWeight. BM25 scores
+	// are divided by the best one, distances are min-max normalized so the
+	// nearest candidate gets 1.
+	WeightedFusion
+)
+
+const DefaultRRFK = 60
+
+type Options struct {
+	Fusion Fusion
+	// RRFK dampens the weight of the top ranks. Zero means DefaultRRFK.
+	RRFK int
+	// TextWeight is the share of the keyword score in WeightedFusion.
+	TextWeight float64
+	// Candidates is how many results are taken from each ranking before
+	// fusing. Zero means four times the requested number of results.
+	Candidates int
+}
+
+type Result struct {
+	Id    uint64
+	Score float64
+	// TextScore is the BM25 score, zero when the text did not match.
+	TextScore float64
+	// Distance is the vector distance, or +Inf when the point was not among
+	// the vector candidates.
+	Distance vectors.VFloat
+	Value    []byte
+}
+
+// Search ranks points by both the distance of their vector to vector and the
+// BM25 score of their text for query, and returns the k best fused results.
+// text is not kept in step with index, so text hits for ids the index no
+// longer holds are skipped.
+func Search(index hnsw.Index, text *TextIndex, vector vectors.Vector, query string, k int, opts Options) []Result {
+	candidates := opts.Candidates
+	if candidates <= 0 {
+		candidates = 4 * k
+	}
+
+	vectorHits := index.Search(vector, candidates)
+	textHits := []TextHit{}
+	values := map[uint64][]byte{}
+	for _, hit := range text.Search(query, candidates) {
+		point, ok := index.Get(hit.Id)
+		if !ok {
+			continue
+		}
+		textHits = append(textHits, hit)
+		values[hit.Id] = point.Value
+	}
+
+	results := map[uint64]*Result{}
+	result := func(id uint64) *Result {
+		r, ok := results[id]
+		if !ok {
+			r = &Result{Id: id, Distance: vectors.VFloat(math.Inf(1))}
+			results[id] = r
+		}
+		return r
+	}
+	for _, hit := range vectorHits {
+		r := result(hit.Id)
+		r.Distance = hit.Distance
+		r.Value = hit.Value
+	}
+	for _, hit := range textHits {
+		r := result(hit.Id)
+		r.TextScore = hit.Score
+		r.Value = values[hit.Id]
+	}
+
+	switch opts.Fusion {
+	case WeightedFusion:
+		fuseWeighted(results, vectorHits, textHits, opts.TextWeight)
+	default:
+		fuseRRF(results, vectorHits, textHits, opts.RRFK)
+	}
+
+	fused := make([]Result, 0, len(results))
+	for _, r := range results {
+		fused = append(fused, *r)
+	}
+	sort.Slice(fused, func(i, j int) bool {
+		if fused[i].Score != fused[j].Score {
+			return fused[i].Score > fused[j].Score
+		}
+		return fused[i].Id < fused[j].Id
+	})
+	if len(fused) > k {
+		fused = fused[:k]
+	}
+	return fused
+}
+
+func fuseRRF(results map[uint64]*Result, vectorHits []hnsw.SearchResult, textHits []TextHit, rrfK int) {
+	if rrfK <= 0 {
+		rrfK = DefaultRRFK
+	}
+	for rank, hit := range vectorHits {
+		results[hit.Id].Score += 1 / float64(rrfK+rank+1)
+	}
+	for rank, hit := range textHits {
+		results[hit.Id].Score += 1 / float64(rrfK+rank+1)
+	}
+}
+
+func fuseWeighted(results map[uint64]*Result, vectorHits []hnsw.SearchResult, textHits []TextHit, textWeight float64) {
+	if len(vectorHits) > 0 {
+		// Hits are sorted by distance, so the first is the most similar.
+		nearest := float64(vectorHits[0].Distance)
+		farthest := float64(vectorHits[len(vectorHits)-1].Distance)
+		for _, hit := range vectorHits {
+			results[hit.Id].Score += (1 - textWeight) * normalize(farthest-float64(hit.Distance), farthest-nearest)
+		}
+	}
+	if len(textHits) > 0 {
+		best := textHits[0].Score
+		for _, hit := range textHits {
+			results[hit.Id].Score += textWeight * normalize(hit.Score, best)
+		}
+	}
+}
+
+// normalize maps value from [0, span] to [0, 1]. When all candidates tie they
+// all get 1.
+func normalize(value float64, span float64) float64 {
+	if span == 0 {
+		return 1
+	}
+	return value / span
+}
@@ -0,0 +1,90 @@
+package hybrid
+
+import (
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"testing"
+)
+
+// catalog has a semantic cluster near (1, 0) and a keyword match far from it.
+func catalog() (hnsw.Index, *TextIndex) {
+	index := hnsw.NewFlatCollection(2, distances.Euclidian)
+	text := NewTextIndex(DefaultK1, DefaultB)
+
+	items := []struct {
+		vector vectors.Vector
+		text   string
+	}{
+		{vectors.Vector{1, 0}, "trail sneaker"},
+		{vectors.Vector{1, 0.1}, "road sneaker"},
+		{vectors.Vector{0.9, 0}, "running shoe waterproof"},
+		{vectors.Vector{-1, 0}, "waterproof jacket"},
+		{vectors.Vector{0, -1}, "garden hose"},
+	}
+	for _, item := range items {
+		id := index.Add(item.vector, []byte(item.text))
+		text.Set(id, item.text)
+	}
+	return index, text
+}
+
+func TestReciprocalRankFusion(t *testing.T) {
+	index, text := catalog()
+
+	res := Search(index, text, vectors.Vector{1, 0}, "waterproof", 3, Options{Candidates: 3})
+	if len(res) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(res))
+	}
+	// Point 2 is in both rankings and wins.
+	if res[0].Id != 2 {
+		t.Fatalf("expected point 2 first, got %+v", res)
+	}
+	// It is second in both: behind point 0 by distance, behind the shorter
+	// text of point 3 by BM25.
+	if res[0].Score != 2.0/62 {
+		t.Fatalf("unexpected RRF score %f", res[0].Score)
+	}
+	if string(res[0].Value) != "running shoe waterproof" {
+		t.Fatalf("value not filled: %q", res[0].Value)
+	}
+}
+
+func TestWeightedFusion(t *testing.T) {
+	index, text := catalog()
+
+	keyword := Search(index, text, vectors.Vector{1, 0}, "jacket", 1, Options{Fusion: WeightedFusion, TextWeight: 1})
+	if keyword[0].Id != 3 || string(keyword[0].Value) != "waterproof jacket" {
+		t.Fatalf("text-only weighting returned %+v", keyword)
+	}
+
+	semantic := Search(index, text, vectors.Vector{1, 0}, "jacket", 1, Options{Fusion: WeightedFusion, TextWeight: 0})
+	if semantic[0].Id != 0 {
+		t.Fatalf("vector-only weighting returned %+v", semantic)
+	}
+
+	mixed := Search(index, text, vectors.Vector{1, 0}, "waterproof", 5, Options{Fusion: WeightedFusion, TextWeight: 0.5})
+	if mixed[0].Id != 2 {
+		t.Fatalf("expected point 2 first, got %+v", mixed)
+	}
+	for _, r := range mixed {
+		if r.Score < 0 || r.Score > 1 {
+			t.Fatalf("score out of [0, 1]: %+v", r)
+		}
+	}
+}
+
+func TestRemovedPointsAreSkipped(t *testing.T) {
+	index, text := catalog()
+	index.Remove(3)
+
+	res := Search(index, text, vectors.Vector{0, -1}, "waterproof", 5, Options{Candidates: 5})
+	for _, r := range res {
+		if r.Id == 3 {
+			t.Fatalf("removed point 3 returned: %+v", res)
+		}
+		if r.Value == nil {
+			t.Fatalf("value not filled: %+v", r)
+		}
+	}
+}
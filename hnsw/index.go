@@ -0,0 +1,124 @@
+package hnsw
+
+import (
+	"fmt"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"io"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Index is implemented by every collection type so callers can swap an
+// approximate graph index for an exact scan.
+type Index interface {
+	Add(vector vectors.Vector, value []byte) uint64
+	AddBatch(vectors []vectors.Vector, values [][]byte) []uint64
+	Upsert(id uint64, vector vectors.Vector, value []byte)
+	UpsertBatch(points []Point)
+	Remove(id uint64) bool
+	Get(id uint64) (Point, bool)
+	Search(vector vectors.Vector, k int) []SearchResult
+	SearchFiltered(vector vectors.Vector, k int, filter Filter) []SearchResult
+	Len() int
+	VectorDimension() int
+	Range(fnc func(point Point) bool)
+	Save(writer io.Writer) error
+}
+
+// Point and SearchResult hold copies of the stored vector and value, so
+// changing them does not change the collection.
+type Point struct {
+	Id     uint64
+	Vector vectors.Vector
+	Value  []byte
+}
+
+func (node *Node) point() Point {
+	return Point{Id: node.Id, Vector: slices.Clone(node.Vector), Value: slices.Clone(node.Value)}
+}
+
+type SearchResult struct {
+	Id       uint64
+	Distance vectors.VFloat
+	Vector   vectors.Vector
+	Value    []byte
+}
+
+// Filter reports whether a point may be returned by a search.
+type Filter func(id uint64, value []byte) bool
+
+const DefaultOversampling = 4
+
+// MaxK bounds the results of a search, and MaxEf and MaxOversampling the
+// candidates it may walk through to find them. The HTTP and gRPC servers
+// reject searches above them.
+const (
+	MaxK            = 1000
+	MaxEf           = 10000
+	MaxOversampling = 100
+)
+
+type SearchOptions struct {
+	// Oversampling is the number of quantized candidates fetched per requested
+	// result and re-scored with the exact distance. Zero means
+	// DefaultOversampling and one disables re-scoring.
+	Oversampling int
+	// Ef is the number of candidates kept while walking the bottom layer. Zero
+	// means the requested number of results times the prefetch factor.
+	Ef     int
+	Filter Filter
+	// Allowed, when not nil, restricts results to the ids mapped to true. If
+	// it has no more of them than the search would visit candidates, they are
+	// scored exactly instead of walking the graph.
+	Allowed map[uint64]bool
+	// TimeBudget and DistanceBudget bound the work of SearchContext, which
+	// returns the best points found when one runs out. DistanceBudget counts
+	// the distances computed in every layer, but not the exact re-scoring of
+	// quantized candidates. Zero means no bound.
+	TimeBudget     time.Duration
+	DistanceBudget int
+}
+
+func validateBatch(vectorDimension int, vectors []vectors.Vector, values [][]byte) {
+	if values != nil && len(values) != len(vectors) {
+		panic(fmt.Sprintf("Expected %d values but %d found", len(vectors), len(values)))
+	}
+	for _, vector := range vectors {
+		if len(vector) != vectorDimension {
+			panic(fmt.Sprintf("Vector dimension must be %d", vectorDimension))
+		}
+	}
+}
+
+func validatePoints(vectorDimension int, points []Point) {
+	for _, point := range points {
+		if len(point.Vector) != vectorDimension {
+			panic(fmt.Sprintf("Vector dimension must be %d", vectorDimension))
+		}
+	}
+}
+
+func batchValue(values [][]byte, i int) []byte {
+	if values == nil {
+		return nil
+	}
+	return values[i]
+}
+
+func toSearchResults(nodes []*Node, vector vectors.Vector, distanceFnc distances.Distance) []SearchResult {
+	results := make([]SearchResult, len(nodes))
+	for i, node := range nodes {
+		results[i] = SearchResult{
+			Id:       node.Id,
+			Distance: distanceFnc(vector, node.Vector),
+			Vector:   slices.Clone(node.Vector),
+			Value:    slices.Clone(node.Value),
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Distance < results[j].Distance
+	})
+	return results
+}
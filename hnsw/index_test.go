@@ -0,0 +1,240 @@
+package hnsw
+
+import (
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"sort"
+	"testing"
+)
+
+func testIndexes(dimension int) map[string]Index {
+	return map[string]Index{
+		"hnsw": NewHnswCollection(3, dimension, distances.Euclidian, 8, 3),
+		"flat": NewFlatCollection(dimension, distances.Euclidian),
+	}
+}
+
+func TestIndexSearch(t *testing.T) {
+	for name, index := range testIndexes(2) {
+		for i := 0; i < 10; i += 1 {
+			index.Add(vectors.Vector{vectors.VFloat(i), 0}, []byte{byte(i)})
+		}
+
+		res := index.Search(vectors.Vector{0, 0}, 3)
+		if len(res) != 3 {
+			t.Fatalf("%s: number of results expected to be 3 but found %d", name, len(res))
+		}
+
+		isSorted := sort.SliceIsSorted(res, func(i, j int) bool {
+			return res[i].Distance < res[j].Distance
+		})
+		if !isSorted {
+			t.Fatalf("%s: results must be sorted by distance", name)
+		}
+
+		for i, r := range res {
+			if r.Id != uint64(i) || r.Distance != vectors.VFloat(i) || r.Value[0] != byte(i) {
+				t.Fatalf("%s: unexpected result %d: %+v", name, i, r)
+			}
+		}
+	}
+}
+
+func TestIndexSearchWithoutK(t *testing.T) {
+	for name, index := range testIndexes(2) {
+		for i := 0; i < 10; i += 1 {
+			index.Add(vectors.Vector{vectors.VFloat(i), 0}, nil)
+		}
+
+		for _, k := range []int{0, -1} {
+			if res := index.Search(vectors.Vector{0, 0}, k); len(res) != 0 {
+				t.Fatalf("%s: no results expected for k = %d but found %d", name, k, len(res))
+			}
+		}
+	}
+
+	hnsw := newLineCollection()
+	for _, k := range []int{0, -1} {
+		if res := hnsw.SearchMMR(vectors.Vector{0, 0}, k, MMROptions{Lambda: 0.5}); len(res) != 0 {
+			t.Fatalf("No MMR results expected for k = %d but found %d", k, len(res))
+		}
+		for _, strategy := range []RecommendStrategy{AverageVector, BestScore} {
+			res, err := hnsw.Recommend([]uint64{1}, nil, k, RecommendOptions{Strategy: strategy})
+			if err != nil || len(res) != 0 {
+				t.Fatalf("No recommendations expected for k = %d but found %v, %v", k, res, err)
+			}
+		}
+	}
+}
+
+func TestIndexSearchFiltered(t *testing.T) {
+	for name, index := range testIndexes(2) {
+		for i := 0; i < 50; i += 1 {
+			index.Add(vectors.Vector{vectors.VFloat(i), 0}, []byte{byte(i % 5)})
+		}
+
+		res := index.SearchFiltered(vectors.Vector{0, 0}, 4, func(id uint64, value []byte) bool {
+			return value[0] == 3
+		})
+
+		if len(res) != 4 {
+			t.Fatalf("%s: number of results expected to be 4 but found %d", name, len(res))
+		}
+		for i, r := range res {
+			if r.Id != uint64(3+i*5) {
+				t.Fatalf("%s: result %d expected to have id %d but %d found", name, i, 3+i*5, r.Id)
+			}
+		}
+	}
+}
+
+func TestSearchAllowedSkipsFalseEntries(t *testing.T) {
+	hnsw := NewHnswCollection(3, 2, distances.Euclidian, 8, 3)
+	for i := 0; i < 100; i += 1 {
+		hnsw.Add(vectors.Vector{vectors.VFloat(i), 0}, nil)
+	}
+
+	// Few allowed ids take the exact path, many take the graph path.
+	for _, n := range []int{5, 90} {
+		allowed := map[uint64]bool{}
+		for id := uint64(0); id < uint64(n); id += 1 {
+			allowed[id] = id%2 == 1
+		}
+
+		res := hnsw.SearchWithOptions(vectors.Vector{0, 0}, 2, SearchOptions{Allowed: allowed})
+		if len(res) != 2 || res[0].Id != 1 || res[1].Id != 3 {
+			t.Fatalf("%d allowed ids: expected points 1, 3 but found %v", n, res)
+		}
+	}
+}
+
+func TestIndexGetAndLen(t *testing.T) {
+	for name, index := range testIndexes(2) {
+		id := index.Add(vectors.Vector{1, 2}, []byte("v1"))
+		index.Add(vectors.Vector{3, 4}, []byte("v2"))
+
+		if index.Len() != 2 {
+			t.Fatalf("%s: length expected to be 2 but %d found", name, index.Len())
+		}
+
+		point, ok := index.Get(id)
+		if !ok || string(point.Value) != "v1" || point.Vector[1] != 2 {
+			t.Fatalf("%s: unexpected point %+v", name, point)
+		}
+
+		index.Remove(id)
+
+		if _, ok = index.Get(id); ok {
+			t.Fatalf("%s: removed point must not be found", name)
+		}
+		if index.Len() != 1 {
+			t.Fatalf("%s: length expected to be 1 but %d found", name, index.Len())
+		}
+	}
+}
+
+func TestIndexReturnsCopies(t *testing.T) {
+	for name, index := range testIndexes(2) {
+		id := index.Add(vectors.Vector{1, 2}, []byte("v1"))
+
+		point, _ := index.Get(id)
+		point.Vector[0], point.Value[0] = 9, 'x'
+		index.Range(func(point Point) bool {
+			point.Vector[0], point.Value[0] = 9, 'x'
+			return true
+		})
+		res := index.Search(vectors.Vector{1, 2}, 1)
+		res[0].Vector[0], res[0].Value[0] = 9, 'x'
+
+		point, _ = index.Get(id)
+		if point.Vector[0] != 1 || string(point.Value) != "v1" {
+			t.Fatalf("%s: changing returned points must not change the stored one but %+v found", name, point)
+		}
+	}
+}
+
+func TestHnswRemoveFromAllLayers(t *testing.T) {
+	hnswCollection := NewHnswCollection(3, 2, distances.Euclidian, 5, 3)
+
+	id := hnswCollection.Add(vectors.Vector{1, 1}, []byte("first"))
+	hnswCollection.Add(vectors.Vector{2, 2}, []byte("second"))
+
+	hnswCollection.Remove(id)
+
+	for i, layer := range hnswCollection.layers {
+		if _, ok := layer.Get(id); ok {
+			t.Fatalf("Removed node is still present in layer %d", i)
+		}
+	}
+}
+
+func TestIndexAddBatchAndRange(t *testing.T) {
+	for name, index := range testIndexes(2) {
+		ids := index.AddBatch([]vectors.Vector{{1, 1}, {2, 2}, {3, 3}}, [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+
+		if len(ids) != 3 || index.Len() != 3 {
+			t.Fatalf("%s: expected 3 added points but %d found", name, index.Len())
+		}
+
+		values := map[uint64]string{}
+		index.Range(func(point Point) bool {
+			values[point.Id] = string(point.Value)
+			return true
+		})
+		if values[ids[0]] != "a" || values[ids[1]] != "b" || values[ids[2]] != "c" {
+			t.Fatalf("%s: unexpected ranged values %v", name, values)
+		}
+
+		visited := 0
+		index.Range(func(point Point) bool {
+			visited += 1
+			return false
+		})
+		if visited != 1 {
+			t.Fatalf("%s: Range must stop when the callback returns false", name)
+		}
+	}
+}
+
+func TestAddBatchIsAtomicOnWrongDimension(t *testing.T) {
+	for name, index := range testIndexes(2) {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("%s: 'AddBatch' must panic on wrong vector size", name)
+				}
+			}()
+			index.AddBatch([]vectors.Vector{{1, 1}, {1, 2, 3}}, nil)
+		}()
+
+		if index.Len() != 0 {
+			t.Fatalf("%s: no vectors must be added from an invalid batch", name)
+		}
+	}
+}
+
+func TestIndexUpsert(t *testing.T) {
+	for name, index := range testIndexes(2) {
+		index.Upsert(10, vectors.Vector{1, 1}, []byte("a"))
+		index.Upsert(10, vectors.Vector{5, 5}, []byte("b"))
+		index.UpsertBatch([]Point{{Id: 3, Vector: vectors.Vector{2, 2}, Value: []byte("c")}})
+
+		if index.Len() != 2 {
+			t.Fatalf("%s: length expected to be 2 but %d found", name, index.Len())
+		}
+
+		point, ok := index.Get(10)
+		if !ok || string(point.Value) != "b" || point.Vector[0] != 5 {
+			t.Fatalf("%s: upserted point must be replaced but %+v found", name, point)
+		}
+
+		res := index.Search(vectors.Vector{5, 5}, 1)
+		if res[0].Id != 10 {
+			t.Fatalf("%s: nearest point expected to be 10 but %d found", name, res[0].Id)
+		}
+
+		if id := index.Add(vectors.Vector{0, 0}, nil); id != 11 {
+			t.Fatalf("%s: generated id expected to be 11 but %d found", name, id)
+		}
+	}
+}
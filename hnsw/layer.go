@@ -3,6 +3,7 @@ package hnsw
 import (
 	"container/heap"
 	"encoding/binary"
+	"fmt"
 	"go-hnsw/hnsw/vectors"
 	"go-hnsw/hnsw/vectors/distances"
 	"io"
@@ -13,6 +14,9 @@ type Layer struct {
 	nodes       []*Node
 	DistanceFnc distances.Distance
 	rindex      map[uint64]int
+	// snapshot is the running Snapshot, if any, that must see neighbor maps
+	// as they were when it started.
+	snapshot *snapshotState
 }
 
 func NewLayer(distanceFnc distances.Distance) *Layer {
@@ -27,16 +31,25 @@ func (layer *Layer) IsEmpty() bool {
 }
 
 func (layer *Layer) Add(id uint64, vector vectors.Vector, value []byte, connectivity int) *Node {
+	return layer.link(id, vector, value, layer.neighborsFor(nil, vector, connectivity))
+}
 
-	nearestNode := layer.Nearest(vector)
+// neighborsFor finds the nodes a new node with the vector is linked to.
+func (layer *Layer) neighborsFor(scratch *searchScratch, vector vectors.Vector, connectivity int) []*Node {
+	distance := scratch.count(layer.distanceTo(vector))
+	nearestNode := layer.nearestWith(scratch, distance)
+	if nearestNode == nil {
+		return nil
+	}
+	return layer.nNearestWith(scratch, nearestNode, connectivity, connectivity*3, distance, nil)
+}
 
+func (layer *Layer) link(id uint64, vector vectors.Vector, value []byte, neighbors []*Node) *Node {
 	newNode := Node{Id: id, Vector: vector, Value: value, Layer: layer, neighbors: map[uint64]*Node{}}
-	if nearestNode != nil {
-		neighbors := layer.NNearest(nearestNode, connectivity, 3)
-		for _, nbh := range neighbors {
-			newNode.neighbors[nbh.Id] = nbh
-			nbh.neighbors[id] = &newNode
-		}
+	for _, nbh := range neighbors {
+		newNode.neighbors[nbh.Id] = nbh
+		layer.snapshot.preserve(nbh)
+		nbh.neighbors[id] = &newNode
 	}
 	layer.nodes = append(layer.nodes, &newNode)
 
@@ -46,76 +59,134 @@ func (layer *Layer) Add(id uint64, vector vectors.Vector, value []byte, connecti
 }
 
 func (layer *Layer) NNearest(node *Node, n int, overfetchFactor int) []*Node {
-	nearestNodes := make([]*Node, n*overfetchFactor)
-	start, end := 0, 1
+	return layer.nNearestBy(node, n, n*overfetchFactor, layer.distanceTo(node.Vector), nil)
+}
 
-	nearestNodes[0] = node
-	added := true
-	visited := map[uint64]bool{}
+func (layer *Layer) distanceTo(vector vectors.Vector) nodeDistance {
+	return func(node *Node) vectors.VFloat {
+		return layer.DistanceFnc(vector, node.Vector)
+	}
+}
+
+func (layer *Layer) nNearestBy(node *Node, n int, ef int, distance nodeDistance, accept func(node *Node) bool) []*Node {
+	return layer.nNearestWith(nil, node, n, ef, distance, accept)
+}
+
+// nNearestWith is nNearestBy reusing the buffers of scratch, if not nil. The
+// returned slice is then only valid until scratch is used again.
+func (layer *Layer) nNearestWith(scratch *searchScratch, node *Node, n int, ef int, distance nodeDistance, accept func(node *Node) bool) []*Node {
+	if scratch == nil {
+		scratch = newSearchScratch()
+	}
+	if scratch.budget.spent() {
+		if accept == nil || accept(node) {
+			return []*Node{node}
+		}
+		return []*Node{}
+	}
+	ef = max(ef, n)
+	nearest := scratch.reset(ef, distance)
+
+	start := candidate{node: node, distance: distance(node)}
+	candidates := &scratch.candidates
+	heap.Push(candidates, start)
+	if accept == nil || accept(node) {
+		heap.Push(nearest, start)
+	}
+
+	visited := scratch.visited
 	visited[node.Id] = true
-	for added {
-		lEnd := end
-		added = false
-		for start < lEnd && end < len(nearestNodes) {
-			currentNode := nearestNodes[start]
-			for _, nd := range currentNode.neighbors {
-				_, seen := visited[nd.Id]
-				if seen {
-					continue
-				}
-				visited[nd.Id] = true
-				nearestNodes[end] = nd
-				added = true
-				end += 1
-				if end == len(nearestNodes) {
-					break
+	for candidates.Len() > 0 {
+		if scratch.budget.exhausted() {
+			break
+		}
+		current := heap.Pop(candidates).(candidate)
+		if nearest.Len() == ef && current.distance > nearest.distances[0] {
+			break
+		}
+
+		for _, nbh := range current.node.neighbors {
+			_, seen := visited[nbh.Id]
+			if seen {
+				continue
+			}
+			if scratch.budget.spent() {
+				break
+			}
+			visited[nbh.Id] = true
+
+			next := candidate{node: nbh, distance: distance(nbh)}
+			if nearest.Len() < ef || next.distance < nearest.distances[0] {
+				heap.Push(candidates, next)
+				if accept == nil || accept(nbh) {
+					heap.Push(nearest, next)
 				}
 			}
-			start += 1
 		}
 	}
 
-	knearest := NewKClosestNodes(n, node.Vector, layer.DistanceFnc)
-
-	for _, n := range nearestNodes[:end] {
-		heap.Push(knearest, n)
+	for nearest.Len() > n {
+		heap.Pop(nearest)
 	}
 
-	return knearest.nodes
+	return nearest.nodes
 }
 
 func (layer *Layer) Nearest(vector vectors.Vector) *Node {
+	return layer.nearestBy(layer.distanceTo(vector))
+}
+
+func (layer *Layer) NearestFrom(vector vectors.Vector, startNode *Node) *Node {
+	return layer.nearestFromBy(startNode, layer.distanceTo(vector))
+}
+
+func (layer *Layer) nearestBy(distanceFnc nodeDistance) *Node {
+	return layer.nearestWith(nil, distanceFnc)
+}
+
+func (layer *Layer) nearestWith(scratch *searchScratch, distanceFnc nodeDistance) *Node {
 	if len(layer.nodes) == 0 {
 		return nil
 	}
 
 	nth := rand.IntN(len(layer.nodes))
-	node := layer.nodes[nth]
 
-	return layer.NearestFrom(vector, node)
+	return layer.nearestFromWith(scratch, layer.nodes[nth], distanceFnc)
 }
 
-func (layer *Layer) NearestFrom(vector vectors.Vector, startNode *Node) *Node {
+func (layer *Layer) nearestFromBy(startNode *Node, distanceFnc nodeDistance) *Node {
+	return layer.nearestFromWith(nil, startNode, distanceFnc)
+}
+
+// nearestFromWith is nearestFromBy that stops at the nearest node found so
+// far when the budget of scratch, if any, runs out.
+func (layer *Layer) nearestFromWith(scratch *searchScratch, startNode *Node, distanceFnc nodeDistance) *Node {
 	if len(layer.nodes) == 0 {
 		return nil
 	}
+	if scratch.spent() {
+		return startNode
+	}
 
-	distance := layer.DistanceFnc(startNode.Vector, vector)
+	distance := distanceFnc(startNode)
 
 	visited := map[uint64]bool{}
 	visited[startNode.Id] = true
 
 	node := startNode
 
-	for {
+	for !scratch.stopped() {
 		updated := false
 		for _, nbh := range node.neighbors {
 			_, seen := visited[nbh.Id]
 			if seen {
 				continue
 			}
+			if scratch.spent() {
+				break
+			}
 			visited[nbh.Id] = true
-			dst := layer.DistanceFnc(vector, nbh.Vector)
+			dst := distanceFnc(nbh)
 			if dst <= distance {
 				distance = dst
 				node = nbh
@@ -142,6 +213,7 @@ func (layer *Layer) Remove(id uint64) bool {
 	delete(layer.rindex, id)
 
 	for _, neighbor := range delNode.neighbors {
+		layer.snapshot.preserve(neighbor)
 		delete(neighbor.neighbors, id)
 	}
 	return true
@@ -198,8 +270,12 @@ func DesserializeLayer(reader io.Reader, distanceFnc distances.Distance, nextLay
 		return nil, err
 	}
 
+	if nNodes < 0 {
+		return nil, fmt.Errorf("negative node count %d", nNodes)
+	}
+
 	layer := Layer{
-		nodes:       make([]*Node, nNodes),
+		nodes:       make([]*Node, 0, min(int(nNodes), readChunk)),
 		rindex:      map[uint64]int{},
 		DistanceFnc: distanceFnc,
 	}
@@ -209,10 +285,14 @@ func DesserializeLayer(reader io.Reader, distanceFnc distances.Distance, nextLay
 		if err != nil {
 			return nil, err
 		}
+		if _, ok := layer.rindex[node.Id]; ok {
+			return nil, fmt.Errorf("duplicate node %d", node.Id)
+		}
 		if nextLayer != nil {
 			node.NextLevel, _ = nextLayer.Get(node.Id)
 		}
-		layer.nodes[i] = node
+		node.Layer = &layer
+		layer.nodes = append(layer.nodes, node)
 		layer.rindex[node.Id] = i
 
 		for id, _ := range node.neighbors {
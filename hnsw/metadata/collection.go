@@ -0,0 +1,215 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"go-hnsw/internal/checksum"
+	"io"
+	"sync"
+)
+
+// Collection keeps an Index in step with a graph: points are added, replaced,
+// removed, saved and loaded together with their metadata. A bare Index has
+// to be kept in step by the caller.
+type Collection struct {
+	mu       sync.Mutex
+	index    *hnsw.HnswCollection
+	metadata *Index
+}
+
+// NewCollection wraps an empty collection.
+func NewCollection(index *hnsw.HnswCollection) *Collection {
+	if index.Len() != 0 {
+		panic("Collection must be empty")
+	}
+	return &Collection{index: index, metadata: NewIndex()}
+}
+
+func (c *Collection) Add(vector vectors.Vector, value []byte, metadata Metadata) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.index.Add(vector, value)
+	err := c.metadata.Set(id, metadata)
+	if err != nil {
+		c.index.Remove(id)
+		return 0, err
+	}
+	return id, nil
+}
+
+// Upsert replaces the point and its metadata. Nothing changes when the
+// metadata does not match the kinds of the fields.
+func (c *Collection) Upsert(id uint64, vector vectors.Vector, value []byte, metadata Metadata) error {
+	if len(vector) != c.index.VectorDimension() {
+		panic(fmt.Sprintf("Vector dimension must be %d", c.index.VectorDimension()))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.metadata.Set(id, metadata)
+	if err != nil {
+		return err
+	}
+	c.index.Upsert(id, vector, value)
+	return nil
+}
+
+func (c *Collection) Remove(id uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.metadata.Remove(id)
+	return c.index.Remove(id)
+}
+
+func (c *Collection) Get(id uint64) (hnsw.Point, Metadata, bool) {
+	point, ok := c.index.Get(id)
+	if !ok {
+		return hnsw.Point{}, nil, false
+	}
+	metadata, _ := c.metadata.Get(id)
+	return point, metadata, true
+}
+
+func (c *Collection) Len() int {
+	return c.index.Len()
+}
+
+// Search returns the nearest points matching expr, or any points when expr
+// is nil.
+func (c *Collection) Search(vector vectors.Vector, k int, expr Expr) ([]hnsw.SearchResult, error) {
+	return c.SearchWithOptions(vector, k, expr, hnsw.SearchOptions{})
+}
+
+// SearchWithOptions combines expr with the Allowed set of opts, if any.
+func (c *Collection) SearchWithOptions(vector vectors.Vector, k int, expr Expr, opts hnsw.SearchOptions) ([]hnsw.SearchResult, error) {
+	if expr != nil {
+		allowed, err := c.metadata.Evaluate(expr)
+		if err != nil {
+			return nil, err
+		}
+		for id := range allowed {
+			if opts.Allowed != nil && !opts.Allowed[id] {
+				delete(allowed, id)
+			}
+		}
+		opts.Allowed = allowed
+	}
+	return c.index.SearchWithOptions(vector, k, opts), nil
+}
+
+// Graph returns the wrapped collection. Changing it directly leaves the
+// metadata behind.
+func (c *Collection) Graph() *hnsw.HnswCollection {
+	return c.index
+}
+
+func (c *Collection) Metadata() *Index {
+	return c.metadata
+}
+
+// savedValue is how a Value is written by Save.
+type savedValue struct {
+	Kind Kind     `json:"kind"`
+	Str  string   `json:"str,omitempty"`
+	Num  float64  `json:"num,omitempty"`
+	Bool bool     `json:"bool,omitempty"`
+	List []string `json:"list,omitempty"`
+}
+
+// Save writes the collection as hnsw.HnswCollection.Save does, followed by
+// the metadata of every point.
+func (c *Collection) Save(writer io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.index.Save(writer)
+	if err != nil {
+		return err
+	}
+
+	saved := map[uint64]map[string]savedValue{}
+	c.metadata.mu.RLock()
+	for id, metadata := range c.metadata.points {
+		fields := map[string]savedValue{}
+		for field, v := range metadata {
+			fields[field] = savedValue{Kind: v.kind, Str: v.str, Num: v.num, Bool: v.boolean, List: v.list}
+		}
+		saved[id] = fields
+	}
+	c.metadata.mu.RUnlock()
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return checksum.Write(writer, func(w io.Writer) error {
+		err := binary.Write(w, binary.LittleEndian, uint64(len(data)))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+}
+
+// maxMetadataSize bounds the metadata block read by LoadCollection. The
+// block is read as it arrives, so a corrupt size fails at the end of the
+// data rather than allocating that much up front.
+const maxMetadataSize = 1 << 30
+
+// LoadCollection reads a collection written by Collection.Save. The distance
+// is passed to hnsw.LoadHnswCollection.
+func LoadCollection(reader io.Reader, distance distances.Distance) (*Collection, error) {
+	index, err := hnsw.LoadHnswCollection(reader, distance)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved map[uint64]map[string]savedValue
+	err = checksum.Read(reader, func(r io.Reader) error {
+		var size uint64
+		err := binary.Read(r, binary.LittleEndian, &size)
+		if err != nil {
+			return err
+		}
+		if size > maxMetadataSize {
+			return fmt.Errorf("metadata block of %d bytes is too large", size)
+		}
+		data := new(bytes.Buffer)
+		_, err = io.CopyN(data, r, int64(size))
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data.Bytes(), &saved)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading metadata: %w", err)
+	}
+
+	c := &Collection{index: index, metadata: NewIndex()}
+	for id, fields := range saved {
+		if _, ok := index.Get(id); !ok {
+			return nil, fmt.Errorf("metadata of missing point %d", id)
+		}
+		metadata := Metadata{}
+		for field, v := range fields {
+			metadata[field] = Value{kind: v.Kind, str: v.Str, num: v.Num, boolean: v.Bool, list: v.List}
+		}
+		err = c.metadata.Set(id, metadata)
+		if err != nil {
+			return nil, fmt.Errorf("reading metadata of point %d: %w", id, err)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,76 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"reflect"
+	"testing"
+)
+
+func TestCollectionKeepsMetadataInStep(t *testing.T) {
+	c := NewCollection(hnsw.NewHnswCollection(3, 2, distances.Euclidian, 8, 4))
+	for i := 0; i < 20; i += 1 {
+		_, err := c.Add(vectors.Vector{vectors.VFloat(i), 0}, nil, Metadata{"even": Bool(i%2 == 0), "tags": Strings("a", "b")})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := c.Add(vectors.Vector{0, 1}, nil, Metadata{"even": Int(1)}); err == nil {
+		t.Fatalf("expected an error on a field of another kind")
+	}
+	if c.Len() != 20 {
+		t.Fatalf("failed Add must not add the point, found %d points", c.Len())
+	}
+
+	res, err := c.Search(vectors.Vector{0, 0}, 2, Eq("even", Bool(false)))
+	if err != nil || len(res) != 2 || res[0].Id != 1 || res[1].Id != 3 {
+		t.Fatalf("expected points 1, 3, got %v, %v", res, err)
+	}
+
+	c.Remove(1)
+	if _, _, ok := c.Get(1); ok || c.Metadata().Len() != 19 {
+		t.Fatalf("removed point must lose its metadata")
+	}
+
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCollection(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, metadata, ok := loaded.Get(4)
+	if !ok || !reflect.DeepEqual(metadata, Metadata{"even": Bool(true), "tags": Strings("a", "b")}) {
+		t.Fatalf("metadata not restored: %v", metadata)
+	}
+	res, _ = loaded.Search(vectors.Vector{0, 0}, 1, Eq("even", Bool(false)))
+	if len(res) != 1 || res[0].Id != 3 {
+		t.Fatalf("expected point 3 after load, got %v", res)
+	}
+}
+
+func TestLoadCollectionDetectsDamage(t *testing.T) {
+	c := NewCollection(hnsw.NewHnswCollection(3, 2, distances.Euclidian, 8, 4))
+	c.Add(vectors.Vector{1, 2}, nil, Metadata{"category": String("books")})
+
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved := buf.Bytes()
+
+	corrupted := bytes.Clone(saved)
+	corrupted[len(corrupted)-6] ^= 0xff
+	if _, err := LoadCollection(bytes.NewReader(corrupted), nil); !errors.Is(err, hnsw.ErrChecksumMismatch) {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+
+	if _, err := LoadCollection(bytes.NewReader(saved[:len(saved)-10]), nil); err == nil {
+		t.Fatalf("expected truncated metadata to fail")
+	}
+}
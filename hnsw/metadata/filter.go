@@ -0,0 +1,262 @@
+package metadata
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Expr is a node of a filter expression, for example
+//
+//	And(In("category", String("books"), String("music")), Lt("price", 50))
+//
+// for category IN ("books", "music") AND price < 50.
+type Expr interface {
+	eval(idx *Index) (map[uint64]bool, error)
+	String() string
+}
+
+type eqExpr struct {
+	field  string
+	values []Value
+}
+
+// Eq matches points whose field equals value, or contains it for keyword
+// lists.
+func Eq(field string, value Value) Expr {
+	return eqExpr{field: field, values: []Value{value}}
+}
+
+// In matches points whose field equals any of the values.
+func In(field string, values ...Value) Expr {
+	return eqExpr{field: field, values: values}
+}
+
+func (e eqExpr) eval(idx *Index) (map[uint64]bool, error) {
+	res := map[uint64]bool{}
+	kind, ok := idx.kinds[e.field]
+	if !ok {
+		return res, nil
+	}
+
+	for _, value := range e.values {
+		if value.numeric() {
+			if kind != KindInt && kind != KindFloat {
+				return nil, fmt.Errorf("field %s holds %s values, compared with %s", e.field, kind, value.kind)
+			}
+			for id := range idx.between(e.field, value.num, value.num, true, true) {
+				res[id] = true
+			}
+			continue
+		}
+
+		comparable := (value.kind == KindString && (kind == KindString || kind == KindStrings)) ||
+			(value.kind == KindBool && kind == KindBool)
+		if !comparable {
+			return nil, fmt.Errorf("field %s holds %s values, compared with %s", e.field, kind, value.kind)
+		}
+		for _, key := range value.keys() {
+			for id := range idx.inverted[e.field][key] {
+				res[id] = true
+			}
+		}
+	}
+	return res, nil
+}
+
+func (e eqExpr) String() string {
+	if len(e.values) == 1 {
+		return e.field + " = " + e.values[0].String()
+	}
+	values := make([]string, len(e.values))
+	for i, v := range e.values {
+		values[i] = v.String()
+	}
+	return e.field + " IN (" + strings.Join(values, ", ") + ")"
+}
+
+type rangeExpr struct {
+	field    string
+	min, max float64
+	minIncl  bool
+	maxIncl  bool
+}
+
+func Lt(field string, v float64) Expr {
+	return rangeExpr{field: field, min: math.Inf(-1), max: v, minIncl: true}
+}
+
+func Lte(field string, v float64) Expr {
+	return rangeExpr{field: field, min: math.Inf(-1), max: v, minIncl: true, maxIncl: true}
+}
+
+func Gt(field string, v float64) Expr {
+	return rangeExpr{field: field, min: v, max: math.Inf(1), maxIncl: true}
+}
+
+func Gte(field string, v float64) Expr {
+	return rangeExpr{field: field, min: v, max: math.Inf(1), minIncl: true, maxIncl: true}
+}
+
+// Between matches min <= field <= max.
+func Between(field string, min float64, max float64) Expr {
+	return rangeExpr{field: field, min: min, max: max, minIncl: true, maxIncl: true}
+}
+
+func (e rangeExpr) eval(idx *Index) (map[uint64]bool, error) {
+	kind, ok := idx.kinds[e.field]
+	if !ok {
+		return map[uint64]bool{}, nil
+	}
+	if kind != KindInt && kind != KindFloat {
+		return nil, fmt.Errorf("range over field %s holding %s values", e.field, kind)
+	}
+	return idx.between(e.field, e.min, e.max, e.minIncl, e.maxIncl), nil
+}
+
+func (e rangeExpr) String() string {
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	switch {
+	case math.IsInf(e.min, -1) && e.maxIncl:
+		return e.field + " <= " + format(e.max)
+	case math.IsInf(e.min, -1):
+		return e.field + " < " + format(e.max)
+	case math.IsInf(e.max, 1) && e.minIncl:
+		return e.field + " >= " + format(e.min)
+	case math.IsInf(e.max, 1):
+		return e.field + " > " + format(e.min)
+	}
+	return e.field + " BETWEEN " + format(e.min) + " AND " + format(e.max)
+}
+
+// between scans the sorted index of a numeric field.
+func (idx *Index) between(field string, min float64, max float64, minIncl bool, maxIncl bool) map[uint64]bool {
+	entries := idx.sorted[field]
+	start := sort.Search(len(entries), func(i int) bool {
+		if minIncl {
+			return entries[i].value >= min
+		}
+		return entries[i].value > min
+	})
+
+	res := map[uint64]bool{}
+	for _, e := range entries[start:] {
+		if e.value > max || (!maxIncl && e.value == max) {
+			break
+		}
+		res[e.id] = true
+	}
+	return res
+}
+
+type andExpr []Expr
+
+func And(exprs ...Expr) Expr {
+	return andExpr(exprs)
+}
+
+func (e andExpr) eval(idx *Index) (map[uint64]bool, error) {
+	sets := make([]map[uint64]bool, len(e))
+	for i, expr := range e {
+		set, err := expr.eval(idx)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	if len(sets) == 0 {
+		return idx.all(), nil
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		return len(sets[i]) < len(sets[j])
+	})
+	res := map[uint64]bool{}
+	for id := range sets[0] {
+		matches := true
+		for _, set := range sets[1:] {
+			if !set[id] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			res[id] = true
+		}
+	}
+	return res, nil
+}
+
+func (e andExpr) String() string {
+	return join(e, " AND ")
+}
+
+type orExpr []Expr
+
+func Or(exprs ...Expr) Expr {
+	return orExpr(exprs)
+}
+
+func (e orExpr) eval(idx *Index) (map[uint64]bool, error) {
+	res := map[uint64]bool{}
+	for _, expr := range e {
+		set, err := expr.eval(idx)
+		if err != nil {
+			return nil, err
+		}
+		for id := range set {
+			res[id] = true
+		}
+	}
+	return res, nil
+}
+
+func (e orExpr) String() string {
+	return join(e, " OR ")
+}
+
+type notExpr struct {
+	expr Expr
+}
+
+func Not(expr Expr) Expr {
+	return notExpr{expr: expr}
+}
+
+func (e notExpr) eval(idx *Index) (map[uint64]bool, error) {
+	set, err := e.expr.eval(idx)
+	if err != nil {
+		return nil, err
+	}
+	res := map[uint64]bool{}
+	for id := range idx.points {
+		if !set[id] {
+			res[id] = true
+		}
+	}
+	return res, nil
+}
+
+func (e notExpr) String() string {
+	return "NOT (" + e.expr.String() + ")"
+}
+
+func (idx *Index) all() map[uint64]bool {
+	res := make(map[uint64]bool, len(idx.points))
+	for id := range idx.points {
+		res[id] = true
+	}
+	return res
+}
+
+func join(exprs []Expr, sep string) string {
+	parts := make([]string, len(exprs))
+	for i, expr := range exprs {
+		parts[i] = "(" + expr.String() + ")"
+	}
+	return strings.Join(parts, sep)
+}
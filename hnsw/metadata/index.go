@@ -0,0 +1,151 @@
+package metadata
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+)
+
+type sortedEntry struct {
+	value float64
+	id    uint64
+}
+
+// Index keeps the metadata of every point with an inverted index over string,
+// bool and keyword-list fields and a sorted index over numeric fields. It is
+// safe for concurrent use. It is not tied to a graph and is not saved with
+// it; Collection does both.
+type Index struct {
+	mu       sync.RWMutex
+	points   map[uint64]Metadata
+	kinds    map[string]Kind
+	inverted map[string]map[string]map[uint64]bool
+	sorted   map[string][]sortedEntry
+}
+
+func NewIndex() *Index {
+	return &Index{
+		points:   map[uint64]Metadata{},
+		kinds:    map[string]Kind{},
+		inverted: map[string]map[string]map[uint64]bool{},
+		sorted:   map[string][]sortedEntry{},
+	}
+}
+
+// Set replaces the metadata of a point with a copy of metadata. A field keeps
+// the kind it was first set with; a value of another kind is an error and
+// nothing is changed. Int and float values may be mixed in one field.
+func (idx *Index) Set(id uint64, metadata Metadata) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for field, value := range metadata {
+		kind, ok := idx.kinds[field]
+		if value.kind == 0 {
+			return fmt.Errorf("field %s has no value", field)
+		}
+		if ok && kind != value.kind && !(value.numeric() && (kind == KindInt || kind == KindFloat)) {
+			return fmt.Errorf("field %s holds %s values, got %s", field, kind, value.kind)
+		}
+	}
+
+	idx.remove(id)
+	idx.points[id] = maps.Clone(metadata)
+	for field, value := range metadata {
+		if _, ok := idx.kinds[field]; !ok {
+			idx.kinds[field] = value.kind
+		}
+		if value.numeric() {
+			idx.insertSorted(field, sortedEntry{value: value.num, id: id})
+			continue
+		}
+		terms := idx.inverted[field]
+		if terms == nil {
+			terms = map[string]map[uint64]bool{}
+			idx.inverted[field] = terms
+		}
+		for _, key := range value.keys() {
+			if terms[key] == nil {
+				terms[key] = map[uint64]bool{}
+			}
+			terms[key][id] = true
+		}
+	}
+	return nil
+}
+
+// Get returns a copy of the metadata of a point.
+func (idx *Index) Get(id uint64) (Metadata, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	metadata, ok := idx.points[id]
+	return maps.Clone(metadata), ok
+}
+
+func (idx *Index) Remove(id uint64) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.remove(id)
+}
+
+func (idx *Index) remove(id uint64) bool {
+	metadata, ok := idx.points[id]
+	if !ok {
+		return false
+	}
+	delete(idx.points, id)
+
+	for field, value := range metadata {
+		if value.numeric() {
+			idx.deleteSorted(field, sortedEntry{value: value.num, id: id})
+			continue
+		}
+		terms := idx.inverted[field]
+		for _, key := range value.keys() {
+			delete(terms[key], id)
+			if len(terms[key]) == 0 {
+				delete(terms, key)
+			}
+		}
+	}
+	return true
+}
+
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.points)
+}
+
+func (idx *Index) sortedPosition(entries []sortedEntry, entry sortedEntry) int {
+	return sort.Search(len(entries), func(i int) bool {
+		e := entries[i]
+		return e.value > entry.value || (e.value == entry.value && e.id >= entry.id)
+	})
+}
+
+func (idx *Index) insertSorted(field string, entry sortedEntry) {
+	entries := idx.sorted[field]
+	i := idx.sortedPosition(entries, entry)
+	entries = append(entries, sortedEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	idx.sorted[field] = entries
+}
+
+func (idx *Index) deleteSorted(field string, entry sortedEntry) {
+	entries := idx.sorted[field]
+	i := idx.sortedPosition(entries, entry)
+	if i < len(entries) && entries[i] == entry {
+		idx.sorted[field] = append(entries[:i], entries[i+1:]...)
+	}
+}
+
+// Evaluate returns the ids of the points matching expr, ready to be used as
+// hnsw.SearchOptions.Allowed.
+func (idx *Index) Evaluate(expr Expr) (map[uint64]bool, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return expr.eval(idx)
+}
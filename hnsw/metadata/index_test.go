@@ -0,0 +1,168 @@
+package metadata
+
+import (
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"math/rand/v2"
+	"reflect"
+	"testing"
+)
+
+func products() *Index {
+	idx := NewIndex()
+	categories := []string{"books", "music", "games"}
+	for id := uint64(0); id < 30; id += 1 {
+		idx.Set(id, Metadata{
+			"category": String(categories[id%3]),
+			"price":    Int(int64(id * 5)),
+			"rating":   Float(float64(id%5) + 0.5),
+			"in_stock": Bool(id%2 == 0),
+			"tags":     Strings(fmt.Sprintf("tag%d", id%4), "all"),
+		})
+	}
+	return idx
+}
+
+func ids(set map[uint64]bool) []uint64 {
+	res := []uint64{}
+	for id := uint64(0); id < 100; id += 1 {
+		if set[id] {
+			res = append(res, id)
+		}
+	}
+	return res
+}
+
+func TestEvaluate(t *testing.T) {
+	idx := products()
+
+	cases := []struct {
+		expr     Expr
+		expected []uint64
+	}{
+		{Eq("category", String("books")), []uint64{0, 3, 6, 9, 12, 15, 18, 21, 24, 27}},
+		{And(In("category", String("books"), String("music")), Lt("price", 50)), []uint64{0, 1, 3, 4, 6, 7, 9}},
+		{Between("price", 20, 30), []uint64{4, 5, 6}},
+		{And(Gt("price", 100), Eq("in_stock", Bool(true))), []uint64{22, 24, 26, 28}},
+		{And(Gte("rating", 4), Lte("price", 50)), []uint64{4, 9}},
+		{Eq("tags", String("tag3")), []uint64{3, 7, 11, 15, 19, 23, 27}},
+		{And(Eq("price", Int(25)), Eq("price", Float(25))), []uint64{5}},
+		{And(Not(Eq("tags", String("all"))), Eq("category", String("books"))), []uint64{}},
+		{Or(Eq("price", Int(0)), Eq("price", Int(145))), []uint64{0, 29}},
+		{Eq("missing", String("x")), []uint64{}},
+	}
+
+	for _, c := range cases {
+		set, err := idx.Evaluate(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %s", c.expr, err)
+		}
+		if got := ids(set); !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("%s matched %v, expected %v", c.expr, got, c.expected)
+		}
+	}
+
+	if s := And(In("category", String("books"), String("music")), Lt("price", 50)).String(); s != `(category IN ("books", "music")) AND (price < 50)` {
+		t.Fatalf("unexpected expression string %s", s)
+	}
+
+	for _, expr := range []Expr{Lt("category", 5), Eq("price", String("5")), Eq("category", Bool(true))} {
+		if _, err := idx.Evaluate(expr); err == nil {
+			t.Fatalf("%s: expected a kind mismatch error", expr)
+		}
+	}
+}
+
+func TestSetAndRemove(t *testing.T) {
+	idx := products()
+
+	err := idx.Set(3, Metadata{"category": String("music"), "price": Int(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, _ := idx.Evaluate(Eq("category", String("books")))
+	if set[3] {
+		t.Fatalf("old value still indexed")
+	}
+	set, _ = idx.Evaluate(Gt("price", 500))
+	if !reflect.DeepEqual(ids(set), []uint64{3}) {
+		t.Fatalf("new value not indexed: %v", ids(set))
+	}
+	set, _ = idx.Evaluate(Eq("in_stock", Bool(false)))
+	if set[3] {
+		t.Fatalf("dropped field still indexed")
+	}
+
+	if err := idx.Set(4, Metadata{"price": String("cheap")}); err == nil {
+		t.Fatalf("expected an error for a kind change")
+	}
+	if metadata, _ := idx.Get(4); metadata["price"].AsInt() != 20 {
+		t.Fatalf("failed Set changed the point: %v", metadata)
+	}
+
+	idx.Remove(3)
+	set, _ = idx.Evaluate(Gt("price", 500))
+	if len(set) != 0 || idx.Len() != 29 {
+		t.Fatalf("removed point still indexed")
+	}
+}
+
+func TestSetKeepsACopy(t *testing.T) {
+	idx := NewIndex()
+	metadata := Metadata{"category": String("books")}
+	idx.Set(1, metadata)
+	metadata["category"] = String("music")
+
+	stored, _ := idx.Get(1)
+	stored["category"] = String("games")
+
+	if stored, _ = idx.Get(1); stored["category"].AsString() != "books" {
+		t.Fatalf("changing the caller's map changed the point: %v", stored)
+	}
+}
+
+func TestAllowedSearch(t *testing.T) {
+	dim := 8
+	collection := hnsw.NewHnswCollection(3, dim, distances.Euclidian, 8, 4)
+	idx := NewIndex()
+	data := make([]vectors.Vector, 2000)
+	for i := range data {
+		data[i] = make(vectors.Vector, dim)
+		for j := range data[i] {
+			data[i][j] = vectors.VFloat(rand.Float64())
+		}
+		id := collection.Add(data[i], nil)
+		idx.Set(id, Metadata{"category": String(fmt.Sprintf("c%d", i%50)), "price": Int(int64(i % 100))})
+	}
+
+	for _, expr := range []Expr{
+		Eq("category", String("c7")),
+		And(In("category", String("c1"), String("c2"), String("c3")), Lt("price", 50)),
+		Lt("price", 80),
+	} {
+		allowed, _ := idx.Evaluate(expr)
+		query := data[rand.IntN(len(data))]
+		res := collection.SearchWithOptions(query, 5, hnsw.SearchOptions{Allowed: allowed})
+		if len(res) != 5 {
+			t.Fatalf("%s: expected 5 results, got %d", expr, len(res))
+		}
+		for _, r := range res {
+			if !allowed[r.Id] {
+				t.Fatalf("%s: result %d is not allowed", expr, r.Id)
+			}
+		}
+
+		best := -1.0
+		for id := range allowed {
+			d := float64(distances.Euclidian(query, data[id]))
+			if best < 0 || d < best {
+				best = d
+			}
+		}
+		if len(allowed) <= 20 && float64(res[0].Distance) != best {
+			t.Fatalf("%s: small allow-list not searched exactly", expr)
+		}
+	}
+}
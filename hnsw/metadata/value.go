@@ -0,0 +1,133 @@
+// Package metadata stores typed attributes per point and indexes them, so
+// that attribute filters become allow-lists for the graph search instead of
+// decoding the payload of every candidate.
+package metadata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Kind int
+
+const (
+	KindString Kind = iota + 1
+	KindInt
+	KindFloat
+	KindBool
+	KindStrings
+)
+
+func (kind Kind) String() string {
+	switch kind {
+	case KindString:
+		return "string"
+	case KindInt:
+		return "int"
+	case KindFloat:
+		return "float"
+	case KindBool:
+		return "bool"
+	case KindStrings:
+		return "strings"
+	}
+	return fmt.Sprintf("Kind(%d)", int(kind))
+}
+
+// Value is a typed attribute value. Build it with String, Int, Float, Bool or
+// Strings.
+type Value struct {
+	kind    Kind
+	str     string
+	num     float64
+	boolean bool
+	list    []string
+}
+
+// Metadata maps field names to values.
+type Metadata map[string]Value
+
+func String(v string) Value {
+	return Value{kind: KindString, str: v}
+}
+
+// Int values are compared as float64, so they are exact up to 2^53.
+func Int(v int64) Value {
+	return Value{kind: KindInt, num: float64(v)}
+}
+
+func Float(v float64) Value {
+	return Value{kind: KindFloat, num: v}
+}
+
+func Bool(v bool) Value {
+	return Value{kind: KindBool, boolean: v}
+}
+
+// Strings is a list of keywords. Equality filters match a list containing the
+// value.
+func Strings(v ...string) Value {
+	return Value{kind: KindStrings, list: append([]string(nil), v...)}
+}
+
+func (v Value) Kind() Kind {
+	return v.kind
+}
+
+func (v Value) AsString() string {
+	return v.str
+}
+
+func (v Value) AsInt() int64 {
+	return int64(v.num)
+}
+
+func (v Value) AsFloat() float64 {
+	return v.num
+}
+
+func (v Value) AsBool() bool {
+	return v.boolean
+}
+
+func (v Value) AsStrings() []string {
+	return v.list
+}
+
+func (v Value) numeric() bool {
+	return v.kind == KindInt || v.kind == KindFloat
+}
+
+// keys are the terms of the inverted index the value is found under.
+func (v Value) keys() []string {
+	switch v.kind {
+	case KindString:
+		return []string{v.str}
+	case KindBool:
+		return []string{strconv.FormatBool(v.boolean)}
+	case KindStrings:
+		return v.list
+	}
+	return nil
+}
+
+func (v Value) String() string {
+	switch v.kind {
+	case KindString:
+		return strconv.Quote(v.str)
+	case KindInt:
+		return strconv.FormatInt(int64(v.num), 10)
+	case KindFloat:
+		return strconv.FormatFloat(v.num, 'g', -1, 64)
+	case KindBool:
+		return strconv.FormatBool(v.boolean)
+	case KindStrings:
+		quoted := make([]string, len(v.list))
+		for i, s := range v.list {
+			quoted[i] = strconv.Quote(s)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+	return "<invalid>"
+}
@@ -0,0 +1,84 @@
+package hnsw
+
+import (
+	"fmt"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"math"
+)
+
+type MMROptions struct {
+	// Lambda trades relevance for diversity: 1 ranks by distance to the query
+	// only and 0 by distance to the results already picked only.
+	Lambda float64
+	// Candidates is the number of nearest points re-ranked. Zero means the
+	// requested number of results times the prefetch factor.
+	Candidates int
+	SearchOptions
+}
+
+// DiverseResult is a result of an MMR search. Score is the marginal relevance
+// the result had when picked.
+type DiverseResult struct {
+	SearchResult
+	Score vectors.VFloat
+}
+
+// SearchMMR fetches candidates like SearchWithOptions and picks k of them with
+// Maximal Marginal Relevance, so near duplicates do not crowd out the rest.
+func (hnsw *HnswCollection) SearchMMR(vector vectors.Vector, k int, opts MMROptions) []DiverseResult {
+	candidates := opts.Candidates
+	if candidates <= 0 {
+		candidates = k * hnsw.prefetchFactor
+	}
+	return MMR(hnsw.SearchWithOptions(vector, max(candidates, k), opts.SearchOptions), k, opts.Lambda, hnsw.Distance())
+}
+
+// MMR picks k of the candidates, ordered by distance to the query, one at a
+// time. Each pick maximizes
+//
+//	-lambda*distance(query, c) + (1-lambda)*min(distance(c, picked))
+//
+// which is the usual MMR with the negated distance as similarity.
+func MMR(candidates []SearchResult, k int, lambda float64, distance distances.Distance) []DiverseResult {
+	if lambda < 0 || lambda > 1 {
+		panic(fmt.Sprintf("Lambda must be in [0, 1] but %v found", lambda))
+	}
+	k = max(min(k, len(candidates)), 0)
+
+	relevance := vectors.VFloat(lambda)
+	diversity := vectors.VFloat(1 - lambda)
+
+	// closest holds the distance of every candidate to the nearest picked one.
+	closest := make([]vectors.VFloat, len(candidates))
+	for i := range closest {
+		closest[i] = vectors.VFloat(math.Inf(1))
+	}
+	picked := make([]bool, len(candidates))
+
+	results := make([]DiverseResult, 0, k)
+	for len(results) < k {
+		best, bestScore := -1, vectors.VFloat(math.Inf(-1))
+		for i, c := range candidates {
+			if picked[i] {
+				continue
+			}
+			score := -relevance * c.Distance
+			if len(results) > 0 && diversity > 0 {
+				score += diversity * closest[i]
+			}
+			if best < 0 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		picked[best] = true
+		results = append(results, DiverseResult{SearchResult: candidates[best], Score: bestScore})
+		for i, c := range candidates {
+			if !picked[i] {
+				closest[i] = min(closest[i], distance(c.Vector, candidates[best].Vector))
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,58 @@
+package hnsw
+
+import (
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"testing"
+)
+
+func TestSearchMMRSkipsNearDuplicates(t *testing.T) {
+	hnsw := NewHnswCollection(3, 2, distances.Euclidian, 8, 4)
+
+	a := hnsw.Add(vectors.Vector{1, 0}, nil)
+	hnsw.Add(vectors.Vector{1.01, 0}, nil)
+	hnsw.Add(vectors.Vector{1.02, 0}, nil)
+	b := hnsw.Add(vectors.Vector{0, 1.2}, nil)
+
+	plain := hnsw.SearchMMR(vectors.Vector{0, 0}, 2, MMROptions{Lambda: 1})
+	if len(plain) != 2 || plain[0].Id != a || plain[1].Id == b {
+		t.Fatalf("Lambda 1 must keep the plain ordering: %v", plain)
+	}
+
+	diverse := hnsw.SearchMMR(vectors.Vector{0, 0}, 2, MMROptions{Lambda: 0.5})
+	if len(diverse) != 2 || diverse[0].Id != a || diverse[1].Id != b {
+		t.Fatalf("Expected points %d, %d but found %v", a, b, diverse)
+	}
+	if diverse[0].Score != -0.5 || diverse[0].Distance != 1 {
+		t.Fatalf("Expected score -0.5 and distance 1 but found %v", diverse[0])
+	}
+}
+
+func TestMMRRespectsFilterAndK(t *testing.T) {
+	hnsw := NewHnswCollection(3, 2, distances.Euclidian, 8, 4)
+	for i := 0; i < 20; i += 1 {
+		hnsw.Add(vectors.Vector{vectors.VFloat(i), 0}, nil)
+	}
+
+	res := hnsw.SearchMMR(vectors.Vector{0, 0}, 30, MMROptions{
+		Lambda:        0.3,
+		SearchOptions: SearchOptions{Filter: func(id uint64, _ []byte) bool { return id%2 == 0 }},
+	})
+	if len(res) != 10 {
+		t.Fatalf("Expected 10 results but found %d", len(res))
+	}
+	for _, r := range res {
+		if r.Id%2 != 0 {
+			t.Fatalf("Filtered point %d returned", r.Id)
+		}
+	}
+}
+
+func TestMMRRejectsWrongLambda(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Expected panic on lambda out of range")
+		}
+	}()
+	MMR(nil, 1, 1.5, distances.Euclidian)
+}
@@ -0,0 +1,184 @@
+package hnsw
+
+import (
+	"fmt"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"math"
+	"sort"
+)
+
+// MultiVectorCollection stores documents made of several vectors, one per
+// token as in late interaction models like ColBERT. The vectors of every
+// document share a single graph.
+type MultiVectorCollection struct {
+	tokens    *HnswCollection
+	docs      map[uint64]*multiVectorDoc
+	owners    map[uint64]uint64
+	idCounter uint64
+}
+
+type multiVectorDoc struct {
+	tokens []uint64
+	value  []byte
+}
+
+type Document struct {
+	Id      uint64
+	Vectors []vectors.Vector
+	Value   []byte
+}
+
+// DocumentResult is a document found by SearchDocuments. Matches holds, for
+// every query vector, the index of the closest document vector.
+type DocumentResult struct {
+	Id       uint64
+	Distance vectors.VFloat
+	Matches  []int
+	Value    []byte
+}
+
+type MultiVectorOptions struct {
+	// Candidates is the number of nearest vectors fetched from the graph per
+	// query vector. Zero means the requested number of documents times the
+	// prefetch factor.
+	Candidates int
+	Filter     Filter
+}
+
+func NewMultiVectorCollection(nLayers int, vectorDimension int, distance distances.Distance, connectivity int, prefetchFactor int) *MultiVectorCollection {
+	return &MultiVectorCollection{
+		tokens: NewHnswCollection(nLayers, vectorDimension, distance, connectivity, prefetchFactor),
+		docs:   map[uint64]*multiVectorDoc{},
+		owners: map[uint64]uint64{},
+	}
+}
+
+func (multi *MultiVectorCollection) AddDocument(vectors []vectors.Vector, value []byte) uint64 {
+	id := multi.idCounter
+	multi.UpsertDocument(id, vectors, value)
+	return id
+}
+
+// UpsertDocument removes the token vectors of the document and adds vectors
+// as its new tokens.
+func (multi *MultiVectorCollection) UpsertDocument(id uint64, vectors []vectors.Vector, value []byte) {
+	if len(vectors) == 0 {
+		panic("At least one vector is required")
+	}
+	validateBatch(multi.tokens.vectorDimension, vectors, nil)
+
+	multi.RemoveDocument(id)
+	doc := &multiVectorDoc{tokens: multi.tokens.AddBatch(vectors, nil), value: value}
+	for _, token := range doc.tokens {
+		multi.owners[token] = id
+	}
+	multi.docs[id] = doc
+	if id >= multi.idCounter {
+		multi.idCounter = id + 1
+	}
+}
+
+func (multi *MultiVectorCollection) RemoveDocument(id uint64) bool {
+	doc, ok := multi.docs[id]
+	if !ok {
+		return false
+	}
+	for _, token := range doc.tokens {
+		multi.tokens.Remove(token)
+		delete(multi.owners, token)
+	}
+	delete(multi.docs, id)
+	return true
+}
+
+func (multi *MultiVectorCollection) GetDocument(id uint64) (Document, bool) {
+	doc, ok := multi.docs[id]
+	if !ok {
+		return Document{}, false
+	}
+	return Document{Id: id, Vectors: multi.vectors(doc), Value: doc.value}, true
+}
+
+// Len returns the number of documents.
+func (multi *MultiVectorCollection) Len() int {
+	return len(multi.docs)
+}
+
+func (multi *MultiVectorCollection) SearchDocuments(queries []vectors.Vector, k int) []DocumentResult {
+	return multi.SearchDocumentsWithOptions(queries, k, MultiVectorOptions{})
+}
+
+// SearchDocumentsWithOptions collects the documents owning the vectors
+// nearest to any query vector and ranks them by the exact MaxSim distance:
+// the sum over query vectors of the distance to the closest document
+// vector. With cosine distance this is the number of query vectors minus
+// the MaxSim score.
+func (multi *MultiVectorCollection) SearchDocumentsWithOptions(queries []vectors.Vector, k int, opts MultiVectorOptions) []DocumentResult {
+	if len(queries) == 0 {
+		panic("At least one query vector is required")
+	}
+	validateBatch(multi.tokens.vectorDimension, queries, nil)
+
+	candidates := opts.Candidates
+	if candidates <= 0 {
+		candidates = k * multi.tokens.prefetchFactor
+	}
+
+	var searchOpts SearchOptions
+	if opts.Filter != nil {
+		searchOpts.Filter = func(token uint64, _ []byte) bool {
+			id := multi.owners[token]
+			return opts.Filter(id, multi.docs[id].value)
+		}
+	}
+
+	found := map[uint64]bool{}
+	for _, query := range queries {
+		for _, node := range multi.tokens.nNearestWithOptions(nil, query, candidates, searchOpts) {
+			found[multi.owners[node.Id]] = true
+		}
+	}
+
+	distance := multi.tokens.Distance()
+	results := make([]DocumentResult, 0, len(found))
+	for id := range found {
+		doc := multi.docs[id]
+		docVectors := multi.vectors(doc)
+		result := DocumentResult{Id: id, Matches: make([]int, len(queries)), Value: doc.value}
+		for i, query := range queries {
+			best := vectors.VFloat(math.Inf(1))
+			for j, vector := range docVectors {
+				if d := distance(query, vector); d < best {
+					best = d
+					result.Matches[i] = j
+				}
+			}
+			result.Distance += best
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		return results[i].Id < results[j].Id
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+func (multi *MultiVectorCollection) vectors(doc *multiVectorDoc) []vectors.Vector {
+	docVectors := make([]vectors.Vector, len(doc.tokens))
+	for i, token := range doc.tokens {
+		point, ok := multi.tokens.Get(token)
+		if !ok {
+			panic(fmt.Sprintf("Vector %d of the document is missing", token))
+		}
+		docVectors[i] = point.Vector
+	}
+	return docVectors
+}
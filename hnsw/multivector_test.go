@@ -0,0 +1,93 @@
+package hnsw
+
+import (
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"math"
+	"testing"
+)
+
+func TestMultiVectorMaxSim(t *testing.T) {
+	multi := NewMultiVectorCollection(3, 2, distances.Cosine, 8, 4)
+
+	a := multi.AddDocument([]vectors.Vector{{1, 0}, {0, 1}}, []byte("a"))
+	b := multi.AddDocument([]vectors.Vector{{1, 0}, {1, 0.1}, {1, 0.2}}, []byte("b"))
+	c := multi.AddDocument([]vectors.Vector{{-1, 0}}, []byte("c"))
+
+	res := multi.SearchDocuments([]vectors.Vector{{1, 0}, {0, 1}}, 3)
+	if len(res) != 3 {
+		t.Fatalf("Expected 3 documents but found %d", len(res))
+	}
+	if res[0].Id != a || math.Abs(float64(res[0].Distance)) > 1e-9 {
+		t.Fatalf("Expected document %d to match exactly but found %v", a, res[0])
+	}
+	if res[0].Matches[0] != 0 || res[0].Matches[1] != 1 || string(res[0].Value) != "a" {
+		t.Fatalf("Wrong matches or value: %v", res[0])
+	}
+	if res[1].Id != b || res[2].Id != c {
+		t.Fatalf("Expected documents %d, %d but found %v", b, c, res[1:])
+	}
+	if res[1].Matches[1] != 2 {
+		t.Fatalf("Expected the last vector of %d to match the second query but found %d", b, res[1].Matches[1])
+	}
+}
+
+func TestMultiVectorDocumentLifecycle(t *testing.T) {
+	multi := NewMultiVectorCollection(3, 2, distances.Euclidian, 8, 4)
+
+	multi.UpsertDocument(5, []vectors.Vector{{0, 0}, {1, 1}}, []byte("old"))
+	multi.UpsertDocument(5, []vectors.Vector{{3, 3}}, []byte("new"))
+
+	doc, ok := multi.GetDocument(5)
+	if !ok || len(doc.Vectors) != 1 || doc.Vectors[0][0] != 3 || string(doc.Value) != "new" {
+		t.Fatalf("Upsert must replace the document: %v", doc)
+	}
+	if id := multi.AddDocument([]vectors.Vector{{0, 0}}, []byte("other")); id != 6 {
+		t.Fatalf("Expected generated id 6 but found %d", id)
+	}
+
+	res := multi.SearchDocumentsWithOptions([]vectors.Vector{{0, 0}}, 2, MultiVectorOptions{
+		Filter: func(id uint64, value []byte) bool { return string(value) != "other" },
+	})
+	if len(res) != 1 || res[0].Id != 5 || res[0].Distance != vectors.VFloat(math.Sqrt(18)) {
+		t.Fatalf("Expected only document 5 but found %v", res)
+	}
+
+	if !multi.RemoveDocument(5) || multi.RemoveDocument(5) {
+		t.Fatalf("Document must be removed once")
+	}
+	if multi.Len() != 1 || multi.tokens.Len() != 1 {
+		t.Fatalf("Expected 1 document with 1 vector but found %d with %d", multi.Len(), multi.tokens.Len())
+	}
+}
+
+func TestMultiVectorRecall(t *testing.T) {
+	multi := NewMultiVectorCollection(3, 8, distances.Euclidian, 16, 4)
+
+	docs := make([][]vectors.Vector, 200)
+	for i := range docs {
+		docs[i] = randomVectors(4, 8)
+		multi.AddDocument(docs[i], nil)
+	}
+
+	queries := randomVectors(3, 8)
+	best, bestDistance := uint64(0), vectors.VFloat(math.Inf(1))
+	for id, doc := range docs {
+		var total vectors.VFloat
+		for _, q := range queries {
+			closest := vectors.VFloat(math.Inf(1))
+			for _, v := range doc {
+				closest = min(closest, distances.Euclidian(q, v))
+			}
+			total += closest
+		}
+		if total < bestDistance {
+			best, bestDistance = uint64(id), total
+		}
+	}
+
+	res := multi.SearchDocuments(queries, 10)
+	if len(res) == 0 || res[0].Id != best {
+		t.Fatalf("Expected best document %d but found %v", best, res)
+	}
+}
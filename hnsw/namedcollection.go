@@ -0,0 +1,202 @@
+package hnsw
+
+import (
+	"fmt"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"sort"
+)
+
+// VectorConfig describes one named vector of a NamedCollection and the graph
+// built over it.
+type VectorConfig struct {
+	Dimension      int
+	Distance       distances.Distance
+	Layers         int
+	Connectivity   int
+	PrefetchFactor int
+}
+
+type NamedPoint struct {
+	Id      uint64
+	Vectors map[string]vectors.Vector
+	Value   []byte
+}
+
+// NamedCollection stores points holding several named vectors, for example
+// an image and a text embedding. Every name has its own graph, while ids and
+// values are shared. A point may lack some of the vectors.
+type NamedCollection struct {
+	graphs    map[string]*HnswCollection
+	values    map[uint64][]byte
+	idCounter uint64
+}
+
+func NewNamedCollection(configs map[string]VectorConfig) *NamedCollection {
+	if len(configs) == 0 {
+		panic("At least one named vector is required")
+	}
+
+	named := &NamedCollection{graphs: map[string]*HnswCollection{}, values: map[uint64][]byte{}}
+	for name, config := range configs {
+		named.graphs[name] = NewHnswCollection(config.Layers, config.Dimension, config.Distance, config.Connectivity, config.PrefetchFactor)
+	}
+	return named
+}
+
+// Names returns the vector names in sorted order.
+func (named *NamedCollection) Names() []string {
+	names := make([]string, 0, len(named.graphs))
+	for name := range named.graphs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (named *NamedCollection) validate(vectors map[string]vectors.Vector) {
+	if len(vectors) == 0 {
+		panic("At least one vector is required")
+	}
+	for name, vector := range vectors {
+		graph := named.graph(name)
+		if len(vector) != graph.vectorDimension {
+			panic(fmt.Sprintf("Vector %s dimension must be %d", name, graph.vectorDimension))
+		}
+	}
+}
+
+func (named *NamedCollection) graph(name string) *HnswCollection {
+	graph, ok := named.graphs[name]
+	if !ok {
+		panic(fmt.Sprintf("Unknown vector %s", name))
+	}
+	return graph
+}
+
+func (named *NamedCollection) Add(vectors map[string]vectors.Vector, value []byte) uint64 {
+	named.validate(vectors)
+
+	id := named.idCounter
+	named.Upsert(id, vectors, value)
+	return id
+}
+
+// Upsert sets the point to exactly the given named vectors; a graph not
+// named in vectors loses its vector for id.
+func (named *NamedCollection) Upsert(id uint64, vectors map[string]vectors.Vector, value []byte) {
+	named.validate(vectors)
+
+	named.Remove(id)
+	for name, vector := range vectors {
+		named.graphs[name].Upsert(id, vector, nil)
+	}
+	named.values[id] = value
+	if id >= named.idCounter {
+		named.idCounter = id + 1
+	}
+}
+
+func (named *NamedCollection) Remove(id uint64) bool {
+	if _, ok := named.values[id]; !ok {
+		return false
+	}
+	for _, graph := range named.graphs {
+		graph.Remove(id)
+	}
+	delete(named.values, id)
+	return true
+}
+
+func (named *NamedCollection) Get(id uint64) (NamedPoint, bool) {
+	value, ok := named.values[id]
+	if !ok {
+		return NamedPoint{}, false
+	}
+
+	point := NamedPoint{Id: id, Vectors: map[string]vectors.Vector{}, Value: value}
+	for name, graph := range named.graphs {
+		if p, ok := graph.Get(id); ok {
+			point.Vectors[name] = p.Vector
+		}
+	}
+	return point, true
+}
+
+func (named *NamedCollection) Len() int {
+	return len(named.values)
+}
+
+func (named *NamedCollection) Search(name string, vector vectors.Vector, k int) []SearchResult {
+	return named.SearchWithOptions(name, vector, k, SearchOptions{})
+}
+
+func (named *NamedCollection) SearchFiltered(name string, vector vectors.Vector, k int, filter Filter) []SearchResult {
+	return named.SearchWithOptions(name, vector, k, SearchOptions{Filter: filter})
+}
+
+// SearchWithOptions searches the graph of one named vector. The filter sees
+// the shared value of each point.
+func (named *NamedCollection) SearchWithOptions(name string, vector vectors.Vector, k int, opts SearchOptions) []SearchResult {
+	graph := named.graph(name)
+	if opts.Filter != nil {
+		filter := opts.Filter
+		opts.Filter = func(id uint64, _ []byte) bool {
+			return filter(id, named.values[id])
+		}
+	}
+
+	results := graph.SearchWithOptions(vector, k, opts)
+	for i := range results {
+		results[i].Value = named.values[results[i].Id]
+	}
+	return results
+}
+
+// SearchCombined searches several named vectors at once. Each graph proposes
+// candidates, which are ranked by the weighted sum of their distances to
+// the queries. A name missing from weights has weight 1. Points lacking one
+// of the queried vectors are skipped, and the results hold no vector.
+func (named *NamedCollection) SearchCombined(queries map[string]vectors.Vector, weights map[string]float64, k int) []SearchResult {
+	named.validate(queries)
+
+	candidates := map[uint64]bool{}
+	for name, query := range queries {
+		for _, r := range named.graphs[name].Search(query, k*named.graphs[name].prefetchFactor) {
+			candidates[r.Id] = true
+		}
+	}
+
+	results := make([]SearchResult, 0, len(candidates))
+	for id := range candidates {
+		var distance vectors.VFloat
+		complete := true
+		for name, query := range queries {
+			graph := named.graphs[name]
+			point, ok := graph.Get(id)
+			if !ok {
+				complete = false
+				break
+			}
+			weight, ok := weights[name]
+			if !ok {
+				weight = 1
+			}
+			distance += vectors.VFloat(weight) * graph.Distance()(query, point.Vector)
+		}
+		if complete {
+			results = append(results, SearchResult{Id: id, Distance: distance, Value: named.values[id]})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		return results[i].Id < results[j].Id
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
@@ -0,0 +1,102 @@
+package hnsw
+
+import (
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"testing"
+)
+
+func newTestNamedCollection() *NamedCollection {
+	return NewNamedCollection(map[string]VectorConfig{
+		"image": {Dimension: 2, Distance: distances.Euclidian, Layers: 3, Connectivity: 8, PrefetchFactor: 4},
+		"text":  {Dimension: 3, Distance: distances.Cosine, Layers: 3, Connectivity: 8, PrefetchFactor: 4},
+	})
+}
+
+func TestNamedCollectionSearchOneVector(t *testing.T) {
+	named := newTestNamedCollection()
+
+	a := named.Add(map[string]vectors.Vector{"image": {0, 0}, "text": {1, 0, 0}}, []byte("a"))
+	b := named.Add(map[string]vectors.Vector{"image": {5, 5}, "text": {0, 1, 0}}, []byte("b"))
+	c := named.Add(map[string]vectors.Vector{"image": {1, 1}}, []byte("c"))
+
+	if named.Len() != 3 {
+		t.Fatalf("Expected 3 points but found %d", named.Len())
+	}
+
+	res := named.Search("image", vectors.Vector{0.9, 0.9}, 1)
+	if len(res) != 1 || res[0].Id != c || string(res[0].Value) != "c" {
+		t.Fatalf("Expected point %d but found %v", c, res)
+	}
+
+	res = named.Search("text", vectors.Vector{0, 1, 0.1}, 2)
+	if len(res) != 2 || res[0].Id != b || res[1].Id != a {
+		t.Fatalf("Expected points %d, %d but found %v", b, a, res)
+	}
+
+	res = named.SearchFiltered("image", vectors.Vector{0, 0}, 3, func(id uint64, value []byte) bool {
+		return string(value) != "a"
+	})
+	if len(res) != 2 || res[0].Id != c {
+		t.Fatalf("Filter on shared values was not applied: %v", res)
+	}
+}
+
+func TestNamedCollectionSearchCombined(t *testing.T) {
+	named := newTestNamedCollection()
+
+	a := named.Add(map[string]vectors.Vector{"image": {0, 0}, "text": {0, 1, 0}}, nil)
+	b := named.Add(map[string]vectors.Vector{"image": {3, 0}, "text": {1, 0, 0}}, nil)
+	named.Add(map[string]vectors.Vector{"image": {0, 0}}, nil)
+
+	queries := map[string]vectors.Vector{"image": {0, 0}, "text": {1, 0, 0}}
+
+	res := named.SearchCombined(queries, map[string]float64{"image": 1, "text": 10}, 3)
+	if len(res) != 2 {
+		t.Fatalf("Points without every queried vector must be skipped: %v", res)
+	}
+	if res[0].Id != b || res[0].Distance != 3 {
+		t.Fatalf("Expected point %d at distance 3 but found %v", b, res[0])
+	}
+
+	res = named.SearchCombined(queries, map[string]float64{"image": 10, "text": 1}, 1)
+	if len(res) != 1 || res[0].Id != a || res[0].Distance != 1 {
+		t.Fatalf("Expected point %d at distance 1 but found %v", a, res)
+	}
+}
+
+func TestNamedCollectionUpsertAndRemove(t *testing.T) {
+	named := newTestNamedCollection()
+
+	named.Upsert(7, map[string]vectors.Vector{"image": {1, 2}, "text": {1, 0, 0}}, []byte("old"))
+	named.Upsert(7, map[string]vectors.Vector{"image": {2, 1}}, []byte("new"))
+
+	point, ok := named.Get(7)
+	if !ok || string(point.Value) != "new" || len(point.Vectors) != 1 || point.Vectors["image"][0] != 2 {
+		t.Fatalf("Upsert must replace every vector and the value: %v", point)
+	}
+	if id := named.Add(map[string]vectors.Vector{"text": {0, 0, 1}}, nil); id != 8 {
+		t.Fatalf("Expected generated id 8 but found %d", id)
+	}
+
+	if !named.Remove(7) || named.Remove(7) {
+		t.Fatalf("Point must be removed once")
+	}
+	if _, ok := named.Get(7); ok {
+		t.Fatalf("Removed point is still returned")
+	}
+	if res := named.Search("image", vectors.Vector{2, 1}, 1); len(res) != 0 {
+		t.Fatalf("Removed point is still found: %v", res)
+	}
+}
+
+func TestNamedCollectionRejectsWrongDimension(t *testing.T) {
+	named := newTestNamedCollection()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Expected panic on wrong dimension")
+		}
+	}()
+	named.Add(map[string]vectors.Vector{"text": {1, 0}}, nil)
+}
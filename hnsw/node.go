@@ -1,8 +1,11 @@
 package hnsw
 
 import (
+	"bytes"
 	"container/heap"
 	"encoding/binary"
+	"fmt"
+	"go-hnsw/hnsw/quantization"
 	"go-hnsw/hnsw/vectors"
 	"go-hnsw/hnsw/vectors/distances"
 	"io"
@@ -13,12 +16,18 @@ type Node struct {
 	Id        uint64
 	neighbors map[uint64]*Node
 	Vector    vectors.Vector
+	Codes     []byte
+	Bits      quantization.BinaryCode
 	Value     []byte
 	NextLevel *Node
 	Layer     *Layer
 }
 
 func (node *Node) SerializeCompact(writer io.Writer) (int, error) {
+	return node.serializeWith(writer, node.neighbors)
+}
+
+func (node *Node) serializeWith(writer io.Writer, neighbors map[uint64]*Node) (int, error) {
 	var size int = 8
 
 	err := binary.Write(writer, binary.LittleEndian, node.Id)
@@ -26,13 +35,13 @@ func (node *Node) SerializeCompact(writer io.Writer) (int, error) {
 		return size, err
 	}
 
-	err = binary.Write(writer, binary.LittleEndian, int32(len(node.neighbors)))
+	err = binary.Write(writer, binary.LittleEndian, int32(len(neighbors)))
 	if err != nil {
 		return size, err
 	}
 	size += 4
 
-	for k := range node.neighbors {
+	for k := range neighbors {
 		err = binary.Write(writer, binary.LittleEndian, k)
 		if err != nil {
 			return size, err
@@ -89,6 +98,9 @@ func DesserializeNode(reader io.Reader) (*Node, error) {
 	if err != nil {
 		return nil, err
 	}
+	if nNeightbors < 0 {
+		return nil, fmt.Errorf("node %d has a negative neighbor count", id)
+	}
 
 	var nId uint64
 	for i := 0; i < int(nNeightbors); i += 1 {
@@ -104,50 +116,82 @@ func DesserializeNode(reader io.Reader) (*Node, error) {
 	if err != nil {
 		return nil, err
 	}
-
-	vector := make([]vectors.VFloat, vectorSize)
-	var v vectors.VFloat
-	for i := 0; i < int(vectorSize); i += 1 {
-		err = binary.Read(reader, binary.LittleEndian, &v)
-		if err != nil {
-			return nil, err
-		}
-		vector[i] = v
+	node.Vector, err = readVector(reader, int(vectorSize))
+	if err != nil {
+		return nil, err
 	}
 
-	node.Vector = vectors.Vector(vector)
-
 	var dataLen int32
 	err = binary.Read(reader, binary.LittleEndian, &dataLen)
 	if err != nil {
 		return nil, err
 	}
-
+	if dataLen < 0 {
+		return nil, fmt.Errorf("node %d has a negative value length", id)
+	}
 	if dataLen > 0 {
-		node.Value = make([]byte, dataLen)
-		_, err := reader.Read(node.Value)
+		var value bytes.Buffer
+		_, err = io.CopyN(&value, reader, int64(dataLen))
 		if err != nil {
-			return nil, err
+			return nil, unexpectedEOF(err)
 		}
+		node.Value = value.Bytes()
 	}
 
 	return &node, nil
 }
 
+// readChunk is the number of vector components read at once. Lengths come
+// from the file, so buffers grow with the data actually read instead of
+// trusting them up front.
+const readChunk = 1024
+
+func readVector(reader io.Reader, n int) (vectors.Vector, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("negative vector length %d", n)
+	}
+	vector := make(vectors.Vector, 0, min(n, readChunk))
+	chunk := make([]vectors.VFloat, min(n, readChunk))
+	for len(vector) < n {
+		part := chunk[:min(n-len(vector), readChunk)]
+		err := binary.Read(reader, binary.LittleEndian, part)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		vector = append(vector, part...)
+	}
+	return vector, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type nodeDistance func(node *Node) vectors.VFloat
+
 type KClosestNodes struct {
-	nodes        []*Node
-	targetLen    int
-	targetVector vectors.Vector
-	distanceFnc  distances.Distance
+	nodes     []*Node
+	distances []vectors.VFloat
+	targetLen int
+	distance  nodeDistance
 }
 
 func NewKClosestNodes(k int, targetVector vectors.Vector, distanceFnc distances.Distance) *KClosestNodes {
+	return newKClosestNodesBy(k, func(node *Node) vectors.VFloat {
+		return distanceFnc(targetVector, node.Vector)
+	})
+}
+
+func newKClosestNodesBy(k int, distance nodeDistance) *KClosestNodes {
 	kcls := new(KClosestNodes)
 
-	kcls.distanceFnc = distanceFnc
+	kcls.distance = distance
 	kcls.targetLen = k
-	kcls.targetVector = targetVector
 	kcls.nodes = make([]*Node, 0, k)
+	kcls.distances = make([]vectors.VFloat, 0, k)
 
 	return kcls
 }
@@ -158,28 +202,31 @@ func (hp *KClosestNodes) Len() int {
 
 func (hp *KClosestNodes) Swap(i, j int) {
 	hp.nodes[i], hp.nodes[j] = hp.nodes[j], hp.nodes[i]
+	hp.distances[i], hp.distances[j] = hp.distances[j], hp.distances[i]
 }
 
 func (hp *KClosestNodes) Less(i, j int) bool {
-	dstI := hp.distanceFnc(hp.targetVector, hp.nodes[i].Vector)
-	dstJ := hp.distanceFnc(hp.targetVector, hp.nodes[j].Vector)
-
-	return dstI > dstJ
+	return hp.distances[i] > hp.distances[j]
 }
 
 func (hp *KClosestNodes) Push(x any) {
-	node := x.(*Node)
+	var node *Node
+	var dstToNode vectors.VFloat
+	switch c := x.(type) {
+	case candidate:
+		node, dstToNode = c.node, c.distance
+	default:
+		node = x.(*Node)
+		dstToNode = hp.distance(node)
+	}
 
 	if len(hp.nodes) < hp.targetLen {
 		hp.nodes = append(hp.nodes, node)
-	} else {
-		dstToNode := hp.distanceFnc(node.Vector, hp.targetVector)
-		dstToHead := hp.distanceFnc(hp.nodes[0].Vector, hp.targetVector)
-
-		if dstToNode < dstToHead {
-			heap.Pop(hp)
-			hp.nodes = append(hp.nodes, node)
-		}
+		hp.distances = append(hp.distances, dstToNode)
+	} else if dstToNode < hp.distances[0] {
+		heap.Pop(hp)
+		hp.nodes = append(hp.nodes, node)
+		hp.distances = append(hp.distances, dstToNode)
 	}
 }
 
@@ -187,5 +234,37 @@ func (hp *KClosestNodes) Pop() any {
 	n := len(hp.nodes)
 	node := hp.nodes[n-1]
 	hp.nodes = hp.nodes[:n-1]
+	hp.distances = hp.distances[:n-1]
 	return node
 }
+
+type candidate struct {
+	node     *Node
+	distance vectors.VFloat
+}
+
+type candidateQueue []candidate
+
+func (cq candidateQueue) Len() int {
+	return len(cq)
+}
+
+func (cq candidateQueue) Swap(i, j int) {
+	cq[i], cq[j] = cq[j], cq[i]
+}
+
+func (cq candidateQueue) Less(i, j int) bool {
+	return cq[i].distance < cq[j].distance
+}
+
+func (cq *candidateQueue) Push(x any) {
+	*cq = append(*cq, x.(candidate))
+}
+
+func (cq *candidateQueue) Pop() any {
+	old := *cq
+	n := len(old)
+	c := old[n-1]
+	*cq = old[:n-1]
+	return c
+}
@@ -0,0 +1,295 @@
+// Package partition keeps many small graphs, one per partition key such as a
+// tenant, inside one collection. Searches are scoped to one partition, so a
+// small tenant is searched through its own graph instead of filtering a
+// shared one.
+package partition
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/internal/fsutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const partitionExt = ".hnsw"
+
+var ErrNotFound = errors.New("partition not found")
+
+type partition struct {
+	mu    sync.RWMutex
+	index *hnsw.HnswCollection
+	dirty bool
+}
+
+// Collection stores every partition in its own file of a directory. A
+// partition is loaded when first used and stays in memory until Unload. Ids
+// are generated per partition, so the same id may exist in two partitions.
+// It is safe for concurrent use.
+type Collection struct {
+	dir    string
+	create func() *hnsw.HnswCollection
+
+	// mu is held for reading by every operation on a loaded partition and for
+	// writing while partitions are loaded, unloaded or dropped.
+	mu     sync.RWMutex
+	loaded map[string]*partition
+}
+
+// Open uses dir for the partition files, creating it if needed. create builds
+// the graph of a new partition.
+func Open(dir string, create func() *hnsw.HnswCollection) (*Collection, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &Collection{dir: dir, create: create, loaded: map[string]*partition{}}, nil
+}
+
+// path hex-encodes the key so that any key is a valid file name.
+func (c *Collection) path(key string) string {
+	return filepath.Join(c.dir, hex.EncodeToString([]byte(key))+partitionExt)
+}
+
+// with calls fnc with the loaded partition, loading it first when needed.
+// When create is set a missing partition is created, otherwise ErrNotFound is
+// returned.
+func (c *Collection) with(key string, create bool, write bool, fnc func(index *hnsw.HnswCollection)) error {
+	for {
+		if c.withLoaded(key, write, fnc) {
+			return nil
+		}
+
+		err := c.load(key, create)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// withLoaded calls fnc when the partition is loaded and reports whether it
+// was.
+func (c *Collection) withLoaded(key string, write bool, fnc func(index *hnsw.HnswCollection)) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	p, ok := c.loaded[key]
+	if !ok {
+		return false
+	}
+	if write {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.dirty = true
+	} else {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+	}
+	fnc(p.index)
+	return true
+}
+
+func (c *Collection) load(key string, create bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.loaded[key]; ok {
+		return nil
+	}
+
+	f, err := os.Open(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		if !create {
+			return fmt.Errorf("%w: %q", ErrNotFound, key)
+		}
+		c.loaded[key] = &partition{index: c.create(), dirty: true}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	index, err := hnsw.LoadHnswCollection(bufio.NewReader(f), nil)
+	if err != nil {
+		return fmt.Errorf("loading partition %q: %w", key, err)
+	}
+	c.loaded[key] = &partition{index: index}
+	return nil
+}
+
+func (c *Collection) Add(key string, vector vectors.Vector, value []byte) (uint64, error) {
+	var id uint64
+	err := c.with(key, true, true, func(index *hnsw.HnswCollection) {
+		id = index.Add(vector, value)
+	})
+	return id, err
+}
+
+func (c *Collection) Upsert(key string, id uint64, vector vectors.Vector, value []byte) error {
+	return c.with(key, true, true, func(index *hnsw.HnswCollection) {
+		index.Upsert(id, vector, value)
+	})
+}
+
+func (c *Collection) Remove(key string, id uint64) (bool, error) {
+	removed := false
+	err := c.with(key, false, true, func(index *hnsw.HnswCollection) {
+		removed = index.Remove(id)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return removed, err
+}
+
+func (c *Collection) Get(key string, id uint64) (hnsw.Point, bool, error) {
+	var point hnsw.Point
+	ok := false
+	err := c.with(key, false, false, func(index *hnsw.HnswCollection) {
+		point, ok = index.Get(id)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return hnsw.Point{}, false, nil
+	}
+	return point, ok, err
+}
+
+func (c *Collection) Search(key string, vector vectors.Vector, k int) ([]hnsw.SearchResult, error) {
+	return c.SearchWithOptions(key, vector, k, hnsw.SearchOptions{})
+}
+
+// SearchWithOptions searches the partition only. A partition that does not
+// exist has no results.
+func (c *Collection) SearchWithOptions(key string, vector vectors.Vector, k int, opts hnsw.SearchOptions) ([]hnsw.SearchResult, error) {
+	results := []hnsw.SearchResult{}
+	err := c.with(key, false, false, func(index *hnsw.HnswCollection) {
+		results = index.SearchWithOptions(vector, k, opts)
+	})
+	if errors.Is(err, ErrNotFound) {
+		return results, nil
+	}
+	return results, err
+}
+
+func (c *Collection) Len(key string) (int, error) {
+	n := 0
+	err := c.with(key, false, false, func(index *hnsw.HnswCollection) {
+		n = index.Len()
+	})
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// Partitions lists the keys of every partition, loaded or not.
+func (c *Collection) Partitions() ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := map[string]bool{}
+	for key := range c.loaded {
+		keys[key] = true
+	}
+
+	files, err := filepath.Glob(filepath.Join(c.dir, "*"+partitionExt))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		key, err := hex.DecodeString(strings.TrimSuffix(filepath.Base(file), partitionExt))
+		if err != nil {
+			continue
+		}
+		keys[string(key)] = true
+	}
+
+	res := make([]string, 0, len(keys))
+	for key := range keys {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func (c *Collection) Loaded() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]string, 0, len(c.loaded))
+	for key := range c.loaded {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Load reads a partition into memory ahead of its first use.
+func (c *Collection) Load(key string) error {
+	return c.load(key, false)
+}
+
+// Unload saves the partition if it changed and frees its memory. It is
+// loaded again by the next operation on it.
+func (c *Collection) Unload(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.loaded[key]
+	if !ok {
+		return nil
+	}
+	err := c.save(key, p)
+	if err != nil {
+		return err
+	}
+	delete(c.loaded, key)
+	return nil
+}
+
+// Drop deletes the partition from memory and disk.
+func (c *Collection) Drop(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.loaded, key)
+	err := os.Remove(c.path(key))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Flush saves every loaded partition that changed since it was last saved.
+func (c *Collection) Flush() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var errs []error
+	for key, p := range c.loaded {
+		p.mu.Lock()
+		errs = append(errs, c.save(key, p))
+		p.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Collection) save(key string, p *partition) error {
+	if !p.dirty {
+		return nil
+	}
+	err := fsutil.WriteFileAtomic(c.path(key), p.index.Save)
+	if err != nil {
+		return fmt.Errorf("saving partition %q: %w", key, err)
+	}
+	p.dirty = false
+	return nil
+}
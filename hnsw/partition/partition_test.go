@@ -0,0 +1,175 @@
+package partition
+
+import (
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"math/rand/v2"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newIndex() *hnsw.HnswCollection {
+	return hnsw.NewHnswCollection(3, 4, distances.Euclidian, 8, 4)
+}
+
+func randomVector() vectors.Vector {
+	vector := make(vectors.Vector, 4)
+	for i := range vector {
+		vector[i] = vectors.VFloat(rand.Float64())
+	}
+	return vector
+}
+
+func TestSearchIsScoped(t *testing.T) {
+	c, err := Open(t.TempDir(), newIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 1000; i += 1 {
+		c.Add("big", randomVector(), nil)
+	}
+	small := []vectors.Vector{randomVector(), randomVector(), randomVector()}
+	for _, v := range small {
+		c.Add("small", v, []byte("small"))
+	}
+
+	for i, v := range small {
+		res, err := c.Search("small", v, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 3 {
+			t.Fatalf("expected the 3 points of the partition, got %d", len(res))
+		}
+		if res[0].Id != uint64(i) || res[0].Distance != 0 {
+			t.Fatalf("query %d: unexpected nearest point %+v", i, res[0])
+		}
+		for _, r := range res {
+			if string(r.Value) != "small" {
+				t.Fatalf("result from another partition: %+v", r)
+			}
+		}
+	}
+
+	res, err := c.Search("missing", small[0], 10)
+	if err != nil || len(res) != 0 {
+		t.Fatalf("missing partition returned %v %v", res, err)
+	}
+}
+
+func TestLoadUnload(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := Open(dir, newIndex)
+
+	vector := randomVector()
+	c.Upsert("tenant a", 7, vector, []byte("a"))
+	c.Add("tenant/b", randomVector(), nil)
+
+	if loaded := c.Loaded(); !reflect.DeepEqual(loaded, []string{"tenant a", "tenant/b"}) {
+		t.Fatalf("unexpected loaded partitions %v", loaded)
+	}
+	err := c.Unload("tenant a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded := c.Loaded(); !reflect.DeepEqual(loaded, []string{"tenant/b"}) {
+		t.Fatalf("unexpected loaded partitions %v", loaded)
+	}
+
+	point, ok, err := c.Get("tenant a", 7)
+	if err != nil || !ok || !reflect.DeepEqual(point.Vector, vector) {
+		t.Fatalf("point not restored after unloading: %+v %v %v", point, ok, err)
+	}
+
+	err = c.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened, _ := Open(dir, newIndex)
+	partitions, _ := reopened.Partitions()
+	if !reflect.DeepEqual(partitions, []string{"tenant a", "tenant/b"}) {
+		t.Fatalf("unexpected partitions %v", partitions)
+	}
+	if len(reopened.Loaded()) != 0 {
+		t.Fatalf("partitions loaded before use: %v", reopened.Loaded())
+	}
+	if n, _ := reopened.Len("tenant/b"); n != 1 {
+		t.Fatalf("expected 1 point, got %d", n)
+	}
+
+	reopened.Drop("tenant/b")
+	partitions, _ = reopened.Partitions()
+	if !reflect.DeepEqual(partitions, []string{"tenant a"}) {
+		t.Fatalf("unexpected partitions after drop %v", partitions)
+	}
+}
+
+func TestConcurrentPartitions(t *testing.T) {
+	c, _ := Open(t.TempDir(), newIndex)
+	tenants := []string{"a", "b", "c", "d"}
+
+	wg := sync.WaitGroup{}
+	for _, tenant := range tenants {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i += 1 {
+				c.Add(tenant, randomVector(), nil)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i += 1 {
+				c.Search(tenant, randomVector(), 5)
+				if i%5 == 0 {
+					c.Unload(tenant)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, tenant := range tenants {
+		if n, _ := c.Len(tenant); n != 100 {
+			t.Fatalf("partition %s has %d points, 100 expected", tenant, n)
+		}
+	}
+}
+
+func TestPanicReleasesLocks(t *testing.T) {
+	c, err := Open(t.TempDir(), newIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add("a", randomVector(), nil)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Adding a vector of the wrong dimension must panic")
+			}
+		}()
+		c.Add("a", vectors.Vector{1}, nil)
+	}()
+
+	done := make(chan error)
+	go func() {
+		_, err := c.Add("a", randomVector(), nil)
+		if err == nil {
+			err = c.Unload("a")
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Locks are still held after a panic")
+	}
+}
@@ -0,0 +1,357 @@
+package hnsw
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw/quantization"
+	"go-hnsw/hnsw/vectors/distances"
+	"go-hnsw/internal/checksum"
+	"io"
+)
+
+const formatVersion uint32 = 1
+
+// Lengths read from a file are checked against these bounds before they are
+// trusted, so a corrupt file fails to load instead of exhausting memory.
+const (
+	maxLayers       = 1 << 10
+	maxDistanceName = 1 << 10
+)
+
+const (
+	quantizationNone byte = iota
+	quantizationProduct
+	quantizationBinary
+)
+
+var (
+	hnswMagic = [4]byte{'H', 'N', 'S', 'W'}
+	flatMagic = [4]byte{'F', 'L', 'A', 'T'}
+
+	ErrChecksumMismatch = checksum.ErrMismatch
+	ErrUnknownDistance  = errors.New("unknown distance")
+)
+
+type hnswHeader struct {
+	Version         uint32
+	NLayers         int32
+	VectorDimension int32
+	Connectivity    int32
+	PrefetchFactor  int32
+	IdCounter       uint64
+}
+
+// Save writes the collection as a header, every layer from the bottom one up
+// and a CRC32 checksum of everything written before it.
+func (hnsw *HnswCollection) Save(writer io.Writer) error {
+	hnsw.writeMu.Lock()
+	defer hnsw.writeMu.Unlock()
+
+	image := hnsw.image()
+	for i, layer := range hnsw.layers {
+		image.layers[i] = layer.nodes
+	}
+	return image.save(writer)
+}
+
+// collectionImage is what Save and Snapshot write: the collection settings,
+// the nodes of every layer and a way to get the neighbors of a node.
+type collectionImage struct {
+	header    hnswHeader
+	distance  distances.Distance
+	pq        *quantization.ProductQuantizer
+	binary    bool
+	layers    [][]*Node
+	neighbors func(node *Node) map[uint64]*Node
+}
+
+func (hnsw *HnswCollection) image() collectionImage {
+	return collectionImage{
+		header: hnswHeader{
+			Version:         formatVersion,
+			NLayers:         int32(len(hnsw.layers)),
+			VectorDimension: int32(hnsw.vectorDimension),
+			Connectivity:    int32(hnsw.connectivity),
+			PrefetchFactor:  int32(hnsw.prefetchFactor),
+			IdCounter:       hnsw.idCounter,
+		},
+		distance: hnsw.bottomLayer().DistanceFnc,
+		pq:       hnsw.pq,
+		binary:   hnsw.binary,
+		layers:   make([][]*Node, len(hnsw.layers)),
+		neighbors: func(node *Node) map[uint64]*Node {
+			return node.neighbors
+		},
+	}
+}
+
+func (image collectionImage) save(writer io.Writer) error {
+	return checksum.Write(writer, func(w io.Writer) error {
+		err := binary.Write(w, binary.LittleEndian, hnswMagic)
+		if err != nil {
+			return err
+		}
+		err = binary.Write(w, binary.LittleEndian, image.header)
+		if err != nil {
+			return err
+		}
+		err = writeDistanceName(w, image.distance)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case image.pq != nil:
+			_, err = w.Write([]byte{quantizationProduct})
+			if err == nil {
+				err = image.pq.Serialize(w)
+			}
+		case image.binary:
+			_, err = w.Write([]byte{quantizationBinary})
+		default:
+			_, err = w.Write([]byte{quantizationNone})
+		}
+		if err != nil {
+			return err
+		}
+
+		for i := len(image.layers) - 1; i >= 0; i -= 1 {
+			nodes := image.layers[i]
+			err = binary.Write(w, binary.LittleEndian, int32(len(nodes)))
+			if err != nil {
+				return err
+			}
+			for _, node := range nodes {
+				_, err = node.serializeWith(w, image.neighbors(node))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// LoadHnswCollection reads a collection written by Save. When distance is nil
+// the distance registered under the saved name is used.
+func LoadHnswCollection(reader io.Reader, distance distances.Distance) (*HnswCollection, error) {
+	var hnsw *HnswCollection
+	err := checksum.Read(reader, func(r io.Reader) error {
+		err := readMagic(r, hnswMagic)
+		if err != nil {
+			return err
+		}
+
+		var header hnswHeader
+		err = binary.Read(r, binary.LittleEndian, &header)
+		if err != nil {
+			return err
+		}
+		if header.Version != formatVersion {
+			return fmt.Errorf("unsupported format version %d", header.Version)
+		}
+		if header.NLayers <= 0 || header.NLayers > maxLayers || header.VectorDimension < 0 {
+			return fmt.Errorf("invalid header with %d layers of dimension %d", header.NLayers, header.VectorDimension)
+		}
+
+		distance, err = readDistance(r, distance)
+		if err != nil {
+			return err
+		}
+
+		kind := make([]byte, 1)
+		_, err = io.ReadFull(r, kind)
+		if err != nil {
+			return err
+		}
+		var pq *quantization.ProductQuantizer
+		if kind[0] == quantizationProduct {
+			_, err = quantizedCosine(distance)
+			if err != nil {
+				return err
+			}
+			pq, err = quantization.DeserializeProductQuantizer(r)
+			if err != nil {
+				return err
+			}
+			if pq.Dimension() != int(header.VectorDimension) {
+				return fmt.Errorf("quantizer dimension %d does not match %d", pq.Dimension(), header.VectorDimension)
+			}
+		}
+
+		hnsw = NewHnswCollection(int(header.NLayers), int(header.VectorDimension), distance, int(header.Connectivity), int(header.PrefetchFactor))
+		hnsw.idCounter = header.IdCounter
+
+		var nextLayer *Layer
+		for i := len(hnsw.layers) - 1; i >= 0; i -= 1 {
+			layer, err := DesserializeLayer(r, distance, nextLayer)
+			if err != nil {
+				return err
+			}
+			err = checkVectors(layer.nodes, int(header.VectorDimension))
+			if err != nil {
+				return err
+			}
+			hnsw.layers[i] = layer
+			nextLayer = layer
+		}
+
+		switch kind[0] {
+		case quantizationProduct:
+			hnsw.Quantize(pq)
+		case quantizationBinary:
+			hnsw.QuantizeBinary()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hnsw, nil
+}
+
+func (flat *FlatCollection) Save(writer io.Writer) error {
+	return checksum.Write(writer, func(w io.Writer) error {
+		err := binary.Write(w, binary.LittleEndian, flatMagic)
+		if err != nil {
+			return err
+		}
+		for _, v := range []any{formatVersion, int32(flat.vectorDimension), flat.idCounter} {
+			err = binary.Write(w, binary.LittleEndian, v)
+			if err != nil {
+				return err
+			}
+		}
+		err = writeDistanceName(w, flat.distance)
+		if err != nil {
+			return err
+		}
+
+		err = binary.Write(w, binary.LittleEndian, int32(len(flat.nodes)))
+		if err != nil {
+			return err
+		}
+		for _, node := range flat.nodes {
+			_, err = node.SerializeCompact(w)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func LoadFlatCollection(reader io.Reader, distance distances.Distance) (*FlatCollection, error) {
+	var flat *FlatCollection
+	err := checksum.Read(reader, func(r io.Reader) error {
+		err := readMagic(r, flatMagic)
+		if err != nil {
+			return err
+		}
+
+		var version uint32
+		var vectorDimension int32
+		var idCounter uint64
+		for _, v := range []any{&version, &vectorDimension, &idCounter} {
+			err = binary.Read(r, binary.LittleEndian, v)
+			if err != nil {
+				return err
+			}
+		}
+		if version != formatVersion {
+			return fmt.Errorf("unsupported format version %d", version)
+		}
+
+		distance, err = readDistance(r, distance)
+		if err != nil {
+			return err
+		}
+
+		flat = NewFlatCollection(int(vectorDimension), distance)
+		flat.idCounter = idCounter
+
+		var nNodes int32
+		err = binary.Read(r, binary.LittleEndian, &nNodes)
+		if err != nil {
+			return err
+		}
+		if vectorDimension < 0 || nNodes < 0 {
+			return fmt.Errorf("invalid header with %d nodes of dimension %d", nNodes, vectorDimension)
+		}
+		for i := 0; i < int(nNodes); i += 1 {
+			node, err := DesserializeNode(r)
+			if err != nil {
+				return err
+			}
+			err = checkVectors([]*Node{node}, int(vectorDimension))
+			if err != nil {
+				return err
+			}
+			node.neighbors = nil
+			flat.nodes = append(flat.nodes, node)
+			flat.rindex[node.Id] = len(flat.nodes) - 1
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return flat, nil
+}
+
+func checkVectors(nodes []*Node, dimension int) error {
+	for _, node := range nodes {
+		if len(node.Vector) != dimension {
+			return fmt.Errorf("node %d has dimension %d, %d expected", node.Id, len(node.Vector), dimension)
+		}
+	}
+	return nil
+}
+
+func readMagic(reader io.Reader, expected [4]byte) error {
+	var magic [4]byte
+	err := binary.Read(reader, binary.LittleEndian, &magic)
+	if err != nil {
+		return err
+	}
+	if magic != expected {
+		return fmt.Errorf("unexpected file signature %q", magic[:])
+	}
+	return nil
+}
+
+func writeDistanceName(writer io.Writer, distance distances.Distance) error {
+	name, _ := distances.NameOf(distance)
+	err := binary.Write(writer, binary.LittleEndian, int32(len(name)))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(writer, name)
+	return err
+}
+
+func readDistance(reader io.Reader, distance distances.Distance) (distances.Distance, error) {
+	var nameLen int32
+	err := binary.Read(reader, binary.LittleEndian, &nameLen)
+	if err != nil {
+		return nil, err
+	}
+	if nameLen < 0 || nameLen > maxDistanceName {
+		return nil, fmt.Errorf("invalid distance name length %d", nameLen)
+	}
+	name := make([]byte, nameLen)
+	_, err = io.ReadFull(reader, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if distance != nil {
+		return distance, nil
+	}
+	distance, ok := distances.ByName(string(name))
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownDistance, name)
+	}
+	return distance, nil
+}
@@ -0,0 +1,165 @@
+package hnsw
+
+import (
+	"bytes"
+	"errors"
+	"go-hnsw/hnsw/quantization"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"reflect"
+	"testing"
+)
+
+func TestHnswSaveLoad(t *testing.T) {
+	data := randomVectors(300, 8)
+
+	hnswCollection := NewHnswCollection(3, 8, distances.Euclidian, 6, 3)
+	for i, v := range data {
+		hnswCollection.Add(v, []byte{byte(i)})
+	}
+	hnswCollection.Remove(7)
+	hnswCollection.Quantize(quantization.TrainProductQuantizer(data, 2, 16, 5))
+
+	buff := new(bytes.Buffer)
+	err := hnswCollection.Save(buff)
+	if err != nil {
+		t.Fatalf("'Save' returned error: %s", err)
+	}
+
+	loaded, err := LoadHnswCollection(buff, nil)
+	if err != nil {
+		t.Fatalf("'LoadHnswCollection' returned error: %s", err)
+	}
+
+	if loaded.Len() != hnswCollection.Len() {
+		t.Fatalf("Loaded collection has %d points but %d expected", loaded.Len(), hnswCollection.Len())
+	}
+	for i, layer := range hnswCollection.layers {
+		if len(layer.nodes) != len(loaded.layers[i].nodes) {
+			t.Fatalf("Layer %d has %d nodes after loading but %d expected", i, len(loaded.layers[i].nodes), len(layer.nodes))
+		}
+	}
+
+	point, ok := loaded.Get(42)
+	if !ok || !reflect.DeepEqual(point.Vector, data[42]) || point.Value[0] != 42 {
+		t.Fatalf("Unexpected loaded point %+v", point)
+	}
+	if _, ok = loaded.Get(7); ok {
+		t.Fatal("Removed point must not be loaded")
+	}
+
+	node, _ := loaded.bottomLayer().Get(42)
+	if len(node.Codes) != 2 {
+		t.Fatal("Loaded collection must be quantized")
+	}
+
+	if id := loaded.Add(data[0], nil); id != 300 {
+		t.Fatalf("New id after loading expected to be 300 but %d found", id)
+	}
+
+	if len(loaded.Search(data[3], 5)) != 5 {
+		t.Fatal("Loaded collection must be searchable")
+	}
+}
+
+func TestFlatSaveLoad(t *testing.T) {
+	flat := NewFlatCollection(4, distances.Cosine)
+	for i, v := range randomVectors(20, 4) {
+		flat.Add(v, []byte{byte(i)})
+	}
+
+	buff := new(bytes.Buffer)
+	err := flat.Save(buff)
+	if err != nil {
+		t.Fatalf("'Save' returned error: %s", err)
+	}
+
+	loaded, err := LoadFlatCollection(buff, nil)
+	if err != nil {
+		t.Fatalf("'LoadFlatCollection' returned error: %s", err)
+	}
+
+	if loaded.Len() != 20 {
+		t.Fatalf("Loaded collection has %d points but 20 expected", loaded.Len())
+	}
+	if name, _ := distances.NameOf(loaded.distance); name != "cosine" {
+		t.Fatalf("Loaded distance expected to be 'cosine' but '%s' found", name)
+	}
+}
+
+func TestLoadDetectsCorruption(t *testing.T) {
+	hnswCollection := NewHnswCollection(2, 4, distances.Euclidian, 4, 2)
+	for _, v := range randomVectors(20, 4) {
+		hnswCollection.Add(v, []byte("value"))
+	}
+
+	buff := new(bytes.Buffer)
+	hnswCollection.Save(buff)
+
+	data := buff.Bytes()
+	data[len(data)-10] ^= 0xff
+
+	_, err := LoadHnswCollection(bytes.NewReader(data), nil)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Expected checksum mismatch error but '%v' found", err)
+	}
+}
+
+func TestLoadUnknownDistance(t *testing.T) {
+	flat := NewFlatCollection(2, func(v1, v2 vectors.Vector) vectors.VFloat { return 0 })
+
+	buff := new(bytes.Buffer)
+	flat.Save(buff)
+
+	_, err := LoadFlatCollection(bytes.NewReader(buff.Bytes()), nil)
+	if !errors.Is(err, ErrUnknownDistance) {
+		t.Fatalf("Expected unknown distance error but '%v' found", err)
+	}
+
+	_, err = LoadFlatCollection(bytes.NewReader(buff.Bytes()), distances.Euclidian)
+	if err != nil {
+		t.Fatalf("Loading with an explicit distance returned error: %s", err)
+	}
+}
+
+func TestLoadNeverPanicsOnCorruption(t *testing.T) {
+	hnswCollection := NewHnswCollection(2, 4, distances.Euclidian, 4, 2)
+	flat := NewFlatCollection(4, distances.Euclidian)
+	for _, v := range randomVectors(10, 4) {
+		hnswCollection.Add(v, []byte("value"))
+		flat.Add(v, []byte("value"))
+	}
+
+	loaders := map[string]func(data []byte) error{
+		"hnsw": func(data []byte) error {
+			_, err := LoadHnswCollection(bytes.NewReader(data), nil)
+			return err
+		},
+		"flat": func(data []byte) error {
+			_, err := LoadFlatCollection(bytes.NewReader(data), nil)
+			return err
+		},
+	}
+	saved := map[string][]byte{}
+	for name, index := range map[string]Index{"hnsw": hnswCollection, "flat": flat} {
+		buff := new(bytes.Buffer)
+		index.Save(buff)
+		saved[name] = buff.Bytes()
+	}
+
+	for name, load := range loaders {
+		data := saved[name]
+		for i := range data {
+			for _, flip := range []byte{0x80, 0xff} {
+				corrupt := bytes.Clone(data)
+				corrupt[i] ^= flip
+				if err := load(corrupt); err == nil {
+					t.Fatalf("%s: flipping byte %d must fail the load", name, i)
+				}
+			}
+			if err := load(data[:i]); err == nil {
+				t.Fatalf("%s: truncating to %d bytes must fail the load", name, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package quantization
+
+import (
+	"go-hnsw/hnsw/vectors"
+	"math/bits"
+)
+
+// BinaryCode keeps one sign bit per vector component packed into 64 bit words.
+type BinaryCode []uint64
+
+func EncodeBinary(vector vectors.Vector) BinaryCode {
+	code := make(BinaryCode, (len(vector)+63)/64)
+	for i, v := range vector {
+		if v > 0 {
+			code[i/64] |= 1 << (i % 64)
+		}
+	}
+	return code
+}
+
+func Hamming(code1, code2 BinaryCode) int {
+	dst := 0
+	for i := 0; i < len(code1); i += 1 {
+		dst += bits.OnesCount64(code1[i] ^ code2[i])
+	}
+	return dst
+}
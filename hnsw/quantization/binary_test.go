@@ -0,0 +1,35 @@
+package quantization
+
+import (
+	"go-hnsw/hnsw/vectors"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeBinary(t *testing.T) {
+	vector := make(vectors.Vector, 70)
+	vector[0] = 1
+	vector[2] = 0.5
+	vector[3] = -1
+	vector[65] = 2
+
+	code := EncodeBinary(vector)
+
+	expected := BinaryCode{0b101, 0b10}
+	if !reflect.DeepEqual(code, expected) {
+		t.Fatalf("Expected binary code %b but %b found", expected, code)
+	}
+}
+
+func TestHamming(t *testing.T) {
+	code1 := EncodeBinary(vectors.Vector{1, -1, 1, -1})
+	code2 := EncodeBinary(vectors.Vector{1, 1, -1, -1})
+
+	if dst := Hamming(code1, code2); dst != 2 {
+		t.Fatalf("Hamming distance expected to be 2 but %d found", dst)
+	}
+
+	if dst := Hamming(code1, code1); dst != 0 {
+		t.Fatalf("Hamming distance to itself expected to be 0 but %d found", dst)
+	}
+}
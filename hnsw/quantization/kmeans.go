@@ -0,0 +1,116 @@
+package quantization
+
+import (
+	"go-hnsw/hnsw/vectors"
+	"math"
+	"math/rand/v2"
+)
+
+func squaredEuclidian(vector1, vector2 vectors.Vector) vectors.VFloat {
+	var dst vectors.VFloat = 0
+	for i := 0; i < len(vector1); i += 1 {
+		d := vector1[i] - vector2[i]
+		dst += d * d
+	}
+	return dst
+}
+
+func nearestCentroid(vector vectors.Vector, centroids []vectors.Vector) (int, vectors.VFloat) {
+	best, bestDst := 0, vectors.VFloat(math.Inf(1))
+	for i, centroid := range centroids {
+		dst := squaredEuclidian(vector, centroid)
+		if dst < bestDst {
+			best, bestDst = i, dst
+		}
+	}
+	return best, bestDst
+}
+
+// kMeans clusters data into k centroids. Centroids are seeded with k-means++
+// and refined with Lloyd iterations until assignments stop changing.
+func kMeans(data []vectors.Vector, k int, iterations int) []vectors.Vector {
+	centroids := seedCentroids(data, k)
+	assignments := make([]int, len(data))
+	for i := range assignments {
+		assignments[i] = -1
+	}
+
+	dimension := len(data[0])
+	for it := 0; it < iterations; it += 1 {
+		changed := false
+		for i, vector := range data {
+			c, _ := nearestCentroid(vector, centroids)
+			if c != assignments[i] {
+				assignments[i] = c
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([]vectors.Vector, k)
+		counts := make([]int, k)
+		for i := range sums {
+			sums[i] = make(vectors.Vector, dimension)
+		}
+		for i, vector := range data {
+			c := assignments[i]
+			counts[c] += 1
+			for j, v := range vector {
+				sums[c][j] += v
+			}
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				centroids[c] = copyVector(data[rand.IntN(len(data))])
+				continue
+			}
+			for j := range sums[c] {
+				sums[c][j] /= vectors.VFloat(counts[c])
+			}
+			centroids[c] = sums[c]
+		}
+	}
+
+	return centroids
+}
+
+func seedCentroids(data []vectors.Vector, k int) []vectors.Vector {
+	centroids := make([]vectors.Vector, 0, k)
+	centroids = append(centroids, copyVector(data[rand.IntN(len(data))]))
+
+	weights := make([]vectors.VFloat, len(data))
+	for len(centroids) < k {
+		var total vectors.VFloat = 0
+		for i, vector := range data {
+			_, dst := nearestCentroid(vector, centroids)
+			weights[i] = dst
+			total += dst
+		}
+
+		if total == 0 {
+			centroids = append(centroids, copyVector(data[rand.IntN(len(data))]))
+			continue
+		}
+
+		target := vectors.VFloat(rand.Float64()) * total
+		chosen := len(data) - 1
+		for i, w := range weights {
+			target -= w
+			if target <= 0 {
+				chosen = i
+				break
+			}
+		}
+		centroids = append(centroids, copyVector(data[chosen]))
+	}
+
+	return centroids
+}
+
+func copyVector(vector vectors.Vector) vectors.Vector {
+	res := make(vectors.Vector, len(vector))
+	copy(res, vector)
+	return res
+}
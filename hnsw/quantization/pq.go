@@ -0,0 +1,230 @@
+package quantization
+
+import (
+	"encoding/binary"
+	"fmt"
+	"go-hnsw/hnsw/vectors"
+	"io"
+	"math"
+)
+
+// ProductQuantizer splits vectors into equally sized subspaces and encodes
+// every subvector as the index of its closest centroid in that subspace's
+// codebook, so a vector is stored as one byte per subspace.
+type ProductQuantizer struct {
+	dimension   int
+	subspaceDim int
+	codebooks   [][]vectors.Vector
+	nCentroids  int
+	nSubspaces  int
+}
+
+func TrainProductQuantizer(data []vectors.Vector, nSubspaces int, nCentroids int, iterations int) *ProductQuantizer {
+	if len(data) == 0 {
+		panic("Training data must not be empty")
+	}
+	if nCentroids <= 0 || nCentroids > 256 {
+		panic("nCentroids must be in range [1, 256]")
+	}
+	if len(data) < nCentroids {
+		panic(fmt.Sprintf("At least %d training vectors are required", nCentroids))
+	}
+
+	dimension := len(data[0])
+	if nSubspaces <= 0 || dimension%nSubspaces != 0 {
+		panic(fmt.Sprintf("Vector dimension %d must be divisible by the number of subspaces", dimension))
+	}
+
+	pq := &ProductQuantizer{
+		dimension:   dimension,
+		subspaceDim: dimension / nSubspaces,
+		codebooks:   make([][]vectors.Vector, nSubspaces),
+		nCentroids:  nCentroids,
+		nSubspaces:  nSubspaces,
+	}
+
+	subvectors := make([]vectors.Vector, len(data))
+	for m := 0; m < nSubspaces; m += 1 {
+		for i, vector := range data {
+			if len(vector) != dimension {
+				panic(fmt.Sprintf("Vector dimension must be %d", dimension))
+			}
+			subvectors[i] = pq.subvector(vector, m)
+		}
+		pq.codebooks[m] = kMeans(subvectors, nCentroids, iterations)
+	}
+
+	return pq
+}
+
+func (pq *ProductQuantizer) Dimension() int {
+	return pq.dimension
+}
+
+func (pq *ProductQuantizer) CodeSize() int {
+	return pq.nSubspaces
+}
+
+func (pq *ProductQuantizer) subvector(vector vectors.Vector, m int) vectors.Vector {
+	return vector[m*pq.subspaceDim : (m+1)*pq.subspaceDim]
+}
+
+func (pq *ProductQuantizer) Encode(vector vectors.Vector) []byte {
+	if len(vector) != pq.dimension {
+		panic(fmt.Sprintf("Vector dimension must be %d", pq.dimension))
+	}
+
+	codes := make([]byte, pq.nSubspaces)
+	for m, codebook := range pq.codebooks {
+		c, _ := nearestCentroid(pq.subvector(vector, m), codebook)
+		codes[m] = byte(c)
+	}
+	return codes
+}
+
+func (pq *ProductQuantizer) Decode(codes []byte) vectors.Vector {
+	vector := make(vectors.Vector, 0, pq.dimension)
+	for m, c := range codes {
+		vector = append(vector, pq.codebooks[m][c]...)
+	}
+	return vector
+}
+
+// DistanceTable precomputes the squared Euclidian distances from every query
+// subvector to every centroid, so the distance to an encoded vector costs one
+// table lookup per subspace.
+func (pq *ProductQuantizer) DistanceTable(query vectors.Vector) *DistanceTable {
+	if len(query) != pq.dimension {
+		panic(fmt.Sprintf("Vector dimension must be %d", pq.dimension))
+	}
+
+	table := &DistanceTable{
+		nCentroids: pq.nCentroids,
+		distances:  make([]vectors.VFloat, pq.nSubspaces*pq.nCentroids),
+	}
+	for m, codebook := range pq.codebooks {
+		sub := pq.subvector(query, m)
+		for c, centroid := range codebook {
+			table.distances[m*pq.nCentroids+c] = squaredEuclidian(sub, centroid)
+		}
+	}
+	return table
+}
+
+type DistanceTable struct {
+	nCentroids int
+	distances  []vectors.VFloat
+}
+
+func (table *DistanceTable) Distance(codes []byte) vectors.VFloat {
+	var dst vectors.VFloat = 0
+	for m, c := range codes {
+		dst += table.distances[m*table.nCentroids+int(c)]
+	}
+	return vectors.VFloat(math.Sqrt(float64(dst)))
+}
+
+// CosineTable precomputes the dot products of every query subvector with
+// every centroid and the squared norm of every centroid, so the cosine
+// distance to an encoded vector costs two table lookups per subspace.
+func (pq *ProductQuantizer) CosineTable(query vectors.Vector) *CosineTable {
+	if len(query) != pq.dimension {
+		panic(fmt.Sprintf("Vector dimension must be %d", pq.dimension))
+	}
+
+	table := &CosineTable{
+		nCentroids: pq.nCentroids,
+		queryNorm:  vectors.VectorAbs(query),
+		dots:       make([]vectors.VFloat, pq.nSubspaces*pq.nCentroids),
+		norms:      make([]vectors.VFloat, pq.nSubspaces*pq.nCentroids),
+	}
+	for m, codebook := range pq.codebooks {
+		sub := pq.subvector(query, m)
+		for c, centroid := range codebook {
+			table.dots[m*pq.nCentroids+c] = dot(sub, centroid)
+			table.norms[m*pq.nCentroids+c] = dot(centroid, centroid)
+		}
+	}
+	return table
+}
+
+type CosineTable struct {
+	nCentroids int
+	queryNorm  vectors.VFloat
+	dots       []vectors.VFloat
+	norms      []vectors.VFloat
+}
+
+// Distance is 1 minus the cosine similarity of the query and the decoded
+// vector. A zero vector on either side is at distance 1.
+func (table *CosineTable) Distance(codes []byte) vectors.VFloat {
+	var dotProd, norm vectors.VFloat = 0, 0
+	for m, c := range codes {
+		i := m*table.nCentroids + int(c)
+		dotProd += table.dots[i]
+		norm += table.norms[i]
+	}
+	if table.queryNorm == 0 || norm == 0 {
+		return 1
+	}
+	return 1 - dotProd/(table.queryNorm*vectors.VFloat(math.Sqrt(float64(norm))))
+}
+
+func dot(vector1, vector2 vectors.Vector) vectors.VFloat {
+	var sum vectors.VFloat = 0
+	for i := 0; i < len(vector1); i += 1 {
+		sum += vector1[i] * vector2[i]
+	}
+	return sum
+}
+
+func (pq *ProductQuantizer) Serialize(writer io.Writer) error {
+	header := []int32{int32(pq.dimension), int32(pq.nSubspaces), int32(pq.nCentroids)}
+	err := binary.Write(writer, binary.LittleEndian, header)
+	if err != nil {
+		return err
+	}
+
+	for _, codebook := range pq.codebooks {
+		for _, centroid := range codebook {
+			err = binary.Write(writer, binary.LittleEndian, centroid)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func DeserializeProductQuantizer(reader io.Reader) (*ProductQuantizer, error) {
+	header := make([]int32, 3)
+	err := binary.Read(reader, binary.LittleEndian, header)
+	if err != nil {
+		return nil, err
+	}
+
+	dimension, nSubspaces, nCentroids := int(header[0]), int(header[1]), int(header[2])
+	if dimension <= 0 || nSubspaces <= 0 || dimension%nSubspaces != 0 || nCentroids <= 0 || nCentroids > 256 {
+		return nil, fmt.Errorf("invalid product quantizer header %v", header)
+	}
+
+	pq := &ProductQuantizer{
+		dimension:   dimension,
+		subspaceDim: dimension / nSubspaces,
+		codebooks:   make([][]vectors.Vector, nSubspaces),
+		nCentroids:  nCentroids,
+		nSubspaces:  nSubspaces,
+	}
+	for m := range pq.codebooks {
+		pq.codebooks[m] = make([]vectors.Vector, nCentroids)
+		for c := range pq.codebooks[m] {
+			centroid := make(vectors.Vector, pq.subspaceDim)
+			err = binary.Read(reader, binary.LittleEndian, centroid)
+			if err != nil {
+				return nil, err
+			}
+			pq.codebooks[m][c] = centroid
+		}
+	}
+	return pq, nil
+}
@@ -0,0 +1,101 @@
+package quantization
+
+import (
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+func clusteredVectors(n int, centers []vectors.Vector, spread float64) []vectors.Vector {
+	data := make([]vectors.Vector, n)
+	for i := range data {
+		center := centers[i%len(centers)]
+		vector := make(vectors.Vector, len(center))
+		for j, c := range center {
+			vector[j] = c + vectors.VFloat((rand.Float64()-0.5)*spread)
+		}
+		data[i] = vector
+	}
+	return data
+}
+
+func TestProductQuantizerEncodeDecode(t *testing.T) {
+	centers := []vectors.Vector{
+		{10, 10, -10, -10, 5, 5, 0, 0},
+		{-10, -10, 10, 10, 0, 0, 5, 5},
+		{0, 10, 0, -10, 10, 0, -10, 0},
+	}
+	data := clusteredVectors(300, centers, 0.5)
+
+	pq := TrainProductQuantizer(data, 4, 8, 20)
+
+	if pq.CodeSize() != 4 {
+		t.Fatalf("Code size expected to be 4 but %d found", pq.CodeSize())
+	}
+
+	for _, vector := range data {
+		codes := pq.Encode(vector)
+		decoded := pq.Decode(codes)
+
+		dst := math.Sqrt(float64(squaredEuclidian(vector, decoded)))
+		if dst > 1.0 {
+			t.Fatalf("Decoded vector %s is too far from the original %s", decoded, vector)
+		}
+	}
+}
+
+func TestDistanceTable(t *testing.T) {
+	centers := []vectors.Vector{{1, 2, 3, 4}, {-4, -3, -2, -1}}
+	data := clusteredVectors(100, centers, 1.0)
+
+	pq := TrainProductQuantizer(data, 2, 4, 10)
+
+	query := vectors.Vector{0.5, 0.5, 0.5, 0.5}
+	table := pq.DistanceTable(query)
+
+	for _, vector := range data {
+		codes := pq.Encode(vector)
+		expected := math.Sqrt(float64(squaredEuclidian(query, pq.Decode(codes))))
+		actual := float64(table.Distance(codes))
+
+		if math.Abs(expected-actual) > 1e-9 {
+			t.Fatalf("ADC distance expected to be %f but %f found", expected, actual)
+		}
+	}
+}
+
+func TestCosineTable(t *testing.T) {
+	centers := []vectors.Vector{{1, 2, 3, 4}, {-4, -3, -2, -1}}
+	data := clusteredVectors(100, centers, 1.0)
+
+	pq := TrainProductQuantizer(data, 2, 4, 10)
+
+	query := vectors.Vector{0.5, -1, 0.5, 2}
+	table := pq.CosineTable(query)
+
+	for _, vector := range data {
+		codes := pq.Encode(vector)
+		expected := float64(distances.Cosine(query, pq.Decode(codes)))
+		actual := float64(table.Distance(codes))
+
+		if math.Abs(expected-actual) > 1e-9 {
+			t.Fatalf("ADC distance expected to be %f but %f found", expected, actual)
+		}
+	}
+
+	if d := pq.CosineTable(vectors.Vector{0, 0, 0, 0}).Distance(pq.Encode(data[0])); d != 1 {
+		t.Fatalf("Distance to a zero query expected to be 1 but %f found", d)
+	}
+}
+
+func TestTrainPanicsOnIndivisibleDimension(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("'TrainProductQuantizer' must panic when the dimension is not divisible")
+		}
+	}()
+
+	TrainProductQuantizer([]vectors.Vector{{1, 2, 3}}, 2, 1, 1)
+}
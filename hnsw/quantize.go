@@ -0,0 +1,87 @@
+package hnsw
+
+import (
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw/quantization"
+	"go-hnsw/hnsw/vectors/distances"
+)
+
+var errQuantizedDistance = errors.New("product quantization supports only the euclidian and cosine distances")
+
+// quantizedCosine reports whether product quantized distances are cosine
+// rather than Euclidian ones.
+func quantizedCosine(distance distances.Distance) (bool, error) {
+	name, _ := distances.NameOf(distance)
+	switch name {
+	case "euclidian":
+		return false, nil
+	case "cosine":
+		return true, nil
+	}
+	return false, errQuantizedDistance
+}
+
+// Quantize encodes every stored vector with pq. Once quantized, searches walk
+// the graph using asymmetric distances of the collection's metric between
+// the raw query and the codes. Only Euclidian and cosine collections can be
+// quantized. It replaces binary quantization if that was enabled.
+//
+// Quantizing makes distances cheaper but saves no memory: nodes keep their
+// raw vectors, which re-scoring, Get, Range, Save and later inserts need, and
+// the codes are stored next to them.
+func (hnsw *HnswCollection) Quantize(pq *quantization.ProductQuantizer) {
+	if pq.Dimension() != hnsw.vectorDimension {
+		panic(fmt.Sprintf("Quantizer dimension must be %d", hnsw.vectorDimension))
+	}
+	cosine, err := quantizedCosine(hnsw.bottomLayer().DistanceFnc)
+	if err != nil {
+		panic("Product quantization supports only the euclidian and cosine distances")
+	}
+
+	hnsw.writeMu.Lock()
+	defer hnsw.writeMu.Unlock()
+
+	hnsw.pq = pq
+	hnsw.pqCosine = cosine
+	hnsw.binary = false
+	hnsw.forEachCopy(func(node *Node, copies []*Node) {
+		codes := pq.Encode(node.Vector)
+		for _, n := range copies {
+			n.Codes = codes
+			n.Bits = nil
+		}
+	})
+}
+
+// QuantizeBinary keeps the sign bit of every vector component. Searches walk
+// the graph by Hamming distance and re-score the best candidates exactly.
+//
+// Like Quantize it saves no memory: the codes are stored next to the raw
+// vectors, which the re-scoring needs.
+func (hnsw *HnswCollection) QuantizeBinary() {
+	hnsw.writeMu.Lock()
+	defer hnsw.writeMu.Unlock()
+
+	hnsw.pq = nil
+	hnsw.binary = true
+	hnsw.forEachCopy(func(node *Node, copies []*Node) {
+		bits := quantization.EncodeBinary(node.Vector)
+		for _, n := range copies {
+			n.Bits = bits
+			n.Codes = nil
+		}
+	})
+}
+
+func (hnsw *HnswCollection) forEachCopy(fnc func(node *Node, copies []*Node)) {
+	for _, node := range hnsw.bottomLayer().nodes {
+		copies := []*Node{}
+		for _, layer := range hnsw.layers {
+			if n, ok := layer.Get(node.Id); ok {
+				copies = append(copies, n)
+			}
+		}
+		fnc(node, copies)
+	}
+}
@@ -0,0 +1,253 @@
+package hnsw
+
+import (
+	"container/heap"
+	"go-hnsw/hnsw/quantization"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"testing"
+)
+
+func randomVectors(n int, dimension int) []vectors.Vector {
+	data := make([]vectors.Vector, n)
+	for i := range data {
+		vector := make(vectors.Vector, dimension)
+		for j := range vector {
+			vector[j] = vectors.VFloat(rand.NormFloat64())
+		}
+		data[i] = vector
+	}
+	return data
+}
+
+func exactNearest(data []vectors.Vector, query vectors.Vector, n int) map[uint64]bool {
+	knearest := NewKClosestNodes(n, query, distances.Euclidian)
+	for i, v := range data {
+		heap.Push(knearest, &Node{Id: uint64(i), Vector: v})
+	}
+	res := map[uint64]bool{}
+	for _, node := range knearest.nodes {
+		res[node.Id] = true
+	}
+	return res
+}
+
+func TestQuantizedHnsw(t *testing.T) {
+	baseVectorA := []float64{10.0, 20.0, 30.0, 10.0, 10.0, 20.0, 30.0, 10.0}
+	baseVectorB := make([]float64, 8)
+	for i, v := range baseVectorA {
+		baseVectorB[i] = -v
+	}
+
+	hnswCollection := NewHnswCollection(3, 8, distances.Euclidian, 5, 3)
+
+	training := []vectors.Vector{}
+	vectorToAdd := make([]float64, 8)
+	for i := 0; i < 100; i += 1 {
+		for j, a := range baseVectorA {
+			vectorToAdd[j] = a + (rand.Float64()-0.5)*10.0
+		}
+		training = append(training, toVector(vectorToAdd))
+		hnswCollection.Add(toVector(vectorToAdd), []byte("A"))
+
+		for j, b := range baseVectorB {
+			vectorToAdd[j] = b + (rand.Float64()-0.5)*10.0
+		}
+		training = append(training, toVector(vectorToAdd))
+		hnswCollection.Add(toVector(vectorToAdd), []byte("B"))
+	}
+
+	hnswCollection.Quantize(quantization.TrainProductQuantizer(training, 4, 16, 10))
+
+	hnswCollection.Add(toVector(baseVectorA), []byte("A"))
+
+	validateANN(t, hnswCollection.nNearest(toVector(baseVectorA), 20), 20, "A")
+	validateANN(t, hnswCollection.nNearest(toVector(baseVectorB), 20), 20, "B")
+}
+
+func TestBinaryQuantizedHnsw(t *testing.T) {
+	baseVectorA := []float64{10.0, 20.0, -30.0, 10.0, -10.0, 20.0, 30.0, -10.0}
+	baseVectorB := make([]float64, 8)
+	for i, v := range baseVectorA {
+		baseVectorB[i] = -v
+	}
+
+	hnswCollection := NewHnswCollection(3, 8, distances.Euclidian, 5, 3)
+	hnswCollection.QuantizeBinary()
+
+	vectorToAdd := make([]float64, 8)
+	for i := 0; i < 100; i += 1 {
+		for j, a := range baseVectorA {
+			vectorToAdd[j] = a + (rand.Float64()-0.5)*10.0
+		}
+		hnswCollection.Add(toVector(vectorToAdd), []byte("A"))
+
+		for j, b := range baseVectorB {
+			vectorToAdd[j] = b + (rand.Float64()-0.5)*10.0
+		}
+		hnswCollection.Add(toVector(vectorToAdd), []byte("B"))
+	}
+
+	validateANN(t, hnswCollection.nNearest(toVector(baseVectorA), 20), 20, "A")
+	validateANN(t, hnswCollection.nNearest(toVector(baseVectorB), 20), 20, "B")
+}
+
+func TestOversamplingRescoresExactly(t *testing.T) {
+	data := randomVectors(200, 16)
+
+	hnswCollection := NewHnswCollection(3, 16, distances.Euclidian, 8, 3)
+	for _, v := range data {
+		hnswCollection.Add(v, nil)
+	}
+	hnswCollection.QuantizeBinary()
+
+	query := randomVectors(1, 16)[0]
+	truth := exactNearest(data, query, 5)
+
+	res := hnswCollection.nNearestWithOptions(nil, query, 5, SearchOptions{Oversampling: 100})
+	if len(res) != 5 {
+		t.Fatalf("Number of found nodes expected to be 5 but found %d", len(res))
+	}
+	for _, node := range res {
+		if !truth[node.Id] {
+			t.Fatalf("Node %d is not among the exact nearest nodes", node.Id)
+		}
+	}
+}
+
+func TestProductQuantizedCosine(t *testing.T) {
+	data := randomVectors(300, 16)
+
+	hnswCollection := NewHnswCollection(3, 16, distances.Cosine, 8, 3)
+	for _, v := range data {
+		hnswCollection.Add(v, nil)
+	}
+	hnswCollection.Quantize(quantization.TrainProductQuantizer(data, 4, 16, 10))
+
+	query := randomVectors(1, 16)[0]
+	knearest := NewKClosestNodes(5, query, distances.Cosine)
+	for i, v := range data {
+		heap.Push(knearest, &Node{Id: uint64(i), Vector: v})
+	}
+	truth := map[uint64]bool{}
+	for _, node := range knearest.nodes {
+		truth[node.Id] = true
+	}
+
+	res := hnswCollection.nNearestWithOptions(nil, query, 5, SearchOptions{Oversampling: 100})
+	if len(res) != 5 {
+		t.Fatalf("Number of found nodes expected to be 5 but found %d", len(res))
+	}
+	for _, node := range res {
+		if !truth[node.Id] {
+			t.Fatalf("Node %d is not among the exact nearest nodes", node.Id)
+		}
+	}
+}
+
+func TestDefaultOversamplingRescoresProductQuantized(t *testing.T) {
+	data := randomVectors(20, 16)
+
+	hnswCollection := NewHnswCollection(3, 16, distances.Euclidian, 8, 3)
+	for _, v := range data {
+		hnswCollection.Add(v, nil)
+	}
+	// A single centroid per subspace puts every point at the same quantized
+	// distance, so only the exact re-scoring can rank them.
+	hnswCollection.Quantize(quantization.TrainProductQuantizer(data, 4, 1, 1))
+
+	query := randomVectors(1, 16)[0]
+	truth := exactNearest(data, query, 5)
+
+	res := hnswCollection.nNearest(query, 5)
+	if len(res) != 5 {
+		t.Fatalf("Number of found nodes expected to be 5 but found %d", len(res))
+	}
+	for _, node := range res {
+		if !truth[node.Id] {
+			t.Fatalf("Node %d is not among the exact nearest nodes", node.Id)
+		}
+	}
+}
+
+func TestQuantizePanicsOnUnsupportedDistance(t *testing.T) {
+	manhattan := func(vector1, vector2 vectors.Vector) vectors.VFloat {
+		var dst vectors.VFloat = 0
+		for i := range vector1 {
+			dst += vectors.VFloat(math.Abs(float64(vector1[i] - vector2[i])))
+		}
+		return dst
+	}
+	data := randomVectors(20, 4)
+	hnswCollection := NewHnswCollection(3, 4, manhattan, 8, 3)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("'Quantize' must panic for a distance without quantized tables")
+		}
+	}()
+	hnswCollection.Quantize(quantization.TrainProductQuantizer(data, 2, 4, 5))
+}
+
+func benchmarkRecall(b *testing.B, quantize func(hnswCollection *HnswCollection, data []vectors.Vector)) {
+	const dimension, nVectors, nQueries, k = 32, 5000, 50, 10
+
+	data := randomVectors(nVectors, dimension)
+	queries := randomVectors(nQueries, dimension)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	hnswCollection := NewHnswCollection(3, dimension, distances.Euclidian, 16, 8)
+	for _, v := range data {
+		hnswCollection.Add(v, nil)
+	}
+	if quantize != nil {
+		quantize(hnswCollection, data)
+	}
+
+	// Measures everything a node holds: the vector, the codes and the links.
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	bytesPerNode := float64(int64(after.HeapAlloc)-int64(before.HeapAlloc)) / nVectors
+
+	truth := make([]map[uint64]bool, nQueries)
+	for i, q := range queries {
+		truth[i] = exactNearest(data, q, k)
+	}
+
+	b.ResetTimer()
+	found := 0
+	for i := 0; i < b.N; i += 1 {
+		q := i % nQueries
+		for _, node := range hnswCollection.nNearest(queries[q], k) {
+			if truth[q][node.Id] {
+				found += 1
+			}
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(found)/float64(b.N*k), "recall")
+	b.ReportMetric(bytesPerNode, "bytes/node")
+}
+
+func BenchmarkRecallUncompressed(b *testing.B) {
+	benchmarkRecall(b, nil)
+}
+
+func BenchmarkRecallProductQuantized(b *testing.B) {
+	benchmarkRecall(b, func(hnswCollection *HnswCollection, data []vectors.Vector) {
+		hnswCollection.Quantize(quantization.TrainProductQuantizer(data[:1000], 8, 256, 10))
+	})
+}
+
+func BenchmarkRecallBinaryQuantized(b *testing.B) {
+	benchmarkRecall(b, func(hnswCollection *HnswCollection, data []vectors.Vector) {
+		hnswCollection.QuantizeBinary()
+	})
+}
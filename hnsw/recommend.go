@@ -0,0 +1,155 @@
+package hnsw
+
+import (
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw/vectors"
+	"math"
+	"sort"
+)
+
+var (
+	ErrUnknownId   = errors.New("unknown id")
+	ErrNoPositives = errors.New("at least one positive id is required")
+)
+
+type RecommendStrategy int
+
+const (
+	// AverageVector searches once with the query 2*avg(positive) -
+	// avg(negative), or avg(positive) without negatives.
+	AverageVector RecommendStrategy = iota
+	// BestScore searches around every positive and ranks candidates by the
+	// distance to their nearest positive. Candidates nearer to a negative
+	// than to any positive come last.
+	BestScore
+)
+
+type RecommendOptions struct {
+	Strategy RecommendStrategy
+	// Filter, Allowed and Ef apply as in other searches. The given ids are
+	// never returned.
+	SearchOptions
+}
+
+// NearestToId searches with the stored vector of the point and leaves the
+// point itself out.
+func (hnsw *HnswCollection) NearestToId(id uint64, k int) ([]SearchResult, error) {
+	return hnsw.Recommend([]uint64{id}, nil, k, RecommendOptions{})
+}
+
+// Recommend returns the points most like the positive ones and unlike the
+// negative ones, using their stored vectors. It fails with ErrNoPositives
+// without positive ids and with ErrUnknownId for ids that are not stored.
+func (hnsw *HnswCollection) Recommend(positive []uint64, negative []uint64, k int, opts RecommendOptions) ([]SearchResult, error) {
+	if len(positive) == 0 {
+		return nil, ErrNoPositives
+	}
+
+	positives, err := hnsw.storedVectors(positive)
+	if err != nil {
+		return nil, err
+	}
+	negatives, err := hnsw.storedVectors(negative)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := map[uint64]bool{}
+	for _, id := range append(append([]uint64{}, positive...), negative...) {
+		excluded[id] = true
+	}
+	searchOpts := opts.SearchOptions
+	filter := searchOpts.Filter
+	searchOpts.Filter = func(id uint64, value []byte) bool {
+		return !excluded[id] && (filter == nil || filter(id, value))
+	}
+
+	switch opts.Strategy {
+	case AverageVector:
+		query := mean(positives)
+		if len(negatives) > 0 {
+			avoid := mean(negatives)
+			for i := range query {
+				query[i] = 2*query[i] - avoid[i]
+			}
+		}
+		return hnsw.SearchWithOptions(query, k, searchOpts), nil
+	case BestScore:
+		return hnsw.recommendBestScore(positives, negatives, k, searchOpts), nil
+	default:
+		panic(fmt.Sprintf("Unknown recommend strategy %d", opts.Strategy))
+	}
+}
+
+func (hnsw *HnswCollection) recommendBestScore(positives []vectors.Vector, negatives []vectors.Vector, k int, opts SearchOptions) []SearchResult {
+	distance := hnsw.Distance()
+	nearestTo := func(vector vectors.Vector, targets []vectors.Vector) vectors.VFloat {
+		best := vectors.VFloat(math.Inf(1))
+		for _, target := range targets {
+			best = min(best, distance(vector, target))
+		}
+		return best
+	}
+
+	type scored struct {
+		SearchResult
+		rejected bool
+	}
+	candidates := map[uint64]scored{}
+	for _, positive := range positives {
+		for _, r := range hnsw.SearchWithOptions(positive, k*hnsw.prefetchFactor, opts) {
+			if _, ok := candidates[r.Id]; ok {
+				continue
+			}
+			r.Distance = nearestTo(r.Vector, positives)
+			candidates[r.Id] = scored{SearchResult: r, rejected: nearestTo(r.Vector, negatives) < r.Distance}
+		}
+	}
+
+	ranked := make([]scored, 0, len(candidates))
+	for _, c := range candidates {
+		ranked = append(ranked, c)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].rejected != ranked[j].rejected {
+			return !ranked[i].rejected
+		}
+		if ranked[i].Distance != ranked[j].Distance {
+			return ranked[i].Distance < ranked[j].Distance
+		}
+		return ranked[i].Id < ranked[j].Id
+	})
+
+	n := max(min(k, len(ranked)), 0)
+	results := make([]SearchResult, 0, n)
+	for _, c := range ranked[:n] {
+		results = append(results, c.SearchResult)
+	}
+	return results
+}
+
+func (hnsw *HnswCollection) storedVectors(ids []uint64) ([]vectors.Vector, error) {
+	stored := make([]vectors.Vector, len(ids))
+	for i, id := range ids {
+		point, ok := hnsw.Get(id)
+		if !ok {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownId, id)
+		}
+		stored[i] = point.Vector
+	}
+	return stored, nil
+}
+
+func mean(vs []vectors.Vector) vectors.Vector {
+	result := make(vectors.Vector, len(vs[0]))
+	for _, v := range vs {
+		for i := range v {
+			result[i] += v[i]
+		}
+	}
+	for i := range result {
+		result[i] /= vectors.VFloat(len(vs))
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package hnsw
+
+import (
+	"errors"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"testing"
+)
+
+func newLineCollection() *HnswCollection {
+	hnsw := NewHnswCollection(3, 2, distances.Euclidian, 8, 4)
+	for i := 0; i < 10; i += 1 {
+		hnsw.Add(vectors.Vector{vectors.VFloat(i), 0}, nil)
+	}
+	return hnsw
+}
+
+func TestNearestToId(t *testing.T) {
+	hnsw := newLineCollection()
+
+	res, err := hnsw.NearestToId(4, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(res) != 2 || res[0].Distance != 1 || res[1].Distance != 1 {
+		t.Fatalf("Expected points 3 and 5 but found %v", res)
+	}
+	for _, r := range res {
+		if r.Id == 4 {
+			t.Fatalf("The point itself must not be returned")
+		}
+	}
+
+	if _, err := hnsw.NearestToId(42, 2); !errors.Is(err, ErrUnknownId) {
+		t.Fatalf("Expected ErrUnknownId but found %v", err)
+	}
+}
+
+func TestRecommendAverageVector(t *testing.T) {
+	hnsw := newLineCollection()
+
+	// The query is 2*avg(2, 4) - 1 = 5.
+	res, err := hnsw.Recommend([]uint64{2, 4}, []uint64{1}, 2, RecommendOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(res) != 2 || res[0].Id != 5 || res[1].Id != 6 {
+		t.Fatalf("Expected points 5, 6 but found %v", res)
+	}
+}
+
+func TestRecommendBestScore(t *testing.T) {
+	hnsw := newLineCollection()
+
+	res, err := hnsw.Recommend([]uint64{2, 8}, []uint64{6}, 4, RecommendOptions{Strategy: BestScore})
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if len(res) != 4 {
+		t.Fatalf("Expected 4 results but found %d", len(res))
+	}
+	// 1, 3, 7 and 9 are next to a positive. 7 is as near to the negative,
+	// while 5 is nearer to it and comes last.
+	for i, id := range []uint64{1, 3, 7, 9} {
+		if res[i].Id != id {
+			t.Fatalf("Expected point %d at %d but found %v", id, i, res)
+		}
+	}
+
+	res, _ = hnsw.Recommend([]uint64{2, 8}, []uint64{6}, 10, RecommendOptions{Strategy: BestScore})
+	if res[len(res)-1].Id != 5 {
+		t.Fatalf("Expected point 5 last but found %v", res)
+	}
+}
+
+func TestRecommendWithoutPositives(t *testing.T) {
+	hnsw := newLineCollection()
+
+	for _, strategy := range []RecommendStrategy{AverageVector, BestScore} {
+		_, err := hnsw.Recommend(nil, []uint64{6}, 4, RecommendOptions{Strategy: strategy})
+		if !errors.Is(err, ErrNoPositives) {
+			t.Fatalf("Expected ErrNoPositives but found %v", err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/rpc/pb"
+	"go-hnsw/hnsw/vectors"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Client struct {
+	client pb.HnswClient
+}
+
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{client: pb.NewHnswClient(conn)}
+}
+
+type SearchOptions struct {
+	// Ef and Oversampling are passed to the server search, zero means its
+	// defaults.
+	Ef           int
+	Oversampling int
+	Filter       *pb.Filter
+}
+
+func (opts SearchOptions) request(vector vectors.Vector, k int) *pb.SearchRequest {
+	return &pb.SearchRequest{
+		Vector:       fromVector(vector),
+		K:            uint32(k),
+		Ef:           uint32(opts.Ef),
+		Oversampling: uint32(opts.Oversampling),
+		Filter:       opts.Filter,
+	}
+}
+
+func toPoints(points []hnsw.Point) []*pb.Point {
+	res := make([]*pb.Point, len(points))
+	for i, p := range points {
+		res[i] = &pb.Point{Id: p.Id, Vector: fromVector(p.Vector), Value: p.Value}
+	}
+	return res
+}
+
+func toSearchResults(res *pb.SearchResponse) []hnsw.SearchResult {
+	results := make([]hnsw.SearchResult, len(res.Results))
+	for i, r := range res.Results {
+		results[i] = hnsw.SearchResult{Id: r.Id, Distance: vectors.VFloat(r.Distance), Value: r.Value}
+	}
+	return results
+}
+
+func (c *Client) Upsert(ctx context.Context, points []hnsw.Point) error {
+	_, err := c.client.Upsert(ctx, &pb.UpsertRequest{Points: toPoints(points)})
+	return err
+}
+
+// BatchUpsert streams the points in messages of at most batchSize points and
+// returns how many the server stored.
+func (c *Client) BatchUpsert(ctx context.Context, points []hnsw.Point, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		panic("batchSize must be > 0")
+	}
+
+	stream, err := c.client.BatchUpsert(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for start := 0; start < len(points); start += batchSize {
+		end := min(start+batchSize, len(points))
+		err = stream.Send(&pb.UpsertRequest{Points: toPoints(points[start:end])})
+		if errors.Is(err, io.EOF) {
+			// The server closed the stream, CloseAndRecv returns its error.
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return 0, err
+	}
+	return int(res.Upserted), nil
+}
+
+// Delete removes the points and returns how many of them existed.
+func (c *Client) Delete(ctx context.Context, ids ...uint64) (int, error) {
+	res, err := c.client.Delete(ctx, &pb.DeleteRequest{Ids: ids})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.Deleted), nil
+}
+
+func (c *Client) Get(ctx context.Context, id uint64) (hnsw.Point, bool, error) {
+	res, err := c.client.Get(ctx, &pb.GetRequest{Id: id})
+	if status.Code(err) == codes.NotFound {
+		return hnsw.Point{}, false, nil
+	}
+	if err != nil {
+		return hnsw.Point{}, false, err
+	}
+	return hnsw.Point{Id: res.Point.Id, Vector: toVector(res.Point.Vector), Value: res.Point.Value}, true, nil
+}
+
+func (c *Client) Search(ctx context.Context, vector vectors.Vector, k int, opts SearchOptions) ([]hnsw.SearchResult, error) {
+	res, err := c.client.Search(ctx, opts.request(vector, k))
+	if err != nil {
+		return nil, err
+	}
+	return toSearchResults(res), nil
+}
+
+// SearchStream runs one search per query over a single stream and returns
+// the results in query order.
+func (c *Client) SearchStream(ctx context.Context, queries []vectors.Vector, k int, opts SearchOptions) ([][]hnsw.SearchResult, error) {
+	stream, err := c.client.SearchStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sendErr := make(chan error, 1)
+	go func() {
+		for _, query := range queries {
+			err := stream.Send(opts.request(query, k))
+			if err != nil {
+				// io.EOF means the server failed the stream, Recv reports why.
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- stream.CloseSend()
+	}()
+
+	results := make([][]hnsw.SearchResult, 0, len(queries))
+	for range queries {
+		res, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, toSearchResults(res))
+	}
+
+	err = <-sendErr
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,381 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             v5.27.1
+// source: hnsw.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	Hnsw_Upsert_FullMethodName       = "/hnsw.v1.Hnsw/Upsert"
+	Hnsw_Delete_FullMethodName       = "/hnsw.v1.Hnsw/Delete"
+	Hnsw_Get_FullMethodName          = "/hnsw.v1.Hnsw/Get"
+	Hnsw_Search_FullMethodName       = "/hnsw.v1.Hnsw/Search"
+	Hnsw_BatchUpsert_FullMethodName  = "/hnsw.v1.Hnsw/BatchUpsert"
+	Hnsw_SearchStream_FullMethodName = "/hnsw.v1.Hnsw/SearchStream"
+)
+
+// HnswClient is the client API for Hnsw service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Hnsw serves a single collection.
+type HnswClient interface {
+	// Upsert stores the points, replacing points that already have their ids.
+	Upsert(ctx context.Context, in *UpsertRequest, opts ...grpc.CallOption) (*UpsertResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// Get fails with NOT_FOUND when there is no point with the id.
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// BatchUpsert upserts the points of every message of the stream and replies
+	// once the client closes it.
+	BatchUpsert(ctx context.Context, opts ...grpc.CallOption) (Hnsw_BatchUpsertClient, error)
+	// SearchStream answers every request of the stream in order.
+	SearchStream(ctx context.Context, opts ...grpc.CallOption) (Hnsw_SearchStreamClient, error)
+}
+
+type hnswClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHnswClient(cc grpc.ClientConnInterface) HnswClient {
+	return &hnswClient{cc}
+}
+
+func (c *hnswClient) Upsert(ctx context.Context, in *UpsertRequest, opts ...grpc.CallOption) (*UpsertResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpsertResponse)
+	err := c.cc.Invoke(ctx, Hnsw_Upsert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hnswClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, Hnsw_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hnswClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, Hnsw_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hnswClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, Hnsw_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hnswClient) BatchUpsert(ctx context.Context, opts ...grpc.CallOption) (Hnsw_BatchUpsertClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Hnsw_ServiceDesc.Streams[0], Hnsw_BatchUpsert_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &hnswBatchUpsertClient{ClientStream: stream}
+	return x, nil
+}
+
+type Hnsw_BatchUpsertClient interface {
+	Send(*UpsertRequest) error
+	CloseAndRecv() (*UpsertResponse, error)
+	grpc.ClientStream
+}
+
+type hnswBatchUpsertClient struct {
+	grpc.ClientStream
+}
+
+func (x *hnswBatchUpsertClient) Send(m *UpsertRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *hnswBatchUpsertClient) CloseAndRecv() (*UpsertResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(UpsertResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *hnswClient) SearchStream(ctx context.Context, opts ...grpc.CallOption) (Hnsw_SearchStreamClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Hnsw_ServiceDesc.Streams[1], Hnsw_SearchStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &hnswSearchStreamClient{ClientStream: stream}
+	return x, nil
+}
+
+type Hnsw_SearchStreamClient interface {
+	Send(*SearchRequest) error
+	Recv() (*SearchResponse, error)
+	grpc.ClientStream
+}
+
+type hnswSearchStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *hnswSearchStreamClient) Send(m *SearchRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *hnswSearchStreamClient) Recv() (*SearchResponse, error) {
+	m := new(SearchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// HnswServer is the server API for Hnsw service.
+// All implementations must embed UnimplementedHnswServer
+// for forward compatibility
+//
+// Hnsw serves a single collection.
+type HnswServer interface {
+	// Upsert stores the points, replacing points that already have their ids.
+	Upsert(context.Context, *UpsertRequest) (*UpsertResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// Get fails with NOT_FOUND when there is no point with the id.
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// BatchUpsert upserts the points of every message of the stream and replies
+	// once the client closes it.
+	BatchUpsert(Hnsw_BatchUpsertServer) error
+	// SearchStream answers every request of the stream in order.
+	SearchStream(Hnsw_SearchStreamServer) error
+	mustEmbedUnimplementedHnswServer()
+}
+
+// UnimplementedHnswServer must be embedded to have forward compatible implementations.
+type UnimplementedHnswServer struct {
+}
+
+func (UnimplementedHnswServer) Upsert(context.Context, *UpsertRequest) (*UpsertResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Upsert not implemented")
+}
+func (UnimplementedHnswServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedHnswServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedHnswServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedHnswServer) BatchUpsert(Hnsw_BatchUpsertServer) error {
+	return status.Errorf(codes.Unimplemented, "method BatchUpsert not implemented")
+}
+func (UnimplementedHnswServer) SearchStream(Hnsw_SearchStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method SearchStream not implemented")
+}
+func (UnimplementedHnswServer) mustEmbedUnimplementedHnswServer() {}
+
+// UnsafeHnswServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to HnswServer will
+// result in compilation errors.
+type UnsafeHnswServer interface {
+	mustEmbedUnimplementedHnswServer()
+}
+
+func RegisterHnswServer(s grpc.ServiceRegistrar, srv HnswServer) {
+	s.RegisterService(&Hnsw_ServiceDesc, srv)
+}
+
+func _Hnsw_Upsert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpsertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HnswServer).Upsert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Hnsw_Upsert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HnswServer).Upsert(ctx, req.(*UpsertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hnsw_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HnswServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Hnsw_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HnswServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hnsw_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HnswServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Hnsw_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HnswServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hnsw_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HnswServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Hnsw_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HnswServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hnsw_BatchUpsert_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(HnswServer).BatchUpsert(&hnswBatchUpsertServer{ServerStream: stream})
+}
+
+type Hnsw_BatchUpsertServer interface {
+	SendAndClose(*UpsertResponse) error
+	Recv() (*UpsertRequest, error)
+	grpc.ServerStream
+}
+
+type hnswBatchUpsertServer struct {
+	grpc.ServerStream
+}
+
+func (x *hnswBatchUpsertServer) SendAndClose(m *UpsertResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *hnswBatchUpsertServer) Recv() (*UpsertRequest, error) {
+	m := new(UpsertRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Hnsw_SearchStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(HnswServer).SearchStream(&hnswSearchStreamServer{ServerStream: stream})
+}
+
+type Hnsw_SearchStreamServer interface {
+	Send(*SearchResponse) error
+	Recv() (*SearchRequest, error)
+	grpc.ServerStream
+}
+
+type hnswSearchStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *hnswSearchStreamServer) Send(m *SearchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *hnswSearchStreamServer) Recv() (*SearchRequest, error) {
+	m := new(SearchRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Hnsw_ServiceDesc is the grpc.ServiceDesc for Hnsw service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Hnsw_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "hnsw.v1.Hnsw",
+	HandlerType: (*HnswServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Upsert",
+			Handler:    _Hnsw_Upsert_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Hnsw_Delete_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _Hnsw_Get_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _Hnsw_Search_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BatchUpsert",
+			Handler:       _Hnsw_BatchUpsert_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "SearchStream",
+			Handler:       _Hnsw_SearchStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "hnsw.proto",
+}
@@ -0,0 +1,208 @@
+// Package rpc serves a collection over gRPC using the hnsw.v1 API defined in
+// pb/hnsw.proto, and provides a client for it.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/rpc/pb"
+	"go-hnsw/hnsw/vectors"
+	"io"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements pb.HnswServer on top of a collection. Writes are
+// serialized, searches run concurrently.
+type Server struct {
+	pb.UnimplementedHnswServer
+
+	mu         sync.RWMutex
+	collection *hnsw.HnswCollection
+}
+
+func NewServer(collection *hnsw.HnswCollection) *Server {
+	return &Server{collection: collection}
+}
+
+func (s *Server) Register(registrar grpc.ServiceRegistrar) {
+	pb.RegisterHnswServer(registrar, s)
+}
+
+func (s *Server) Upsert(ctx context.Context, req *pb.UpsertRequest) (*pb.UpsertResponse, error) {
+	n, err := s.upsert(req)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.UpsertResponse{Upserted: n}, nil
+}
+
+// BatchUpsert applies every message as soon as it is received, so the points
+// of messages before an invalid one stay stored.
+func (s *Server) BatchUpsert(stream pb.Hnsw_BatchUpsertServer) error {
+	var total uint64
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&pb.UpsertResponse{Upserted: total})
+		}
+		if err != nil {
+			return err
+		}
+
+		n, err := s.upsert(req)
+		if err != nil {
+			return err
+		}
+		total += n
+	}
+}
+
+func (s *Server) upsert(req *pb.UpsertRequest) (uint64, error) {
+	points := make([]hnsw.Point, len(req.Points))
+	for i, p := range req.Points {
+		if len(p.Vector) != s.collection.VectorDimension() {
+			return 0, status.Errorf(codes.InvalidArgument, "point %d must have dimension %d", p.Id, s.collection.VectorDimension())
+		}
+		points[i] = hnsw.Point{Id: p.Id, Vector: toVector(p.Vector), Value: p.Value}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.collection.UpsertBatch(points)
+	return uint64(len(points)), nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted uint64
+	for _, id := range req.Ids {
+		if s.collection.Remove(id) {
+			deleted += 1
+		}
+	}
+	return &pb.DeleteResponse{Deleted: deleted}, nil
+}
+
+func (s *Server) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	s.mu.RLock()
+	point, ok := s.collection.Get(req.Id)
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "point %d not found", req.Id)
+	}
+	return &pb.GetResponse{Point: &pb.Point{Id: point.Id, Vector: fromVector(point.Vector), Value: point.Value}}, nil
+}
+
+func (s *Server) Search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	return s.search(ctx, req)
+}
+
+func (s *Server) SearchStream(stream pb.Hnsw_SearchStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		res, err := s.search(stream.Context(), req)
+		if err != nil {
+			return err
+		}
+		err = stream.Send(res)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// search stops walking the graph when ctx is done, so a cancelled call
+// frees its worker.
+func (s *Server) search(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	if len(req.Vector) != s.collection.VectorDimension() {
+		return nil, status.Errorf(codes.InvalidArgument, "vector must have dimension %d", s.collection.VectorDimension())
+	}
+	if req.K == 0 || req.K > hnsw.MaxK {
+		return nil, status.Errorf(codes.InvalidArgument, "k must be in range [1, %d]", hnsw.MaxK)
+	}
+	if req.Ef > hnsw.MaxEf || req.Oversampling > hnsw.MaxOversampling {
+		return nil, status.Errorf(codes.InvalidArgument, "ef must be at most %d and oversampling at most %d", hnsw.MaxEf, hnsw.MaxOversampling)
+	}
+
+	opts := hnsw.SearchOptions{
+		Ef:           int(req.Ef),
+		Oversampling: int(req.Oversampling),
+		Filter:       toFilter(req.Filter),
+	}
+
+	s.mu.RLock()
+	res, _, err := s.collection.SearchContext(ctx, toVector(req.Vector), int(req.K), opts)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+
+	results := make([]*pb.ScoredPoint, len(res))
+	for i, r := range res {
+		results[i] = &pb.ScoredPoint{Id: r.Id, Distance: float64(r.Distance), Value: r.Value}
+	}
+	return &pb.SearchResponse{Results: results}, nil
+}
+
+func toFilter(filter *pb.Filter) hnsw.Filter {
+	if filter == nil {
+		return nil
+	}
+
+	var allowed map[uint64]bool
+	if len(filter.Ids) > 0 {
+		allowed = map[uint64]bool{}
+		for _, id := range filter.Ids {
+			allowed[id] = true
+		}
+	}
+	excluded := map[uint64]bool{}
+	for _, id := range filter.ExcludeIds {
+		excluded[id] = true
+	}
+
+	return func(id uint64, value []byte) bool {
+		if allowed != nil && !allowed[id] {
+			return false
+		}
+		if excluded[id] {
+			return false
+		}
+		if filter.Value != nil && !bytes.Equal(value, filter.Value) {
+			return false
+		}
+		return bytes.HasPrefix(value, filter.ValuePrefix)
+	}
+}
+
+func toVector(values []float64) vectors.Vector {
+	vector := make(vectors.Vector, len(values))
+	for i, v := range values {
+		vector[i] = vectors.VFloat(v)
+	}
+	return vector
+}
+
+func fromVector(vector vectors.Vector) []float64 {
+	values := make([]float64, len(vector))
+	for i, v := range vector {
+		values[i] = float64(v)
+	}
+	return values
+}
@@ -0,0 +1,195 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/rpc/pb"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T, collection *hnsw.HnswCollection) *Client {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	NewServer(collection).Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewClient(conn)
+}
+
+func gridPoints(n int) []hnsw.Point {
+	points := make([]hnsw.Point, n)
+	for i := range points {
+		points[i] = hnsw.Point{
+			Id:     uint64(i),
+			Vector: vectors.Vector{vectors.VFloat(i), vectors.VFloat(i % 7)},
+			Value:  []byte(fmt.Sprintf("point-%d", i)),
+		}
+	}
+	return points
+}
+
+func TestUpsertGetDelete(t *testing.T) {
+	ctx := context.Background()
+	collection := hnsw.NewHnswCollection(3, 2, distances.Euclidian, 4, 4)
+	client := newTestClient(t, collection)
+
+	err := client.Upsert(ctx, gridPoints(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collection.Len() != 20 {
+		t.Fatalf("expected 20 points, got %d", collection.Len())
+	}
+
+	point, ok, err := client.Get(ctx, 5)
+	if err != nil || !ok {
+		t.Fatalf("get failed: %v %v", ok, err)
+	}
+	if point.Vector[0] != 5 || string(point.Value) != "point-5" {
+		t.Fatalf("unexpected point %+v", point)
+	}
+
+	deleted, err := client.Delete(ctx, 5, 6, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Fatalf("expected 2 deleted points, got %d", deleted)
+	}
+	_, ok, err = client.Get(ctx, 5)
+	if err != nil || ok {
+		t.Fatalf("deleted point still found: %v %v", ok, err)
+	}
+
+	err = client.Upsert(ctx, []hnsw.Point{{Id: 1, Vector: vectors.Vector{1}}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestBatchUpsert(t *testing.T) {
+	ctx := context.Background()
+	collection := hnsw.NewHnswCollection(3, 2, distances.Euclidian, 4, 4)
+	client := newTestClient(t, collection)
+
+	n, err := client.BatchUpsert(ctx, gridPoints(95), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 95 || collection.Len() != 95 {
+		t.Fatalf("expected 95 points, got %d and %d", n, collection.Len())
+	}
+
+	points := gridPoints(30)
+	points[25].Vector = vectors.Vector{1, 2, 3}
+	_, err = client.BatchUpsert(ctx, points, 10)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	collection := hnsw.NewHnswCollection(3, 2, distances.Euclidian, 4, 4)
+	collection.UpsertBatch(gridPoints(50))
+	client := newTestClient(t, collection)
+
+	res, err := client.Search(ctx, vectors.Vector{10, 3}, 3, SearchOptions{Ef: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 || res[0].Id != 10 || res[0].Distance != 0 {
+		t.Fatalf("unexpected results %+v", res)
+	}
+
+	filter := &pb.Filter{ExcludeIds: []uint64{10}, ValuePrefix: []byte("point-1")}
+	res, err = client.Search(ctx, vectors.Vector{10, 3}, 3, SearchOptions{Ef: 50, Filter: filter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range res {
+		if r.Id == 10 || string(r.Value[:7]) != "point-1" {
+			t.Fatalf("filter not applied: %+v", res)
+		}
+	}
+
+	_, err = client.Search(ctx, vectors.Vector{1}, 3, SearchOptions{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+
+	_, err = client.Search(ctx, vectors.Vector{10, 3}, hnsw.MaxK+1, SearchOptions{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for k above MaxK, got %v", err)
+	}
+	_, err = client.Search(ctx, vectors.Vector{10, 3}, 3, SearchOptions{Ef: hnsw.MaxEf + 1})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for ef above MaxEf, got %v", err)
+	}
+}
+
+func TestSearchStream(t *testing.T) {
+	ctx := context.Background()
+	collection := hnsw.NewHnswCollection(3, 2, distances.Euclidian, 4, 4)
+	collection.UpsertBatch(gridPoints(50))
+	client := newTestClient(t, collection)
+
+	queries := []vectors.Vector{}
+	for i := 0; i < 20; i += 1 {
+		queries = append(queries, vectors.Vector{vectors.VFloat(i), vectors.VFloat(i % 7)})
+	}
+
+	results, err := client.SearchStream(ctx, queries, 1, SearchOptions{Ef: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(queries) {
+		t.Fatalf("expected %d results, got %d", len(queries), len(results))
+	}
+	for i, res := range results {
+		if len(res) != 1 || res[0].Id != uint64(i) {
+			t.Fatalf("query %d: unexpected results %+v", i, res)
+		}
+	}
+
+	queries[5] = vectors.Vector{1, 2, 3}
+	_, err = client.SearchStream(ctx, queries, 1, SearchOptions{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestSearchStopsWhenCancelled(t *testing.T) {
+	collection := hnsw.NewHnswCollection(3, 2, distances.Euclidian, 4, 4)
+	collection.UpsertBatch(gridPoints(100))
+	server := NewServer(collection)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := server.Search(ctx, &pb.SearchRequest{Vector: []float64{10, 3}, K: 3})
+	if status.Code(err) != codes.Canceled {
+		t.Fatalf("expected Canceled for a cancelled call, got %v", err)
+	}
+}
@@ -0,0 +1,339 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const maxBodyBytes = 64 << 20
+
+type PointRequest struct {
+	Id     *uint64        `json:"id"`
+	Vector vectors.Vector `json:"vector"`
+	Value  string         `json:"value,omitempty"`
+}
+
+type UpsertRequest struct {
+	Points []PointRequest `json:"points"`
+}
+
+// FilterRequest restricts search results. All set conditions must hold.
+type FilterRequest struct {
+	Ids         []uint64 `json:"ids,omitempty"`
+	ExcludeIds  []uint64 `json:"exclude_ids,omitempty"`
+	Value       *string  `json:"value,omitempty"`
+	ValuePrefix string   `json:"value_prefix,omitempty"`
+}
+
+type SearchRequest struct {
+	Vector       vectors.Vector `json:"vector"`
+	K            int            `json:"k"`
+	Ef           int            `json:"ef,omitempty"`
+	Oversampling int            `json:"oversampling,omitempty"`
+	Filter       *FilterRequest `json:"filter,omitempty"`
+}
+
+type PointResponse struct {
+	Id       uint64          `json:"id"`
+	Distance *vectors.VFloat `json:"distance,omitempty"`
+	Vector   vectors.Vector  `json:"vector,omitempty"`
+	Value    string          `json:"value,omitempty"`
+}
+
+type CollectionResponse struct {
+	Name   string           `json:"name"`
+	Config CollectionConfig `json:"config"`
+	Points int              `json:"points"`
+}
+
+func (filter *FilterRequest) toFilter() hnsw.Filter {
+	if filter == nil {
+		return nil
+	}
+
+	var allowed, excluded map[uint64]bool
+	if filter.Ids != nil {
+		allowed = map[uint64]bool{}
+		for _, id := range filter.Ids {
+			allowed[id] = true
+		}
+	}
+	if filter.ExcludeIds != nil {
+		excluded = map[uint64]bool{}
+		for _, id := range filter.ExcludeIds {
+			excluded[id] = true
+		}
+	}
+
+	return func(id uint64, value []byte) bool {
+		if allowed != nil && !allowed[id] {
+			return false
+		}
+		if excluded[id] {
+			return false
+		}
+		if filter.Value != nil && string(value) != *filter.Value {
+			return false
+		}
+		return strings.HasPrefix(string(value), filter.ValuePrefix)
+	}
+}
+
+// Handler returns the HTTP API of the server:
+//
+//	GET    /healthz
+//	GET    /readyz
+//	GET    /collections
+//	PUT    /collections/{name}
+//	GET    /collections/{name}
+//	DELETE /collections/{name}
+//	PUT    /collections/{name}/points
+//	GET    /collections/{name}/points/{id}
+//	DELETE /collections/{name}/points/{id}
+//	POST   /collections/{name}/search
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /readyz", s.handleReady)
+	mux.HandleFunc("GET /collections", s.handleList)
+	mux.HandleFunc("PUT /collections/{name}", s.handleCreate)
+	mux.HandleFunc("GET /collections/{name}", s.handleInfo)
+	mux.HandleFunc("DELETE /collections/{name}", s.handleDrop)
+	mux.HandleFunc("PUT /collections/{name}/points", s.handleUpsert)
+	mux.HandleFunc("GET /collections/{name}/points/{id}", s.handleGetPoint)
+	mux.HandleFunc("DELETE /collections/{name}/points/{id}", s.handleDeletePoint)
+	mux.HandleFunc("POST /collections/{name}/search", s.handleSearch)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrAlreadyExists):
+		status = http.StatusConflict
+	case errors.Is(err, ErrInvalid):
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, body any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(body)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalid, err)
+	}
+	return nil
+}
+
+func pointId(r *http.Request) (uint64, error) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: point id must be an unsigned integer", ErrInvalid)
+	}
+	return id, nil
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if !s.Ready() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unavailable"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	res := []CollectionResponse{}
+	for _, name := range s.List() {
+		c, err := s.get(name)
+		if err != nil {
+			continue
+		}
+		res = append(res, c.describe(name))
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (c *collection) describe(name string) CollectionResponse {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return CollectionResponse{Name: name, Config: c.config, Points: c.index.Len()}
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var config CollectionConfig
+	err := readJSON(w, r, &config)
+	if err == nil {
+		err = s.Create(r.PathValue("name"), config)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	c, _ := s.get(r.PathValue("name"))
+	writeJSON(w, http.StatusCreated, c.describe(r.PathValue("name")))
+}
+
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	c, err := s.get(r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c.describe(r.PathValue("name")))
+}
+
+func (s *Server) handleDrop(w http.ResponseWriter, r *http.Request) {
+	err := s.Drop(r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleUpsert(w http.ResponseWriter, r *http.Request) {
+	c, err := s.get(r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req UpsertRequest
+	err = readJSON(w, r, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	points := make([]hnsw.Point, len(req.Points))
+	for i, p := range req.Points {
+		if p.Id == nil {
+			writeError(w, fmt.Errorf("%w: point %d has no id", ErrInvalid, i))
+			return
+		}
+		if len(p.Vector) != c.config.Dimension {
+			writeError(w, fmt.Errorf("%w: point %d must have dimension %d", ErrInvalid, *p.Id, c.config.Dimension))
+			return
+		}
+		points[i] = hnsw.Point{Id: *p.Id, Vector: p.Vector, Value: []byte(p.Value)}
+	}
+
+	c.mu.Lock()
+	c.index.UpsertBatch(points)
+	c.dirty = true
+	c.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]int{"upserted": len(points)})
+}
+
+func (s *Server) handleGetPoint(w http.ResponseWriter, r *http.Request) {
+	c, err := s.get(r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id, err := pointId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	c.mu.RLock()
+	point, ok := c.index.Get(id)
+	c.mu.RUnlock()
+	if !ok {
+		writeError(w, fmt.Errorf("point %d %w", id, ErrNotFound))
+		return
+	}
+	writeJSON(w, http.StatusOK, PointResponse{Id: point.Id, Vector: point.Vector, Value: string(point.Value)})
+}
+
+func (s *Server) handleDeletePoint(w http.ResponseWriter, r *http.Request) {
+	c, err := s.get(r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id, err := pointId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	c.mu.Lock()
+	removed := c.index.Remove(id)
+	if removed {
+		c.dirty = true
+	}
+	c.mu.Unlock()
+
+	if !removed {
+		writeError(w, fmt.Errorf("point %d %w", id, ErrNotFound))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	c, err := s.get(r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	req := SearchRequest{K: 10}
+	err = readJSON(w, r, &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(req.Vector) != c.config.Dimension {
+		writeError(w, fmt.Errorf("%w: vector must have dimension %d", ErrInvalid, c.config.Dimension))
+		return
+	}
+	if req.K <= 0 || req.K > hnsw.MaxK {
+		writeError(w, fmt.Errorf("%w: k must be in range [1, %d]", ErrInvalid, hnsw.MaxK))
+		return
+	}
+	if req.Ef < 0 || req.Ef > hnsw.MaxEf || req.Oversampling < 0 || req.Oversampling > hnsw.MaxOversampling {
+		writeError(w, fmt.Errorf("%w: ef must be in range [0, %d] and oversampling in range [0, %d]", ErrInvalid, hnsw.MaxEf, hnsw.MaxOversampling))
+		return
+	}
+
+	opts := hnsw.SearchOptions{Ef: req.Ef, Oversampling: req.Oversampling, Filter: req.Filter.toFilter()}
+
+	c.mu.RLock()
+	res := c.index.SearchWithOptions(req.Vector, req.K, opts)
+	c.mu.RUnlock()
+
+	writeJSON(w, http.StatusOK, map[string][]PointResponse{"results": toResponses(res)})
+}
+
+func toResponses(res []hnsw.SearchResult) []PointResponse {
+	responses := make([]PointResponse, len(res))
+	for i, r := range res {
+		responses[i] = PointResponse{Id: r.Id, Distance: &r.Distance, Value: string(r.Value)}
+	}
+	return responses
+}
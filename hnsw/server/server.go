@@ -0,0 +1,241 @@
+// Package server exposes named collections over an HTTP/JSON API and keeps
+// them persisted in a data directory.
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors/distances"
+	"go-hnsw/internal/fsutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const collectionExt = ".hnsw"
+
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrInvalid       = errors.New("invalid request")
+
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+type CollectionConfig struct {
+	Dimension      int    `json:"dimension"`
+	Metric         string `json:"metric"`
+	Layers         int    `json:"layers"`
+	Connectivity   int    `json:"connectivity"`
+	PrefetchFactor int    `json:"prefetch_factor"`
+}
+
+func (config *CollectionConfig) setDefaults() {
+	if config.Metric == "" {
+		config.Metric = "euclidian"
+	}
+	if config.Layers == 0 {
+		config.Layers = 3
+	}
+	if config.Connectivity == 0 {
+		config.Connectivity = 16
+	}
+	if config.PrefetchFactor == 0 {
+		config.PrefetchFactor = 4
+	}
+}
+
+func (config CollectionConfig) validate() error {
+	if config.Dimension <= 0 {
+		return fmt.Errorf("%w: dimension must be > 0", ErrInvalid)
+	}
+	if config.Layers <= 0 || config.Connectivity <= 0 || config.PrefetchFactor <= 0 {
+		return fmt.Errorf("%w: layers, connectivity and prefetch_factor must be > 0", ErrInvalid)
+	}
+	if _, ok := distances.ByName(config.Metric); !ok {
+		return fmt.Errorf("%w: unknown metric %q", ErrInvalid, config.Metric)
+	}
+	return nil
+}
+
+type collection struct {
+	mu     sync.RWMutex
+	config CollectionConfig
+	index  *hnsw.HnswCollection
+	dirty  bool
+}
+
+// Server owns the collections of a data directory. Every collection is
+// guarded by its own lock, so requests to different collections do not
+// contend.
+type Server struct {
+	dataDir     string
+	mu          sync.RWMutex
+	collections map[string]*collection
+	ready       atomic.Bool
+}
+
+// New opens every collection saved in dataDir, creating the directory when
+// it does not exist.
+func New(dataDir string) (*Server, error) {
+	err := os.MkdirAll(dataDir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{dataDir: dataDir, collections: map[string]*collection{}}
+
+	files, err := filepath.Glob(filepath.Join(dataDir, "*"+collectionExt))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.Base(path), collectionExt)
+		c, err := loadCollection(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading collection %s: %w", name, err)
+		}
+		s.collections[name] = c
+	}
+
+	s.ready.Store(true)
+	return s, nil
+}
+
+func loadCollection(path string) (*collection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	index, err := hnsw.LoadHnswCollection(bufio.NewReader(f), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := index.Stats()
+	metric, _ := distances.NameOf(index.Distance())
+	config := CollectionConfig{
+		Dimension:      stats.VectorDimension,
+		Metric:         metric,
+		Layers:         len(stats.Layers),
+		Connectivity:   stats.Connectivity,
+		PrefetchFactor: stats.PrefetchFactor,
+	}
+	return &collection{config: config, index: index}, nil
+}
+
+func (s *Server) path(name string) string {
+	return filepath.Join(s.dataDir, name+collectionExt)
+}
+
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
+
+func (s *Server) Create(name string, config CollectionConfig) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%w: collection name must match %s", ErrInvalid, namePattern)
+	}
+	config.setDefaults()
+	err := config.validate()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[name]; ok {
+		return fmt.Errorf("collection %s %w", name, ErrAlreadyExists)
+	}
+
+	distance, _ := distances.ByName(config.Metric)
+	c := &collection{
+		config: config,
+		index:  hnsw.NewHnswCollection(config.Layers, config.Dimension, distance, config.Connectivity, config.PrefetchFactor),
+		dirty:  true,
+	}
+	s.collections[name] = c
+	return nil
+}
+
+func (s *Server) Drop(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[name]; !ok {
+		return fmt.Errorf("collection %s %w", name, ErrNotFound)
+	}
+	delete(s.collections, name)
+
+	err := os.Remove(s.path(name))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) List() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.collections))
+	for name := range s.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Server) get(name string) (*collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("collection %s %w", name, ErrNotFound)
+	}
+	return c, nil
+}
+
+// Flush saves every collection changed since the previous flush.
+func (s *Server) Flush() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+	for name, c := range s.collections {
+		errs = append(errs, s.flushCollection(name, c))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) flushCollection(name string, c *collection) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	err := fsutil.WriteFileAtomic(s.path(name), c.index.Save)
+	if err != nil {
+		return fmt.Errorf("saving collection %s: %w", name, err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// Close marks the server as not ready and flushes all collections.
+func (s *Server) Close() error {
+	s.ready.Store(false)
+	return s.Flush()
+}
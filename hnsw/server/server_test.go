@@ -0,0 +1,215 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-hnsw/hnsw"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func do(t *testing.T, srv *httptest.Server, method string, path string, body any, out any) int {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, srv.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if out != nil {
+		err = json.NewDecoder(res.Body).Decode(out)
+		if err != nil {
+			t.Fatalf("%s %s: decoding response: %s", method, path, err)
+		}
+	}
+	return res.StatusCode
+}
+
+func newTestServer(t *testing.T, dataDir string) (*Server, *httptest.Server) {
+	s, err := New(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+func upsertGrid(t *testing.T, srv *httptest.Server, name string, n int) {
+	points := []map[string]any{}
+	for i := 0; i < n; i += 1 {
+		points = append(points, map[string]any{
+			"id":     i,
+			"vector": []float32{float32(i), float32(i % 7)},
+			"value":  fmt.Sprintf("point-%d", i),
+		})
+	}
+	status := do(t, srv, "PUT", "/collections/"+name+"/points", map[string]any{"points": points}, nil)
+	if status != http.StatusOK {
+		t.Fatalf("upsert returned %d", status)
+	}
+}
+
+func TestCollectionLifecycle(t *testing.T) {
+	_, srv := newTestServer(t, t.TempDir())
+
+	if status := do(t, srv, "GET", "/healthz", nil, nil); status != http.StatusOK {
+		t.Fatalf("healthz returned %d", status)
+	}
+	if status := do(t, srv, "GET", "/readyz", nil, nil); status != http.StatusOK {
+		t.Fatalf("readyz returned %d", status)
+	}
+
+	var created CollectionResponse
+	status := do(t, srv, "PUT", "/collections/docs", CollectionConfig{Dimension: 2, Connectivity: 4}, &created)
+	if status != http.StatusCreated {
+		t.Fatalf("create returned %d", status)
+	}
+	if created.Config.Metric != "euclidian" || created.Config.Layers != 3 {
+		t.Fatalf("defaults not applied: %+v", created.Config)
+	}
+	if status := do(t, srv, "PUT", "/collections/docs", CollectionConfig{Dimension: 2}, nil); status != http.StatusConflict {
+		t.Fatalf("duplicate create returned %d", status)
+	}
+
+	var list []CollectionResponse
+	do(t, srv, "GET", "/collections", nil, &list)
+	if len(list) != 1 || list[0].Name != "docs" {
+		t.Fatalf("unexpected collections %+v", list)
+	}
+
+	if status := do(t, srv, "DELETE", "/collections/docs", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("drop returned %d", status)
+	}
+	if status := do(t, srv, "GET", "/collections/docs", nil, nil); status != http.StatusNotFound {
+		t.Fatalf("get after drop returned %d", status)
+	}
+}
+
+func TestInvalidRequests(t *testing.T) {
+	_, srv := newTestServer(t, t.TempDir())
+	do(t, srv, "PUT", "/collections/docs", CollectionConfig{Dimension: 2}, nil)
+
+	cases := []struct {
+		method string
+		path   string
+		body   any
+		status int
+	}{
+		{"PUT", "/collections/bad.name", CollectionConfig{Dimension: 2}, http.StatusBadRequest},
+		{"PUT", "/collections/other", CollectionConfig{Dimension: 0}, http.StatusBadRequest},
+		{"PUT", "/collections/other", CollectionConfig{Dimension: 2, Metric: "nope"}, http.StatusBadRequest},
+		{"PUT", "/collections/docs/points", map[string]any{"points": []map[string]any{{"vector": []float32{1, 2}}}}, http.StatusBadRequest},
+		{"PUT", "/collections/docs/points", map[string]any{"points": []map[string]any{{"id": 1, "vector": []float32{1}}}}, http.StatusBadRequest},
+		{"POST", "/collections/docs/search", map[string]any{"vector": []float32{1, 2, 3}}, http.StatusBadRequest},
+		{"POST", "/collections/docs/search", map[string]any{"vector": []float32{1, 2}, "k": -1}, http.StatusBadRequest},
+		{"POST", "/collections/docs/search", map[string]any{"vector": []float32{1, 2}, "k": hnsw.MaxK + 1}, http.StatusBadRequest},
+		{"POST", "/collections/docs/search", map[string]any{"vector": []float32{1, 2}, "ef": hnsw.MaxEf + 1}, http.StatusBadRequest},
+		{"POST", "/collections/docs/search", map[string]any{"vector": []float32{1, 2}, "oversampling": hnsw.MaxOversampling + 1}, http.StatusBadRequest},
+		{"POST", "/collections/docs/search", map[string]any{"vektor": []float32{1, 2}}, http.StatusBadRequest},
+		{"POST", "/collections/missing/search", map[string]any{"vector": []float32{1, 2}}, http.StatusNotFound},
+		{"GET", "/collections/docs/points/abc", nil, http.StatusBadRequest},
+		{"GET", "/collections/docs/points/42", nil, http.StatusNotFound},
+		{"DELETE", "/collections/docs/points/42", nil, http.StatusNotFound},
+	}
+
+	for _, c := range cases {
+		var res map[string]string
+		status := do(t, srv, c.method, c.path, c.body, &res)
+		if status != c.status {
+			t.Fatalf("%s %s returned %d, expected %d", c.method, c.path, status, c.status)
+		}
+		if res["error"] == "" {
+			t.Fatalf("%s %s returned no error message", c.method, c.path)
+		}
+	}
+}
+
+func TestPointsAndSearch(t *testing.T) {
+	_, srv := newTestServer(t, t.TempDir())
+	do(t, srv, "PUT", "/collections/docs", CollectionConfig{Dimension: 2, Connectivity: 4}, nil)
+	upsertGrid(t, srv, "docs", 50)
+
+	var point PointResponse
+	if status := do(t, srv, "GET", "/collections/docs/points/10", nil, &point); status != http.StatusOK {
+		t.Fatalf("get point returned %d", status)
+	}
+	if point.Id != 10 || point.Value != "point-10" || point.Vector[0] != 10 {
+		t.Fatalf("unexpected point %+v", point)
+	}
+
+	var res struct{ Results []PointResponse }
+	do(t, srv, "POST", "/collections/docs/search", map[string]any{"vector": []float32{10, 3}, "k": 3, "ef": 50}, &res)
+	if len(res.Results) != 3 || res.Results[0].Id != 10 || *res.Results[0].Distance != 0 {
+		t.Fatalf("unexpected results %+v", res.Results)
+	}
+
+	filter := map[string]any{"exclude_ids": []uint64{10}, "value_prefix": "point-1"}
+	do(t, srv, "POST", "/collections/docs/search", map[string]any{"vector": []float32{10, 3}, "k": 3, "ef": 50, "filter": filter}, &res)
+	for _, r := range res.Results {
+		if r.Id == 10 || r.Value[:7] != "point-1" {
+			t.Fatalf("filter not applied: %+v", res.Results)
+		}
+	}
+
+	if status := do(t, srv, "DELETE", "/collections/docs/points/10", nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete point returned %d", status)
+	}
+	if status := do(t, srv, "GET", "/collections/docs/points/10", nil, nil); status != http.StatusNotFound {
+		t.Fatalf("get deleted point returned %d", status)
+	}
+
+	var info CollectionResponse
+	do(t, srv, "GET", "/collections/docs", nil, &info)
+	if info.Points != 49 {
+		t.Fatalf("expected 49 points, got %d", info.Points)
+	}
+}
+
+func TestFlushAndReload(t *testing.T) {
+	dataDir := t.TempDir()
+	s, srv := newTestServer(t, dataDir)
+	do(t, srv, "PUT", "/collections/docs", CollectionConfig{Dimension: 2, Metric: "cosine", Connectivity: 4}, nil)
+	upsertGrid(t, srv, "docs", 20)
+
+	err := s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := do(t, srv, "GET", "/readyz", nil, nil); status != http.StatusServiceUnavailable {
+		t.Fatalf("readyz after close returned %d", status)
+	}
+
+	_, srv = newTestServer(t, dataDir)
+	var info CollectionResponse
+	do(t, srv, "GET", "/collections/docs", nil, &info)
+	if info.Points != 20 || info.Config.Metric != "cosine" || info.Config.Connectivity != 4 {
+		t.Fatalf("unexpected reloaded collection %+v", info)
+	}
+
+	do(t, srv, "DELETE", "/collections/docs", nil, nil)
+	_, srv = newTestServer(t, dataDir)
+	var list []CollectionResponse
+	do(t, srv, "GET", "/collections", nil, &list)
+	if len(list) != 0 {
+		t.Fatalf("dropped collection was reloaded: %+v", list)
+	}
+}
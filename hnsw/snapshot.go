@@ -0,0 +1,85 @@
+package hnsw
+
+import (
+	"go-hnsw/hnsw/vectors/distances"
+	"io"
+	"maps"
+	"sync"
+)
+
+// snapshotState keeps the neighbor maps a running Snapshot has not written
+// yet as they were when it started. Writers hand a node to preserve before
+// changing its neighbors; the snapshot then reads the preserved copy instead
+// of the live map.
+type snapshotState struct {
+	mu        sync.Mutex
+	preserved map[*Node]map[uint64]*Node
+	written   map[*Node]bool
+}
+
+func (state *snapshotState) preserve(node *Node) {
+	if state == nil {
+		return
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if state.written[node] {
+		return
+	}
+	if _, ok := state.preserved[node]; !ok {
+		state.preserved[node] = maps.Clone(node.neighbors)
+	}
+}
+
+func (state *snapshotState) neighbors(node *Node) map[uint64]*Node {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.written[node] = true
+	if neighbors, ok := state.preserved[node]; ok {
+		delete(state.preserved, node)
+		return neighbors
+	}
+	// Writers preserve a node before touching it, so the live map cannot
+	// change until the lock is released.
+	return maps.Clone(node.neighbors)
+}
+
+// Snapshot writes the collection as it is when the call starts, in the
+// format of Save, while Add, Upsert and Remove keep running. Writers are
+// only held back while the node lists are copied; afterwards a neighbor map
+// is copied the first time a writer changes it before the snapshot wrote
+// it. Searches still must not run concurrently with writers.
+func (hnsw *HnswCollection) Snapshot(writer io.Writer) error {
+	hnsw.snapshotMu.Lock()
+	defer hnsw.snapshotMu.Unlock()
+
+	state := &snapshotState{preserved: map[*Node]map[uint64]*Node{}, written: map[*Node]bool{}}
+
+	hnsw.writeMu.Lock()
+	image := hnsw.image()
+	for i, layer := range hnsw.layers {
+		image.layers[i] = append([]*Node(nil), layer.nodes...)
+		layer.snapshot = state
+	}
+	hnsw.writeMu.Unlock()
+
+	defer func() {
+		hnsw.writeMu.Lock()
+		for _, layer := range hnsw.layers {
+			layer.snapshot = nil
+		}
+		hnsw.writeMu.Unlock()
+	}()
+
+	image.neighbors = state.neighbors
+	return image.save(writer)
+}
+
+// Restore reads a collection written by Snapshot or Save. When distance is
+// nil the distance registered under the saved name is used.
+func Restore(reader io.Reader, distance distances.Distance) (*HnswCollection, error) {
+	return LoadHnswCollection(reader, distance)
+}
@@ -0,0 +1,136 @@
+package hnsw
+
+import (
+	"bytes"
+	"go-hnsw/hnsw/vectors/distances"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// pausingWriter blocks its first write until release is closed, so that
+// writers can change the collection in the middle of a snapshot.
+type pausingWriter struct {
+	bytes.Buffer
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *pausingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.started)
+		<-w.release
+	})
+	return w.Buffer.Write(p)
+}
+
+func TestSnapshotIsPointInTime(t *testing.T) {
+	data := randomVectors(2000, 8)
+	collection := NewHnswCollection(3, 8, distances.Euclidian, 6, 3)
+	for i, v := range data[:1000] {
+		collection.Add(v, []byte{byte(i)})
+	}
+	expected := map[uint64]Point{}
+	collection.Range(func(point Point) bool {
+		expected[point.Id] = point
+		return true
+	})
+
+	writer := &pausingWriter{started: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan error)
+	go func() {
+		done <- collection.Snapshot(writer)
+	}()
+
+	<-writer.started
+	for i, v := range data[1000:] {
+		collection.Add(v, nil)
+		collection.Remove(uint64(i))
+		collection.Upsert(uint64(500+i%100), v, []byte("updated"))
+	}
+	close(writer.release)
+	err := <-done
+	if err != nil {
+		t.Fatalf("'Snapshot' returned error: %s", err)
+	}
+
+	restored, err := Restore(&writer.Buffer, nil)
+	if err != nil {
+		t.Fatalf("'Restore' returned error: %s", err)
+	}
+	err = restored.Verify()
+	if err != nil {
+		t.Fatalf("Restored collection is inconsistent: %s", err)
+	}
+	if restored.Len() != len(expected) {
+		t.Fatalf("Restored collection has %d points but %d expected", restored.Len(), len(expected))
+	}
+	restored.Range(func(point Point) bool {
+		if !reflect.DeepEqual(point, expected[point.Id]) {
+			t.Fatalf("Restored point %d differs from the snapshot time", point.Id)
+		}
+		return true
+	})
+	if id := restored.Add(data[0], nil); id != 1000 {
+		t.Fatalf("Restored collection generated id %d but 1000 expected", id)
+	}
+
+	err = collection.Verify()
+	if err != nil {
+		t.Fatalf("Live collection is inconsistent after the snapshot: %s", err)
+	}
+}
+
+func TestSnapshotWithConcurrentInserts(t *testing.T) {
+	data := randomVectors(3000, 8)
+	collection := NewHnswCollection(3, 8, distances.Euclidian, 6, 3)
+	for _, v := range data[:1000] {
+		collection.Add(v, nil)
+	}
+
+	stop := make(chan struct{})
+	inserted := make(chan int)
+	go func() {
+		n := 0
+		for _, v := range data[1000:] {
+			select {
+			case <-stop:
+				inserted <- n
+				return
+			default:
+			}
+			collection.Add(v, nil)
+			n += 1
+		}
+		inserted <- n
+	}()
+
+	buff := new(bytes.Buffer)
+	err := collection.Snapshot(buff)
+	close(stop)
+	n := <-inserted
+	if err != nil {
+		t.Fatalf("'Snapshot' returned error: %s", err)
+	}
+
+	restored, err := Restore(buff, nil)
+	if err != nil {
+		t.Fatalf("'Restore' returned error: %s", err)
+	}
+	err = restored.Verify()
+	if err != nil {
+		t.Fatalf("Restored collection is inconsistent: %s", err)
+	}
+	if restored.Len() < 1000 || restored.Len() > 1000+n {
+		t.Fatalf("Restored collection has %d points, expected between 1000 and %d", restored.Len(), 1000+n)
+	}
+	for id := uint64(0); id < uint64(restored.Len()); id += 1 {
+		if _, ok := restored.Get(id); !ok {
+			t.Fatalf("Restored collection misses point %d inserted before the snapshot", id)
+		}
+	}
+	if collection.Len() != 1000+n {
+		t.Fatalf("Live collection has %d points but %d expected", collection.Len(), 1000+n)
+	}
+}
@@ -0,0 +1,82 @@
+package sparse
+
+import (
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"sort"
+)
+
+// DenseIndex is a dense vector index sharing its ids with a sparse Index.
+type DenseIndex interface {
+	hnsw.Index
+	Distance() distances.Distance
+}
+
+type HybridOptions struct {
+	DenseWeight  float64
+	SparseWeight float64
+	// Candidates is how many points are taken from each index before exact
+	// re-scoring. Zero means four times the requested number of results.
+	Candidates int
+}
+
+type HybridResult struct {
+	Id uint64
+	// Distance is DenseWeight*DenseDistance + SparseWeight*SparseDistance.
+	Distance       vectors.VFloat
+	DenseDistance  vectors.VFloat
+	SparseDistance vectors.VFloat
+	Value          []byte
+}
+
+// SearchHybrid takes candidates from both indexes, scores every candidate
+// with both its dense and sparse distance and returns the k nearest by the
+// weighted sum. The weights must account for the scales of the two metrics.
+// A point without a sparse vector gets the distance of an empty one; points
+// without a dense vector are skipped.
+func SearchHybrid(dense DenseIndex, sparse *Index, denseQuery vectors.Vector, sparseQuery vectors.SparseVector, k int, opts HybridOptions) []HybridResult {
+	candidates := opts.Candidates
+	if candidates <= 0 {
+		candidates = 4 * k
+	}
+
+	ids := map[uint64]bool{}
+	for _, r := range dense.Search(denseQuery, candidates) {
+		ids[r.Id] = true
+	}
+	for _, r := range sparse.Search(sparseQuery, candidates) {
+		ids[r.Id] = true
+	}
+
+	denseDistance := dense.Distance()
+	sparseDistance := sparse.metric.Distance()
+	results := make([]HybridResult, 0, len(ids))
+	for id := range ids {
+		point, ok := dense.Get(id)
+		if !ok {
+			continue
+		}
+		sparseVector, _, _ := sparse.Get(id)
+
+		r := HybridResult{
+			Id:             id,
+			DenseDistance:  denseDistance(denseQuery, point.Vector),
+			SparseDistance: sparseDistance(sparseQuery, sparseVector),
+			Value:          point.Value,
+		}
+		r.Distance = vectors.VFloat(opts.DenseWeight)*r.DenseDistance + vectors.VFloat(opts.SparseWeight)*r.SparseDistance
+		results = append(results, r)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		return results[i].Id < results[j].Id
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
@@ -0,0 +1,211 @@
+// Package sparse searches sparse vectors, such as SPLADE embeddings, through
+// an inverted index: only the points sharing a non-zero dimension with the
+// query are scored, and they are scored exactly.
+package sparse
+
+import (
+	"container/heap"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"sort"
+	"sync"
+)
+
+type Metric int
+
+const (
+	// Dot ranks by dot product, reported as the SparseDot distance.
+	Dot Metric = iota
+	// Cosine ranks by cosine similarity, reported as the SparseCosine
+	// distance.
+	Cosine
+)
+
+func (metric Metric) Distance() distances.SparseDistance {
+	if metric == Cosine {
+		return distances.SparseCosine
+	}
+	return distances.SparseDot
+}
+
+type Result struct {
+	Id       uint64
+	Distance vectors.VFloat
+	Vector   vectors.SparseVector
+	Value    []byte
+}
+
+type point struct {
+	vector vectors.SparseVector
+	norm   vectors.VFloat
+	value  []byte
+}
+
+// Index is safe for concurrent use.
+type Index struct {
+	metric Metric
+
+	mu        sync.RWMutex
+	points    map[uint64]point
+	postings  map[uint32]map[uint64]vectors.VFloat
+	idCounter uint64
+}
+
+func NewIndex(metric Metric) *Index {
+	return &Index{
+		metric:   metric,
+		points:   map[uint64]point{},
+		postings: map[uint32]map[uint64]vectors.VFloat{},
+	}
+}
+
+// Add normalizes vector as vectors.NewSparseVector does, so its indices may
+// come in any order and repeat.
+func (idx *Index) Add(vector vectors.SparseVector, value []byte) uint64 {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	id := idx.idCounter
+	idx.insert(id, vector, value)
+	return id
+}
+
+// Upsert is Add under id, dropping the postings of the vector stored there.
+func (idx *Index) Upsert(id uint64, vector vectors.SparseVector, value []byte) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+	idx.insert(id, vector, value)
+}
+
+func (idx *Index) insert(id uint64, vector vectors.SparseVector, value []byte) {
+	// Search merges sorted indices, so they must not repeat.
+	vector = vectors.NewSparseVector(vector.Indices, vector.Values)
+	idx.points[id] = point{vector: vector, norm: vector.Norm(), value: value}
+	for i, dim := range vector.Indices {
+		docs := idx.postings[dim]
+		if docs == nil {
+			docs = map[uint64]vectors.VFloat{}
+			idx.postings[dim] = docs
+		}
+		docs[id] = vector.Values[i]
+	}
+	if id >= idx.idCounter {
+		idx.idCounter = id + 1
+	}
+}
+
+func (idx *Index) Remove(id uint64) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.remove(id)
+}
+
+func (idx *Index) remove(id uint64) bool {
+	p, ok := idx.points[id]
+	if !ok {
+		return false
+	}
+	for _, dim := range p.vector.Indices {
+		docs := idx.postings[dim]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.postings, dim)
+		}
+	}
+	delete(idx.points, id)
+	return true
+}
+
+func (idx *Index) Get(id uint64) (vectors.SparseVector, []byte, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	p, ok := idx.points[id]
+	return p.vector, p.value, ok
+}
+
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.points)
+}
+
+func (idx *Index) Search(query vectors.SparseVector, k int) []Result {
+	return idx.SearchFiltered(query, k, nil)
+}
+
+// SearchFiltered returns the k nearest points sharing at least one non-zero
+// dimension with query, nearest first.
+func (idx *Index) SearchFiltered(query vectors.SparseVector, k int, filter hnsw.Filter) []Result {
+	query = vectors.NewSparseVector(query.Indices, query.Values)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	dots := map[uint64]vectors.VFloat{}
+	for i, dim := range query.Indices {
+		weight := query.Values[i]
+		for id, value := range idx.postings[dim] {
+			dots[id] += weight * value
+		}
+	}
+
+	queryNorm := query.Norm()
+	best := &resultHeap{}
+	for id, dot := range dots {
+		p := idx.points[id]
+		if filter != nil && !filter(id, p.value) {
+			continue
+		}
+
+		distance := -dot
+		if idx.metric == Cosine {
+			distance = 1
+			if norms := queryNorm * p.norm; norms != 0 {
+				distance = 1 - dot/norms
+			}
+		}
+
+		heap.Push(best, Result{Id: id, Distance: distance, Vector: p.vector, Value: p.value})
+		if best.Len() > k {
+			heap.Pop(best)
+		}
+	}
+
+	results := []Result(*best)
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		return results[i].Id < results[j].Id
+	})
+	return results
+}
+
+// resultHeap is a max-heap on distance keeping the nearest results seen.
+type resultHeap []Result
+
+func (h resultHeap) Len() int {
+	return len(h)
+}
+
+func (h resultHeap) Less(i, j int) bool {
+	return h[i].Distance > h[j].Distance
+}
+
+func (h resultHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *resultHeap) Push(x any) {
+	*h = append(*h, x.(Result))
+}
+
+func (h *resultHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
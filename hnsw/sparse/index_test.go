@@ -0,0 +1,157 @@
+package sparse
+
+import (
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"math"
+	"math/rand/v2"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+const vocabulary = 30000
+
+func randomSparse(nonZero int) vectors.SparseVector {
+	components := map[uint32]vectors.VFloat{}
+	for len(components) < nonZero {
+		// Skewed towards low indices so that points share dimensions.
+		dim := uint32(rand.ExpFloat64()*200) % vocabulary
+		components[dim] = vectors.VFloat(rand.Float64())
+	}
+	return vectors.SparseFromMap(components)
+}
+
+func bruteForce(data []vectors.SparseVector, query vectors.SparseVector, k int, distance distances.SparseDistance) []uint64 {
+	ids := []uint64{}
+	for id, v := range data {
+		if v.Dot(query) != 0 {
+			ids = append(ids, uint64(id))
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		di, dj := distance(query, data[ids[i]]), distance(query, data[ids[j]])
+		if di != dj {
+			return di < dj
+		}
+		return ids[i] < ids[j]
+	})
+	return ids[:min(k, len(ids))]
+}
+
+func TestSearchIsExact(t *testing.T) {
+	data := make([]vectors.SparseVector, 1000)
+	for i := range data {
+		data[i] = randomSparse(30)
+	}
+
+	for _, metric := range []Metric{Dot, Cosine} {
+		idx := NewIndex(metric)
+		for _, v := range data {
+			idx.Add(v, nil)
+		}
+
+		for q := 0; q < 20; q += 1 {
+			query := randomSparse(10)
+			res := idx.Search(query, 10)
+			expected := bruteForce(data, query, 10, metric.Distance())
+			if len(res) != len(expected) {
+				t.Fatalf("metric %d: got %d results, expected %d", metric, len(res), len(expected))
+			}
+			for i, r := range res {
+				want := metric.Distance()(query, data[expected[i]])
+				if math.Abs(float64(r.Distance-want)) > 1e-9 {
+					t.Fatalf("metric %d: result %d has distance %f, expected %f", metric, i, r.Distance, want)
+				}
+			}
+		}
+	}
+}
+
+func TestUpsertRemoveFilter(t *testing.T) {
+	idx := NewIndex(Dot)
+	a := vectors.SparseFromMap(map[uint32]vectors.VFloat{1: 1, 2: 1})
+	b := vectors.SparseFromMap(map[uint32]vectors.VFloat{2: 2})
+	c := vectors.SparseFromMap(map[uint32]vectors.VFloat{3: 5})
+
+	idx.Add(a, []byte("a"))
+	idx.Add(b, []byte("b"))
+	idx.Upsert(10, c, []byte("c"))
+	if id := idx.Add(a, nil); id != 11 {
+		t.Fatalf("expected id 11 after upserting 10, got %d", id)
+	}
+
+	query := vectors.SparseFromMap(map[uint32]vectors.VFloat{2: 1, 3: 1})
+	res := idx.Search(query, 10)
+	if len(res) != 4 || res[0].Id != 10 || res[0].Distance != -5 || res[1].Id != 1 {
+		t.Fatalf("unexpected results %+v", res)
+	}
+
+	idx.Upsert(10, a, []byte("c2"))
+	idx.Remove(1)
+	res = idx.SearchFiltered(query, 10, func(id uint64, value []byte) bool {
+		return id != 11
+	})
+	if len(res) != 2 || res[0].Id != 0 || res[1].Id != 10 || string(res[1].Value) != "c2" {
+		t.Fatalf("unexpected results after changes %+v", res)
+	}
+	if idx.Len() != 3 {
+		t.Fatalf("expected 3 points, got %d", idx.Len())
+	}
+}
+
+func TestSearchHybrid(t *testing.T) {
+	dense := hnsw.NewFlatCollection(2, distances.Euclidian)
+	idx := NewIndex(Dot)
+
+	points := []struct {
+		dense  vectors.Vector
+		sparse map[uint32]vectors.VFloat
+	}{
+		{vectors.Vector{0, 0}, map[uint32]vectors.VFloat{}},
+		{vectors.Vector{0.1, 0}, map[uint32]vectors.VFloat{100: 1}},
+		{vectors.Vector{5, 5}, map[uint32]vectors.VFloat{100: 3}},
+	}
+	for _, p := range points {
+		id := dense.Add(p.dense, nil)
+		idx.Upsert(id, vectors.SparseFromMap(p.sparse), nil)
+	}
+
+	denseQuery := vectors.Vector{0, 0}
+	sparseQuery := vectors.SparseFromMap(map[uint32]vectors.VFloat{100: 1})
+
+	res := SearchHybrid(dense, idx, denseQuery, sparseQuery, 3, HybridOptions{DenseWeight: 1})
+	if res[0].Id != 0 {
+		t.Fatalf("dense-only ranking returned %+v", res)
+	}
+	res = SearchHybrid(dense, idx, denseQuery, sparseQuery, 3, HybridOptions{SparseWeight: 1})
+	if res[0].Id != 2 {
+		t.Fatalf("sparse-only ranking returned %+v", res)
+	}
+	res = SearchHybrid(dense, idx, denseQuery, sparseQuery, 3, HybridOptions{DenseWeight: 1, SparseWeight: 1})
+	if res[0].Id != 1 || math.Abs(float64(res[0].Distance)-(0.1-1)) > 1e-12 {
+		t.Fatalf("mixed ranking returned %+v", res)
+	}
+}
+
+func TestUnsortedLiteralsAreNormalized(t *testing.T) {
+	idx := NewIndex(Cosine)
+	id := idx.Add(vectors.SparseVector{Indices: []uint32{7, 2, 7}, Values: []vectors.VFloat{1, 2, 2}}, nil)
+	idx.Upsert(id+1, vectors.SparseVector{Indices: []uint32{2, 7}, Values: []vectors.VFloat{2, 3}}, nil)
+
+	stored, _, _ := idx.Get(id)
+	if !reflect.DeepEqual(stored.Indices, []uint32{2, 7}) || !reflect.DeepEqual(stored.Values, []vectors.VFloat{2, 3}) {
+		t.Fatalf("Expected the stored vector to be normalized but found %s", stored)
+	}
+
+	res := idx.Search(vectors.SparseVector{Indices: []uint32{7, 2}, Values: []vectors.VFloat{3, 2}}, 2)
+	if len(res) != 2 {
+		t.Fatalf("Expected 2 results but found %d", len(res))
+	}
+	for _, r := range res {
+		if math.Abs(float64(r.Distance)) > 1e-9 {
+			t.Fatalf("Point %d is the query itself but found at distance %f", r.Id, r.Distance)
+		}
+	}
+}
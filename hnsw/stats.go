@@ -0,0 +1,115 @@
+package hnsw
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+type LayerStats struct {
+	Nodes     int
+	Edges     int
+	MinDegree int
+	MaxDegree int
+	AvgDegree float64
+	// DegreeHistogram maps a node degree to the number of nodes having it.
+	DegreeHistogram map[int]int
+}
+
+type Stats struct {
+	Points          int
+	VectorDimension int
+	Connectivity    int
+	PrefetchFactor  int
+	Layers          []LayerStats
+	// MemoryBytes is an estimate of the memory held by vectors, codes, values
+	// and adjacency maps.
+	MemoryBytes int
+}
+
+const mapEntryOverhead = 48
+
+func (hnsw *HnswCollection) Stats() Stats {
+	stats := Stats{
+		Points:          hnsw.Len(),
+		VectorDimension: hnsw.vectorDimension,
+		Connectivity:    hnsw.connectivity,
+		PrefetchFactor:  hnsw.prefetchFactor,
+	}
+
+	nodeSize := int(unsafe.Sizeof(Node{}))
+	for _, node := range hnsw.bottomLayer().nodes {
+		stats.MemoryBytes += len(node.Vector)*int(unsafe.Sizeof(node.Vector[0])) + len(node.Value) + len(node.Codes) + len(node.Bits)*8
+	}
+
+	for _, layer := range hnsw.layers {
+		ls := LayerStats{Nodes: len(layer.nodes), DegreeHistogram: map[int]int{}}
+		for i, node := range layer.nodes {
+			degree := len(node.neighbors)
+			ls.Edges += degree
+			ls.DegreeHistogram[degree] += 1
+			if i == 0 || degree < ls.MinDegree {
+				ls.MinDegree = degree
+			}
+			ls.MaxDegree = max(ls.MaxDegree, degree)
+		}
+		if ls.Nodes > 0 {
+			ls.AvgDegree = float64(ls.Edges) / float64(ls.Nodes)
+		}
+		ls.Edges /= 2
+
+		stats.MemoryBytes += ls.Nodes*(nodeSize+mapEntryOverhead) + 2*ls.Edges*mapEntryOverhead
+		stats.Layers = append(stats.Layers, ls)
+	}
+
+	return stats
+}
+
+// Verify checks the graph invariants: layers index their nodes consistently,
+// edges are symmetric and stay inside a layer, and every node links to its copy
+// in the layer below.
+func (hnsw *HnswCollection) Verify() error {
+	for i, layer := range hnsw.layers {
+		if len(layer.rindex) != len(layer.nodes) {
+			return fmt.Errorf("layer %d: index has %d entries for %d nodes", i, len(layer.rindex), len(layer.nodes))
+		}
+
+		var lower *Layer
+		if i < len(hnsw.layers)-1 {
+			lower = hnsw.layers[i+1]
+		}
+
+		for idx, node := range layer.nodes {
+			if layer.rindex[node.Id] != idx {
+				return fmt.Errorf("layer %d: node %d is indexed at %d but stored at %d", i, node.Id, layer.rindex[node.Id], idx)
+			}
+			if len(node.Vector) != hnsw.vectorDimension {
+				return fmt.Errorf("layer %d: node %d has dimension %d", i, node.Id, len(node.Vector))
+			}
+
+			for id, nbh := range node.neighbors {
+				if id == node.Id {
+					return fmt.Errorf("layer %d: node %d links to itself", i, id)
+				}
+				stored, ok := layer.Get(id)
+				if !ok || stored != nbh {
+					return fmt.Errorf("layer %d: node %d links to node %d outside of the layer", i, node.Id, id)
+				}
+				if nbh.neighbors[node.Id] != node {
+					return fmt.Errorf("layer %d: edge %d -> %d has no reverse edge", i, node.Id, id)
+				}
+			}
+
+			if lower == nil {
+				continue
+			}
+			next, ok := lower.Get(node.Id)
+			if !ok {
+				return fmt.Errorf("layer %d: node %d is missing from layer %d", i, node.Id, i+1)
+			}
+			if node.NextLevel != next {
+				return fmt.Errorf("layer %d: node %d does not link to its copy in layer %d", i, node.Id, i+1)
+			}
+		}
+	}
+	return nil
+}
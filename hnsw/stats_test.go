@@ -0,0 +1,67 @@
+package hnsw
+
+import (
+	"go-hnsw/hnsw/vectors/distances"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	hnswCollection := NewHnswCollection(3, 4, distances.Euclidian, 4, 2)
+	hnswCollection.AddBatch(randomVectors(100, 4), nil)
+
+	stats := hnswCollection.Stats()
+
+	if stats.Points != 100 || len(stats.Layers) != 3 {
+		t.Fatalf("Unexpected stats %+v", stats)
+	}
+
+	bottom := stats.Layers[2]
+	if bottom.Nodes != 100 {
+		t.Fatalf("Bottom layer expected to have 100 nodes but %d found", bottom.Nodes)
+	}
+
+	nodes := 0
+	for degree, count := range bottom.DegreeHistogram {
+		nodes += count
+		if degree < bottom.MinDegree || degree > bottom.MaxDegree {
+			t.Fatalf("Degree %d is outside of [%d, %d]", degree, bottom.MinDegree, bottom.MaxDegree)
+		}
+	}
+	if nodes != 100 {
+		t.Fatalf("Degree histogram must cover 100 nodes but covers %d", nodes)
+	}
+
+	if stats.MemoryBytes < 100*4*8 {
+		t.Fatalf("Memory estimate %d is smaller than the vectors", stats.MemoryBytes)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	hnswCollection := NewHnswCollection(3, 4, distances.Euclidian, 4, 2)
+	hnswCollection.AddBatch(randomVectors(100, 4), nil)
+	hnswCollection.Remove(10)
+	hnswCollection.Remove(20)
+
+	err := hnswCollection.Verify()
+	if err != nil {
+		t.Fatalf("Valid collection failed verification: %s", err)
+	}
+
+	node := hnswCollection.bottomLayer().nodes[0]
+	for _, n := range hnswCollection.bottomLayer().nodes {
+		if len(n.neighbors) > 0 {
+			node = n
+			break
+		}
+	}
+	for id := range node.neighbors {
+		delete(node.neighbors, id)
+		break
+	}
+
+	err = hnswCollection.Verify()
+	if err == nil || !strings.Contains(err.Error(), "no reverse edge") {
+		t.Fatalf("Missing reverse edge must be reported but '%v' found", err)
+	}
+}
@@ -25,5 +25,5 @@ var Cosine Distance = func(vector1, vector2 vectors.Vector) vectors.VFloat {
 	abs1 := vectors.VectorAbs(vector1)
 	abs2 := vectors.VectorAbs(vector2)
 
-	return dotProd / (abs1 * abs2)
+	return 1 - dotProd/(abs1*abs2)
 }
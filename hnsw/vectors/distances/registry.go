@@ -0,0 +1,41 @@
+package distances
+
+import (
+	"reflect"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Distance{
+		"euclidian": Euclidian,
+		"cosine":    Cosine,
+	}
+)
+
+// Register makes a distance available by name, so collections saved with it
+// can be loaded without passing the function explicitly.
+func Register(name string, distance Distance) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = distance
+}
+
+func ByName(name string) (Distance, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	distance, ok := registry[name]
+	return distance, ok
+}
+
+func NameOf(distance Distance) (string, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	ptr := reflect.ValueOf(distance).Pointer()
+	for name, d := range registry {
+		if reflect.ValueOf(d).Pointer() == ptr {
+			return name, true
+		}
+	}
+	return "", false
+}
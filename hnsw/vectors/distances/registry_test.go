@@ -0,0 +1,37 @@
+package distances
+
+import (
+	"go-hnsw/hnsw/vectors"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	name, ok := NameOf(Euclidian)
+	if !ok || name != "euclidian" {
+		t.Fatalf("Euclidian distance expected to be registered as 'euclidian' but '%s' found", name)
+	}
+
+	var manhattan Distance = func(v1, v2 vectors.Vector) vectors.VFloat {
+		var dst vectors.VFloat = 0
+		for i := range v1 {
+			dst += max(v1[i]-v2[i], v2[i]-v1[i])
+		}
+		return dst
+	}
+
+	if _, ok = NameOf(manhattan); ok {
+		t.Fatal("Unregistered distance must not have a name")
+	}
+
+	Register("manhattan", manhattan)
+	t.Cleanup(func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		delete(registry, "manhattan")
+	})
+
+	distance, ok := ByName("manhattan")
+	if !ok || distance(vectors.Vector{0, 0}, vectors.Vector{1, -2}) != 3 {
+		t.Fatal("Registered distance must be found by name")
+	}
+}
@@ -0,0 +1,21 @@
+package distances
+
+import "go-hnsw/hnsw/vectors"
+
+type SparseDistance func(v1, v2 vectors.SparseVector) vectors.VFloat
+
+// SparseDot is the negated dot product, so that the most similar vectors are
+// the nearest.
+var SparseDot SparseDistance = func(vector1, vector2 vectors.SparseVector) vectors.VFloat {
+	return -vector1.Dot(vector2)
+}
+
+// SparseCosine is 1 minus the cosine similarity. A zero vector is at distance
+// 1 from everything.
+var SparseCosine SparseDistance = func(vector1, vector2 vectors.SparseVector) vectors.VFloat {
+	norms := vector1.Norm() * vector2.Norm()
+	if norms == 0 {
+		return 1
+	}
+	return 1 - vector1.Dot(vector2)/norms
+}
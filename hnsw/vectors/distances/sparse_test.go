@@ -0,0 +1,36 @@
+package distances
+
+import (
+	"go-hnsw/hnsw/vectors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNewSparseVector(t *testing.T) {
+	v := vectors.NewSparseVector([]uint32{7, 2, 7, 9, 4}, []vectors.VFloat{1, 2, 3, 0, -4})
+	expected := vectors.SparseVector{Indices: []uint32{2, 4, 7}, Values: []vectors.VFloat{2, -4, 4}}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("got %v, expected %v", v, expected)
+	}
+}
+
+func TestSparseDistances(t *testing.T) {
+	v1 := vectors.SparseFromMap(map[uint32]vectors.VFloat{1: 3, 5: 4, 30000: 1})
+	v2 := vectors.SparseFromMap(map[uint32]vectors.VFloat{5: 2, 30000: 2, 40000: 7})
+
+	if d := SparseDot(v1, v2); d != -10 {
+		t.Fatalf("SparseDot expected to be -10 but %f found", d)
+	}
+
+	expected := 1 - 10/(math.Sqrt(26)*math.Sqrt(57))
+	if d := SparseCosine(v1, v2); math.Abs(float64(d)-expected) > 1e-12 {
+		t.Fatalf("SparseCosine expected to be %f but %f found", expected, d)
+	}
+	if d := SparseCosine(v1, v1); math.Abs(float64(d)) > 1e-12 {
+		t.Fatalf("SparseCosine of a vector with itself expected to be 0 but %f found", d)
+	}
+	if d := SparseCosine(v1, vectors.SparseVector{}); d != 1 {
+		t.Fatalf("SparseCosine with a zero vector expected to be 1 but %f found", d)
+	}
+}
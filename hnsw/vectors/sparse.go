@@ -0,0 +1,96 @@
+package vectors
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// SparseVector holds the non-zero components of a vector, sorted by index.
+type SparseVector struct {
+	Indices []uint32
+	Values  []VFloat
+}
+
+// NewSparseVector sorts the components by index, adds up repeated indices and
+// drops zeros.
+func NewSparseVector(indices []uint32, values []VFloat) SparseVector {
+	if len(indices) != len(values) {
+		panic(fmt.Sprintf("Expected %d values but %d found", len(indices), len(values)))
+	}
+
+	order := make([]int, len(indices))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return indices[order[i]] < indices[order[j]]
+	})
+
+	vector := SparseVector{}
+	for _, i := range order {
+		last := len(vector.Indices) - 1
+		if last >= 0 && vector.Indices[last] == indices[i] {
+			vector.Values[last] += values[i]
+			continue
+		}
+		vector.Indices = append(vector.Indices, indices[i])
+		vector.Values = append(vector.Values, values[i])
+	}
+
+	n := 0
+	for i := range vector.Indices {
+		if vector.Values[i] != 0 {
+			vector.Indices[n], vector.Values[n] = vector.Indices[i], vector.Values[i]
+			n += 1
+		}
+	}
+	vector.Indices, vector.Values = vector.Indices[:n], vector.Values[:n]
+	return vector
+}
+
+func SparseFromMap(components map[uint32]VFloat) SparseVector {
+	indices := make([]uint32, 0, len(components))
+	values := make([]VFloat, 0, len(components))
+	for index, value := range components {
+		indices = append(indices, index)
+		values = append(values, value)
+	}
+	return NewSparseVector(indices, values)
+}
+
+func (vector SparseVector) Len() int {
+	return len(vector.Indices)
+}
+
+// Dot merges the sorted indices of both vectors.
+func (vector SparseVector) Dot(other SparseVector) VFloat {
+	var dot VFloat
+	i, j := 0, 0
+	for i < len(vector.Indices) && j < len(other.Indices) {
+		switch {
+		case vector.Indices[i] < other.Indices[j]:
+			i += 1
+		case vector.Indices[i] > other.Indices[j]:
+			j += 1
+		default:
+			dot += vector.Values[i] * other.Values[j]
+			i += 1
+			j += 1
+		}
+	}
+	return dot
+}
+
+func (vector SparseVector) Norm() VFloat {
+	return VFloat(math.Sqrt(float64(vector.Dot(vector))))
+}
+
+func (vector SparseVector) String() string {
+	parts := make([]string, len(vector.Indices))
+	for i, index := range vector.Indices {
+		parts[i] = fmt.Sprintf("%d:%f", index, float64(vector.Values[i]))
+	}
+	return "SparseVector(" + strings.Join(parts, ", ") + ")"
+}
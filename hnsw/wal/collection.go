@@ -0,0 +1,264 @@
+package wal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/internal/fsutil"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	snapshotFile = "snapshot.hnsw"
+	logFile      = "wal.log"
+)
+
+// ErrDimension is returned for a vector whose dimension is not the one of
+// the collection.
+var ErrDimension = errors.New("wrong vector dimension")
+
+// Collection is an HnswCollection whose changes are logged before they are
+// acknowledged. Its directory holds the last snapshot and the log of the
+// changes made since. It is safe for concurrent use.
+//
+// A write that returns an error may still be visible in memory, but it is
+// only guaranteed to survive a crash when it returns nil.
+type Collection struct {
+	dir   string
+	mu    sync.RWMutex
+	index *hnsw.HnswCollection
+	log   *Log
+}
+
+// OpenCollection loads the snapshot of dir, or calls create when there is
+// none yet, and replays the log on top of it. Snapshots are loaded with the
+// distance registered under their saved name.
+func OpenCollection(dir string, opts Options, create func() *hnsw.HnswCollection) (*Collection, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := loadSnapshot(filepath.Join(dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		index, err = create(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log, err := Open(filepath.Join(dir, logFile), opts, func(record Record) error {
+		return apply(index, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Collection{dir: dir, index: index, log: log}, nil
+}
+
+func loadSnapshot(path string) (*hnsw.HnswCollection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return hnsw.LoadHnswCollection(bufio.NewReader(f), nil)
+}
+
+func apply(index *hnsw.HnswCollection, record Record) error {
+	switch record.Op {
+	case OpAdd, OpUpdate:
+		if len(record.Vector) != index.VectorDimension() {
+			return ErrCorrupted
+		}
+		index.Upsert(record.Id, record.Vector, record.Value)
+	case OpDelete:
+		index.Remove(record.Id)
+	}
+	return nil
+}
+
+func (c *Collection) Add(vector vectors.Vector, value []byte) (uint64, error) {
+	ids, err := c.AddBatch([]vectors.Vector{vector}, [][]byte{value})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// AddBatch adds the vectors and commits them to the log with a single sync.
+// Nothing is added when a vector has the wrong dimension.
+func (c *Collection) AddBatch(vectors []vectors.Vector, values [][]byte) ([]uint64, error) {
+	if values != nil && len(values) != len(vectors) {
+		return nil, fmt.Errorf("expected %d values but %d found", len(vectors), len(values))
+	}
+	for i, vector := range vectors {
+		err := c.checkDimension(vector)
+		if err != nil {
+			return nil, fmt.Errorf("vector %d: %w", i, err)
+		}
+	}
+
+	var ids []uint64
+	err := c.write(func() ([]Record, func()) {
+		ids = c.index.AddBatch(vectors, values)
+		records := make([]Record, len(ids))
+		for i, id := range ids {
+			records[i] = Record{Op: OpAdd, Id: id, Vector: vectors[i]}
+			if values != nil {
+				records[i].Value = values[i]
+			}
+		}
+		return records, func() {
+			for _, id := range ids {
+				c.index.Remove(id)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (c *Collection) Upsert(id uint64, vector vectors.Vector, value []byte) error {
+	return c.UpsertBatch([]hnsw.Point{{Id: id, Vector: vector, Value: value}})
+}
+
+// UpsertBatch upserts the points and commits them to the log with a single
+// sync. Nothing is upserted when a vector has the wrong dimension.
+func (c *Collection) UpsertBatch(points []hnsw.Point) error {
+	for _, point := range points {
+		err := c.checkDimension(point.Vector)
+		if err != nil {
+			return fmt.Errorf("point %d: %w", point.Id, err)
+		}
+	}
+
+	return c.write(func() ([]Record, func()) {
+		records := make([]Record, len(points))
+		for i, point := range points {
+			records[i] = Record{Op: OpAdd, Id: point.Id, Vector: point.Vector, Value: point.Value}
+			if _, ok := c.index.Get(point.Id); ok {
+				records[i].Op = OpUpdate
+			}
+		}
+		c.index.UpsertBatch(points)
+		return records, nil
+	})
+}
+
+func (c *Collection) checkDimension(vector vectors.Vector) error {
+	if len(vector) != c.index.VectorDimension() {
+		return fmt.Errorf("%w: %d, expected %d", ErrDimension, len(vector), c.index.VectorDimension())
+	}
+	return nil
+}
+
+func (c *Collection) Remove(id uint64) (bool, error) {
+	removed := false
+	err := c.write(func() ([]Record, func()) {
+		removed = c.index.Remove(id)
+		if !removed {
+			return nil, nil
+		}
+		return []Record{{Op: OpDelete, Id: id}}, nil
+	})
+	return removed, err
+}
+
+// write applies a change to the index and logs the records describing it
+// under the write lock, then waits for the log commit without holding it so
+// that concurrent writers can share a sync. rollback, when not nil, undoes
+// the change if it cannot be logged.
+func (c *Collection) write(change func() (records []Record, rollback func())) error {
+	seq, err := func() (uint64, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		records, rollback := change()
+		if len(records) == 0 {
+			return 0, nil
+		}
+		seq, err := c.log.Write(records...)
+		if err != nil && rollback != nil {
+			rollback()
+		}
+		return seq, err
+	}()
+	if err != nil || seq == 0 {
+		return err
+	}
+	return c.log.Commit(seq)
+}
+
+func (c *Collection) Get(id uint64) (hnsw.Point, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.index.Get(id)
+}
+
+func (c *Collection) Search(vector vectors.Vector, k int) []hnsw.SearchResult {
+	return c.SearchWithOptions(vector, k, hnsw.SearchOptions{})
+}
+
+func (c *Collection) SearchWithOptions(vector vectors.Vector, k int, opts hnsw.SearchOptions) []hnsw.SearchResult {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.index.SearchWithOptions(vector, k, opts)
+}
+
+func (c *Collection) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.index.Len()
+}
+
+// Snapshot saves the collection next to the log and truncates the log.
+// Writers wait until it is done.
+func (c *Collection) Snapshot() error {
+	return c.SnapshotContext(context.Background())
+}
+
+// SnapshotContext is Snapshot that gives up when ctx is done, keeping the
+// previous snapshot and the log.
+func (c *Collection) SnapshotContext(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := fsutil.WriteFileAtomic(filepath.Join(c.dir, snapshotFile), func(w io.Writer) error {
+		return c.index.SaveContext(ctx, w)
+	})
+	if err != nil {
+		return err
+	}
+	return c.log.Truncate()
+}
+
+// Compact rebuilds the graph of the collection, see HnswCollection.Compact.
+// Only the graph changes, so nothing is logged.
+func (c *Collection) Compact() {
+	c.CompactContext(context.Background())
+}
+
+// CompactContext is Compact that gives up when ctx is done, leaving the
+// collection as it was. Writers and searches wait until it is done.
+func (c *Collection) CompactContext(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.index.CompactContext(ctx)
+}
+
+// Close syncs the log. It does not take a snapshot.
+func (c *Collection) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.log.Close()
+}
@@ -0,0 +1,214 @@
+package wal
+
+import (
+	"context"
+	"errors"
+	"go-hnsw/hnsw"
+	"go-hnsw/hnsw/vectors"
+	"go-hnsw/hnsw/vectors/distances"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newIndex() *hnsw.HnswCollection {
+	return hnsw.NewHnswCollection(3, 4, distances.Euclidian, 8, 4)
+}
+
+func randomVector() vectors.Vector {
+	vector := make(vectors.Vector, 4)
+	for i := range vector {
+		vector[i] = vectors.VFloat(rand.Float64())
+	}
+	return vector
+}
+
+func checkSame(t *testing.T, expected map[uint64]vectors.Vector, c *Collection) {
+	t.Helper()
+	if c.Len() != len(expected) {
+		t.Fatalf("expected %d points, got %d", len(expected), c.Len())
+	}
+	for id, vector := range expected {
+		point, ok := c.Get(id)
+		if !ok || !reflect.DeepEqual(point.Vector, vector) {
+			t.Fatalf("point %d: expected %v, got %v", id, vector, point.Vector)
+		}
+	}
+}
+
+func TestCollectionRecovery(t *testing.T) {
+	dir := t.TempDir()
+	c, err := OpenCollection(dir, Options{Sync: SyncEveryOp}, newIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[uint64]vectors.Vector{}
+	for i := 0; i < 50; i += 1 {
+		vector := randomVector()
+		id, err := c.Add(vector, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected[id] = vector
+	}
+
+	err = c.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(filepath.Join(dir, logFile))
+	if info.Size() != 8 {
+		t.Fatalf("log not truncated after snapshot, size %d", info.Size())
+	}
+
+	for id := uint64(0); id < 10; id += 1 {
+		removed, err := c.Remove(id)
+		if err != nil || !removed {
+			t.Fatalf("remove %d: %v %v", id, removed, err)
+		}
+		delete(expected, id)
+	}
+	for id := uint64(10); id < 20; id += 1 {
+		vector := randomVector()
+		err = c.Upsert(id, vector, []byte("updated"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected[id] = vector
+	}
+	batch := []vectors.Vector{randomVector(), randomVector()}
+	ids, err := c.AddBatch(batch, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range ids {
+		expected[id] = batch[i]
+	}
+
+	// No Close: every change since the snapshot must come back from the log.
+	recovered, err := OpenCollection(dir, Options{}, func() *hnsw.HnswCollection {
+		t.Fatal("snapshot not loaded")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+	checkSame(t, expected, recovered)
+
+	point, _ := recovered.Get(15)
+	if string(point.Value) != "updated" {
+		t.Fatalf("update not replayed: %q", point.Value)
+	}
+	id, _ := recovered.Add(randomVector(), nil)
+	if _, ok := expected[id]; ok || id < 52 {
+		t.Fatalf("recovered collection reuses id %d", id)
+	}
+}
+
+func TestCollectionWithoutSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := OpenCollection(dir, Options{Sync: SyncGroup}, newIndex)
+	expected := map[uint64]vectors.Vector{}
+	for i := 0; i < 20; i += 1 {
+		vector := randomVector()
+		id, _ := c.Add(vector, nil)
+		expected[id] = vector
+	}
+	c.Close()
+
+	recovered, err := OpenCollection(dir, Options{}, newIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+	checkSame(t, expected, recovered)
+}
+
+func TestCollectionSnapshotCancelled(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := OpenCollection(dir, Options{Sync: SyncGroup}, newIndex)
+	expected := map[uint64]vectors.Vector{}
+	for i := 0; i < 20; i += 1 {
+		vector := randomVector()
+		id, _ := c.Add(vector, nil)
+		expected[id] = vector
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.SnapshotContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotFile)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("cancelled snapshot was written: %v", err)
+	}
+	c.Close()
+
+	recovered, err := OpenCollection(dir, Options{}, newIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+	checkSame(t, expected, recovered)
+}
+
+func TestCollectionCompact(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := OpenCollection(dir, Options{Sync: SyncGroup}, newIndex)
+	expected := map[uint64]vectors.Vector{}
+	for i := 0; i < 40; i += 1 {
+		vector := randomVector()
+		id, _ := c.Add(vector, nil)
+		expected[id] = vector
+	}
+	for id := uint64(0); id < 40; id += 4 {
+		c.Remove(id)
+		delete(expected, id)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.CompactContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	c.Compact()
+	checkSame(t, expected, c)
+	c.Close()
+
+	recovered, err := OpenCollection(dir, Options{}, newIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+	checkSame(t, expected, recovered)
+}
+
+func TestWrongDimensionIsAnError(t *testing.T) {
+	dir := t.TempDir()
+	c, _ := OpenCollection(dir, Options{Sync: SyncGroup}, newIndex)
+	vector := randomVector()
+	id, _ := c.Add(vector, nil)
+	expected := map[uint64]vectors.Vector{id: vector}
+
+	_, err := c.AddBatch([]vectors.Vector{randomVector(), {1, 2}}, nil)
+	if !errors.Is(err, ErrDimension) {
+		t.Fatalf("expected ErrDimension, got %v", err)
+	}
+	err = c.UpsertBatch([]hnsw.Point{{Id: 7, Vector: randomVector()}, {Id: 8, Vector: vectors.Vector{1}}})
+	if !errors.Is(err, ErrDimension) {
+		t.Fatalf("expected ErrDimension, got %v", err)
+	}
+	checkSame(t, expected, c)
+	c.Close()
+
+	recovered, err := OpenCollection(dir, Options{}, newIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+	checkSame(t, expected, recovered)
+}
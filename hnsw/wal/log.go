@@ -0,0 +1,481 @@
+// Package wal keeps collections durable between snapshots with an
+// append-only write-ahead log of their changes.
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go-hnsw/hnsw/vectors"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+const formatVersion uint32 = 1
+
+// recordHeaderSize is the payload length and its CRC32 preceding every record.
+const recordHeaderSize = 8
+
+var (
+	walMagic = [4]byte{'H', 'W', 'A', 'L'}
+
+	ErrCorrupted = errors.New("wal corrupted")
+	ErrClosed    = errors.New("wal closed")
+)
+
+type Op byte
+
+const (
+	OpAdd Op = iota + 1
+	OpUpdate
+	OpDelete
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpAdd:
+		return "add"
+	case OpUpdate:
+		return "update"
+	case OpDelete:
+		return "delete"
+	}
+	return fmt.Sprintf("Op(%d)", byte(op))
+}
+
+// Record is a logged change. Vector and Value are empty for deletes.
+type Record struct {
+	Op     Op
+	Id     uint64
+	Vector vectors.Vector
+	Value  []byte
+}
+
+type SyncPolicy int
+
+const (
+	// SyncEveryOp fsyncs before every write returns.
+	SyncEveryOp SyncPolicy = iota
+	// SyncGroup fsyncs before a write returns, but writers waiting at the same
+	// time share a single fsync.
+	SyncGroup
+	// SyncInterval fsyncs in the background every Options.Interval. A crash
+	// loses at most the writes of the last interval.
+	SyncInterval
+	// SyncNever leaves flushing to the operating system.
+	SyncNever
+)
+
+type Options struct {
+	Sync SyncPolicy
+	// Interval is the fsync period of SyncInterval.
+	Interval time.Duration
+}
+
+// Log is an append-only file of checksummed records. It is safe for
+// concurrent use.
+type Log struct {
+	opts Options
+
+	mu      sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	written uint64
+	closed  bool
+
+	syncMu sync.Mutex
+	synced uint64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Open opens the log at path, creating it when it does not exist, and calls
+// replay for every record in it. A record cut short by a crash at the end of
+// the log is dropped; damage anywhere else is reported as ErrCorrupted and
+// leaves the file as it is.
+func Open(path string, opts Options, replay func(record Record) error) (*Log, error) {
+	if opts.Sync == SyncInterval && opts.Interval <= 0 {
+		panic("Interval must be > 0")
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := readLog(file, replay)
+	if err == nil {
+		err = file.Truncate(end)
+	}
+	if err == nil {
+		_, err = file.Seek(end, io.SeekStart)
+	}
+	if err == nil && end == 0 {
+		err = writeLogHeader(file)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	log := &Log{opts: opts, file: file, writer: bufio.NewWriter(file)}
+	if opts.Sync == SyncInterval {
+		log.stop = make(chan struct{})
+		log.done = make(chan struct{})
+		go log.syncPeriodically()
+	}
+	return log, nil
+}
+
+func writeLogHeader(writer io.Writer) error {
+	err := binary.Write(writer, binary.LittleEndian, walMagic)
+	if err != nil {
+		return err
+	}
+	return binary.Write(writer, binary.LittleEndian, formatVersion)
+}
+
+// readLog replays the records of file and returns the offset after the last
+// complete one.
+func readLog(file *os.File, replay func(record Record) error) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return 0, nil
+	}
+
+	reader := bufio.NewReader(file)
+	var magic [4]byte
+	var version uint32
+	err = binary.Read(reader, binary.LittleEndian, &magic)
+	if err == nil {
+		err = binary.Read(reader, binary.LittleEndian, &version)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		// The header itself was torn, so the log holds no record.
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if magic != walMagic {
+		return 0, fmt.Errorf("%w: not a write-ahead log", ErrCorrupted)
+	}
+	if version != formatVersion {
+		return 0, fmt.Errorf("unsupported wal version %d", version)
+	}
+
+	offset := int64(8)
+	for {
+		record, n, err := readRecord(reader, size-offset)
+		if errors.Is(err, io.EOF) {
+			return offset, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// A length running past the end of the file is a torn tail only
+			// if no intact record follows it.
+			if n == recordHeaderSize {
+				follows, err := recordFollows(file, offset+recordHeaderSize, size)
+				if err != nil {
+					return 0, err
+				}
+				if follows {
+					return 0, fmt.Errorf("%w: record length at offset %d runs past the end of the log", ErrCorrupted, offset)
+				}
+			}
+			return offset, nil
+		}
+		if errors.Is(err, ErrCorrupted) {
+			if offset+int64(n) >= size {
+				return offset, nil
+			}
+			return 0, fmt.Errorf("%w at offset %d", err, offset)
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if replay != nil {
+			err = replay(record)
+			if err != nil {
+				return 0, err
+			}
+		}
+		offset += int64(n)
+	}
+}
+
+// recordFollows reports whether an intact record starts anywhere in file
+// between start and size.
+func recordFollows(file io.ReaderAt, start int64, size int64) (bool, error) {
+	const window = 64 << 10
+	buf := make([]byte, window+recordHeaderSize)
+	for base := start; base+recordHeaderSize <= size; base += window {
+		n, err := file.ReadAt(buf[:min(int64(len(buf)), size-base)], base)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		for p := 0; p < window && p+recordHeaderSize <= n; p += 1 {
+			length := int64(binary.LittleEndian.Uint32(buf[p : p+4]))
+			at := base + int64(p) + recordHeaderSize
+			if length < 13 || at+length > size {
+				continue
+			}
+			payload := make([]byte, length)
+			_, err := file.ReadAt(payload, at)
+			if err != nil {
+				return false, err
+			}
+			if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(buf[p+4:p+8]) {
+				continue
+			}
+			if _, err := decodeRecord(payload); err == nil {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func readRecord(reader io.Reader, remaining int64) (Record, int, error) {
+	var header [recordHeaderSize]byte
+	n, err := io.ReadFull(reader, header[:])
+	if err != nil {
+		if n > 0 {
+			return Record{}, n, io.ErrUnexpectedEOF
+		}
+		return Record{}, 0, err
+	}
+
+	length := binary.LittleEndian.Uint32(header[0:4])
+	checksum := binary.LittleEndian.Uint32(header[4:8])
+	if int64(length) > remaining-recordHeaderSize {
+		return Record{}, recordHeaderSize, io.ErrUnexpectedEOF
+	}
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(reader, payload)
+	if err != nil {
+		return Record{}, recordHeaderSize, io.ErrUnexpectedEOF
+	}
+	size := recordHeaderSize + int(length)
+
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return Record{}, size, fmt.Errorf("%w: checksum mismatch", ErrCorrupted)
+	}
+
+	record, err := decodeRecord(payload)
+	if err != nil {
+		return Record{}, size, err
+	}
+	return record, size, nil
+}
+
+func encodeRecord(record Record) []byte {
+	payload := make([]byte, 0, 1+8+4+8*len(record.Vector)+4+len(record.Value))
+	payload = append(payload, byte(record.Op))
+	payload = binary.LittleEndian.AppendUint64(payload, record.Id)
+	payload = binary.LittleEndian.AppendUint32(payload, uint32(len(record.Vector)))
+	for _, v := range record.Vector {
+		payload = binary.LittleEndian.AppendUint64(payload, math.Float64bits(float64(v)))
+	}
+	payload = binary.LittleEndian.AppendUint32(payload, uint32(len(record.Value)))
+	payload = append(payload, record.Value...)
+
+	buf := make([]byte, recordHeaderSize, recordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	return append(buf, payload...)
+}
+
+func decodeRecord(payload []byte) (Record, error) {
+	malformed := fmt.Errorf("%w: malformed record", ErrCorrupted)
+
+	if len(payload) < 13 {
+		return Record{}, malformed
+	}
+	record := Record{Op: Op(payload[0]), Id: binary.LittleEndian.Uint64(payload[1:9])}
+	if record.Op < OpAdd || record.Op > OpDelete {
+		return Record{}, malformed
+	}
+
+	dim := int(binary.LittleEndian.Uint32(payload[9:13]))
+	payload = payload[13:]
+	if len(payload) < dim*8+4 {
+		return Record{}, malformed
+	}
+	if dim > 0 {
+		record.Vector = make(vectors.Vector, dim)
+		for i := range record.Vector {
+			record.Vector[i] = vectors.VFloat(math.Float64frombits(binary.LittleEndian.Uint64(payload[i*8:])))
+		}
+	}
+	payload = payload[dim*8:]
+
+	valueLen := int(binary.LittleEndian.Uint32(payload[0:4]))
+	payload = payload[4:]
+	if len(payload) != valueLen {
+		return Record{}, malformed
+	}
+	if valueLen > 0 {
+		record.Value = payload
+	}
+	return record, nil
+}
+
+// Write appends the records without waiting for them to be durable and
+// returns a sequence number to pass to Commit.
+func (log *Log) Write(records ...Record) (uint64, error) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	if log.closed {
+		return 0, ErrClosed
+	}
+	for _, record := range records {
+		_, err := log.writer.Write(encodeRecord(record))
+		if err != nil {
+			return 0, err
+		}
+	}
+	log.written += 1
+	return log.written, nil
+}
+
+// Commit waits until the write with sequence number seq is as durable as the
+// sync policy promises.
+func (log *Log) Commit(seq uint64) error {
+	switch log.opts.Sync {
+	case SyncEveryOp:
+		return log.syncUpTo(seq, false)
+	case SyncGroup:
+		return log.syncUpTo(seq, true)
+	default:
+		log.mu.Lock()
+		defer log.mu.Unlock()
+		if log.closed {
+			return ErrClosed
+		}
+		return log.writer.Flush()
+	}
+}
+
+// Append writes the records and commits them.
+func (log *Log) Append(records ...Record) error {
+	seq, err := log.Write(records...)
+	if err != nil {
+		return err
+	}
+	return log.Commit(seq)
+}
+
+// syncUpTo fsyncs the log unless an fsync covering seq already happened. With
+// shared set, writes made while waiting for the previous fsync ride along.
+func (log *Log) syncUpTo(seq uint64, shared bool) error {
+	log.syncMu.Lock()
+	defer log.syncMu.Unlock()
+
+	if shared && log.synced >= seq {
+		return nil
+	}
+	return log.sync()
+}
+
+// Sync flushes and fsyncs everything written so far.
+func (log *Log) Sync() error {
+	log.syncMu.Lock()
+	defer log.syncMu.Unlock()
+	return log.sync()
+}
+
+func (log *Log) sync() error {
+	log.mu.Lock()
+	if log.closed {
+		log.mu.Unlock()
+		return ErrClosed
+	}
+	err := log.writer.Flush()
+	written := log.written
+	log.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	err = log.file.Sync()
+	if err != nil {
+		return err
+	}
+	log.synced = max(log.synced, written)
+	return nil
+}
+
+func (log *Log) syncPeriodically() {
+	defer close(log.done)
+
+	ticker := time.NewTicker(log.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-log.stop:
+			return
+		case <-ticker.C:
+			log.Sync()
+		}
+	}
+}
+
+// Truncate drops every record, including written ones not flushed yet. Call
+// it once the changes they describe are saved elsewhere, typically right
+// after a snapshot.
+func (log *Log) Truncate() error {
+	log.syncMu.Lock()
+	defer log.syncMu.Unlock()
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	if log.closed {
+		return ErrClosed
+	}
+
+	log.writer.Reset(log.file)
+	err := log.file.Truncate(0)
+	if err == nil {
+		_, err = log.file.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		err = writeLogHeader(log.file)
+	}
+	if err == nil {
+		err = log.file.Sync()
+	}
+	log.synced = log.written
+	return err
+}
+
+// Close flushes and fsyncs the log whatever the sync policy.
+func (log *Log) Close() error {
+	if log.stop != nil {
+		close(log.stop)
+		<-log.done
+		log.stop = nil
+	}
+
+	err := log.Sync()
+	if errors.Is(err, ErrClosed) {
+		return nil
+	}
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	log.closed = true
+	return errors.Join(err, log.file.Close())
+}
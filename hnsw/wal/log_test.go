@@ -0,0 +1,185 @@
+package wal
+
+import (
+	"errors"
+	"go-hnsw/hnsw/vectors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testRecords() []Record {
+	return []Record{
+		{Op: OpAdd, Id: 1, Vector: vectors.Vector{1, 2, 3}, Value: []byte("one")},
+		{Op: OpAdd, Id: 2, Vector: vectors.Vector{4, 5, 6}},
+		{Op: OpUpdate, Id: 1, Vector: vectors.Vector{7, 8, 9}, Value: []byte("uno")},
+		{Op: OpDelete, Id: 2},
+	}
+}
+
+func readAll(t *testing.T, path string) []Record {
+	t.Helper()
+	var records []Record
+	log, err := Open(path, Options{}, func(record Record) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = log.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestLogReplay(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncEveryOp, SyncGroup, SyncInterval, SyncNever} {
+		path := filepath.Join(t.TempDir(), "wal.log")
+		log, err := Open(path, Options{Sync: policy, Interval: time.Millisecond}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range testRecords() {
+			err = log.Append(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = log.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		records := readAll(t, path)
+		if !reflect.DeepEqual(records, testRecords()) {
+			t.Fatalf("policy %d: replayed %+v, expected %+v", policy, records, testRecords())
+		}
+	}
+}
+
+func TestLogTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	log, _ := Open(path, Options{}, nil)
+	log.Append(testRecords()...)
+	log.Close()
+
+	info, _ := os.Stat(path)
+	lastSize := int64(len(encodeRecord(testRecords()[3])))
+
+	for _, cut := range []int64{1, 4, lastSize - 1} {
+		err := os.Truncate(path, info.Size()-cut)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records := readAll(t, path)
+		if !reflect.DeepEqual(records, testRecords()[:3]) {
+			t.Fatalf("cut %d: replayed %+v", cut, records)
+		}
+
+		// The torn record is gone, so appending again yields a valid log.
+		log, _ = Open(path, Options{}, nil)
+		log.Append(testRecords()[3])
+		log.Close()
+		if records := readAll(t, path); !reflect.DeepEqual(records, testRecords()) {
+			t.Fatalf("cut %d: replayed %+v after append", cut, records)
+		}
+	}
+}
+
+func TestLogCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	log, _ := Open(path, Options{}, nil)
+	log.Append(testRecords()...)
+	log.Close()
+
+	data, _ := os.ReadFile(path)
+
+	// A flipped byte in the last record looks like a torn write.
+	tail := append([]byte{}, data...)
+	tail[len(tail)-1] ^= 0xff
+	os.WriteFile(path, tail, 0o644)
+	if records := readAll(t, path); len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+
+	// In the middle of the log it is reported.
+	middle := append([]byte{}, data...)
+	middle[8+recordHeaderSize+2] ^= 0xff
+	os.WriteFile(path, middle, 0o644)
+	_, err := Open(path, Options{}, nil)
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted, got %v", err)
+	}
+}
+
+func TestLogCorruptLengthInTheMiddle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	log, _ := Open(path, Options{}, nil)
+	log.Append(testRecords()...)
+	log.Close()
+
+	data, _ := os.ReadFile(path)
+	corrupted := append([]byte{}, data...)
+	// The length of the first record now runs past the end of the file.
+	corrupted[8+3] = 0x7f
+	os.WriteFile(path, corrupted, 0o644)
+
+	_, err := Open(path, Options{}, nil)
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("expected ErrCorrupted, got %v", err)
+	}
+	after, _ := os.ReadFile(path)
+	if !reflect.DeepEqual(after, corrupted) {
+		t.Fatalf("the corrupted log was changed from %d to %d bytes", len(corrupted), len(after))
+	}
+}
+
+func TestLogTruncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	log, _ := Open(path, Options{}, nil)
+	log.Append(testRecords()[:2]...)
+	err := log.Truncate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Append(testRecords()[2:]...)
+	log.Close()
+
+	if records := readAll(t, path); !reflect.DeepEqual(records, testRecords()[2:]) {
+		t.Fatalf("replayed %+v", records)
+	}
+}
+
+func TestLogGroupCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+	log, _ := Open(path, Options{Sync: SyncGroup}, nil)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j += 1 {
+				err := log.Append(Record{Op: OpDelete, Id: uint64(j)})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	log.Close()
+
+	if records := readAll(t, path); len(records) != 400 {
+		t.Fatalf("expected 400 records, got %d", len(records))
+	}
+	if err := log.Append(testRecords()[0]); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
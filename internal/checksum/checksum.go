@@ -0,0 +1,93 @@
+// Package checksum frames the files written by the packages that persist
+// collections with a trailing CRC32 of their content.
+package checksum
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+var ErrMismatch = errors.New("checksum mismatch")
+
+// readChunk is the number of bytes read at a time while checking the rest
+// of a file.
+const readChunk = 1024
+
+// Write writes what body produces followed by its CRC32.
+func Write(writer io.Writer, body func(w io.Writer) error) error {
+	buffered := bufio.NewWriter(writer)
+	checksum := crc32.NewIEEE()
+
+	err := body(io.MultiWriter(buffered, checksum))
+	if err != nil {
+		return err
+	}
+
+	err = binary.Write(buffered, binary.LittleEndian, checksum.Sum32())
+	if err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// Read lets body read what Write wrote and then checks the CRC32 after it.
+func Read(reader io.Reader, body func(r io.Reader) error) error {
+	checksum := crc32.NewIEEE()
+
+	err := body(io.TeeReader(reader, checksum))
+	if err != nil {
+		// A corrupt length or field is reported as a checksum mismatch when
+		// the rest of the file shows that it is one.
+		if verifyRest(reader, checksum) == ErrMismatch {
+			return ErrMismatch
+		}
+		return err
+	}
+
+	return verify(reader, checksum)
+}
+
+// verifyRest adds everything left in reader but its last 4 bytes to the
+// checksum and compares it to them.
+func verifyRest(reader io.Reader, checksum hash.Hash32) error {
+	tail := make([]byte, 0, 4+readChunk)
+	buff := make([]byte, readChunk)
+	for {
+		n, err := reader.Read(buff)
+		tail = append(tail, buff[:n]...)
+		if len(tail) > 4 {
+			checksum.Write(tail[:len(tail)-4])
+			copy(tail, tail[len(tail)-4:])
+			tail = tail[:4]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(tail) < 4 {
+		return io.ErrUnexpectedEOF
+	}
+	if binary.LittleEndian.Uint32(tail) != checksum.Sum32() {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func verify(reader io.Reader, checksum hash.Hash32) error {
+	var expected uint32
+	err := binary.Read(reader, binary.LittleEndian, &expected)
+	if err != nil {
+		return err
+	}
+	if expected != checksum.Sum32() {
+		return ErrMismatch
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package checksum
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func readAll(data []byte) (string, error) {
+	var body []byte
+	err := Read(bytes.NewReader(data), func(r io.Reader) error {
+		body = make([]byte, 5)
+		_, err := io.ReadFull(r, body)
+		return err
+	})
+	return string(body), err
+}
+
+func TestReadWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, func(w io.Writer) error {
+		_, err := io.WriteString(w, "hello")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body, err := readAll(buf.Bytes()); err != nil || body != "hello" {
+		t.Fatalf("expected hello, got %q, %v", body, err)
+	}
+
+	corrupted := bytes.Clone(buf.Bytes())
+	corrupted[1] ^= 0xff
+	if _, err := readAll(corrupted); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+
+	if _, err := readAll(buf.Bytes()[:7]); err == nil {
+		t.Fatalf("expected a truncated checksum to fail")
+	}
+}
@@ -0,0 +1,40 @@
+package fsutil
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data")
+
+	err := WriteFileAtomic(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "first")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failure := errors.New("failure")
+	err = WriteFileAtomic(path, func(w io.Writer) error {
+		io.WriteString(w, "second")
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "first" {
+		t.Fatalf("failed write changed the file to %q", data)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}